@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// loadConfigFile sets the flags of fs from a JSON, or TOML if the file name ends in
+// .toml, object whose keys are flag names, e.g. {"w": 3, "transport": "obfs4"}.
+// Flags set on the command line keep their values. Keys that are not flags, and
+// values other than strings, numbers and booleans, are errors.
+func loadConfigFile(fs *flag.FlagSet, filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var options map[string]interface{}
+	if filepath.Ext(filename) == ".toml" {
+		tree, err := toml.LoadBytes(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %v", filename, err)
+		}
+		options = tree.ToMap()
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&options); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %v", filename, err)
+		}
+		if decoder.More() {
+			return fmt.Errorf("failed to parse config file %s: data after the options object", filename)
+		}
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	// in order, for the same error on every run
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown option %q in config file %s", name, filename)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+
+		var value string
+		switch v := options[name].(type) {
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		case json.Number:
+			value = v.String()
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			return fmt.Errorf("option %q in config file %s must be a string, number or boolean", name, filename)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for option %q in config file %s: %v", name, filename, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	newFlags := func() (*flag.FlagSet, *int, *string, *bool, *time.Duration) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("config", "", "")
+		return fs, fs.Int("w", 5, ""), fs.String("transport", "min", ""),
+			fs.Bool("debug", false, ""), fs.Duration("reg-timeout", 0, "")
+	}
+	writeConfig := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		require.Nil(t, ioutil.WriteFile(filename, []byte(content), 0600))
+		return filename
+	}
+
+	// file values apply, except over flags set on the command line
+	fs, width, transport, debug, regTimeout := newFlags()
+	require.Nil(t, fs.Parse([]string{"-transport", "tls"}))
+	config := writeConfig("config.json", `{"w": 3, "transport": "obfs4", "debug": true, "reg-timeout": "5s"}`)
+	require.Nil(t, loadConfigFile(fs, config))
+	require.Equal(t, 3, *width)
+	require.Equal(t, "tls", *transport)
+	require.True(t, *debug)
+	require.Equal(t, 5*time.Second, *regTimeout)
+
+	fs, width, transport, _, _ = newFlags()
+	config = writeConfig("config.toml", "w = 2\ntransport = \"obfs4\"\n")
+	require.Nil(t, loadConfigFile(fs, config))
+	require.Equal(t, 2, *width)
+	require.Equal(t, "obfs4", *transport)
+
+	for content, expected := range map[string]string{
+		`{"width": 3}`:         `unknown option "width"`,
+		`{"config": "a.json"}`: `unknown option "config"`,
+		`{"w": "three"}`:       `invalid value for option "w"`,
+		`{"w": [3]}`:           `option "w" in config file`,
+		`{"w": 3`:              "failed to parse config file",
+		`{"debug": true} {}`:   "data after the options object",
+	} {
+		fs, _, _, _, _ = newFlags()
+		err := loadConfigFile(fs, writeConfig("bad.json", content))
+		require.NotNil(t, err, content)
+		require.Contains(t, err.Error(), expected, content)
+	}
+}
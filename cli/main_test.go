@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimuls/gotapdance/tapdance"
+	"github.com/stretchr/testify/require"
+)
+
+// erroringRegistrar fails every registration, so dialing always errors.
+type erroringRegistrar struct{}
+
+func (erroringRegistrar) Register(*tapdance.ConjureSession, context.Context) (*tapdance.ConjureReg, error) {
+	return nil, errors.New("stub registrar always fails")
+}
+
+func TestManageConnDialFailure(t *testing.T) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.Nil(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	defer client.Close()
+
+	clientConn, err := l.AcceptTCP()
+	require.Nil(t, err)
+
+	tdDialer := tapdance.Dialer{
+		DarkDecoy:          true,
+		DarkDecoyRegistrar: erroringRegistrar{},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		manageConn(tdDialer, "1.2.3.4:443", clientConn, nil, 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("manageConn did not return after dial failure")
+	}
+
+	// the client sees the connection torn down instead of hanging
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = client.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	netErr, ok := err.(net.Error)
+	require.False(t, ok && netErr.Timeout(), "client connection was left open")
+}
+
+func TestLoadAssets(t *testing.T) {
+	oldpath := tapdance.Assets().GetAssetsDir()
+	defer tapdance.AssetsSetDir(oldpath)
+
+	err := loadAssets(filepath.Join(t.TempDir(), "missing"))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "not found")
+
+	// no ClientConf: the built-in defaults are used
+	require.Nil(t, loadAssets(t.TempDir()))
+
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "ClientConf"), []byte("not a ClientConf"), 0644))
+	err = loadAssets(dir)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "Failed to parse the ClientConf")
+}
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.Nil(t, err)
+	defer l.Close()
+
+	dialed, err := net.DialTCP("tcp", nil, l.Addr().(*net.TCPAddr))
+	require.Nil(t, err)
+	accepted, err := l.AcceptTCP()
+	require.Nil(t, err)
+	return dialed, accepted
+}
+
+func TestProxyConnsHalfClose(t *testing.T) {
+	client, clientConn := tcpPair(t)
+	defer client.Close()
+	tdConn, server := tcpPair(t)
+	defer server.Close()
+
+	done := make(chan struct{})
+	var bytesUp, bytesDown int64
+	var proxyErr error
+	go func() {
+		bytesUp, bytesDown, proxyErr = proxyConns(clientConn, tdConn, nil, 0)
+		close(done)
+	}()
+
+	// the client sends its request and EOF first
+	request := []byte("request")
+	_, err := client.Write(request)
+	require.Nil(t, err)
+	require.Nil(t, client.CloseWrite())
+
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, err := io.ReadAll(server)
+	require.Nil(t, err)
+	require.Equal(t, request, received)
+
+	// the server still gets its whole response through after the client's EOF
+	response := bytes.Repeat([]byte("response"), 64*1024)
+	_, err = server.Write(response)
+	require.Nil(t, err)
+	require.Nil(t, server.CloseWrite())
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, err = io.ReadAll(client)
+	require.Nil(t, err)
+	require.Equal(t, response, received)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxyConns did not return after both directions finished")
+	}
+	require.Equal(t, int64(len(request)), bytesUp)
+	require.Equal(t, int64(len(response)), bytesDown)
+	require.Nil(t, proxyErr)
+}
+
+func TestProxyConnsByteLimit(t *testing.T) {
+	client, clientConn := tcpPair(t)
+	defer client.Close()
+	tdConn, server := tcpPair(t)
+	defer server.Close()
+
+	done := make(chan error)
+	go func() {
+		_, _, err := proxyConns(clientConn, tdConn, nil, 1024)
+		done <- err
+	}()
+
+	// a covert host sending more than the limit gets the tunnel closed
+	go server.Write(bytes.Repeat([]byte("response"), 64*1024))
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, _ := io.ReadAll(client)
+	require.LessOrEqual(t, len(received), 1024)
+
+	select {
+	case err := <-done:
+		require.Equal(t, errTunnelLimit, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxyConns did not return after exceeding the byte limit")
+	}
+}
+
+func TestMakeRegistrar(t *testing.T) {
+	registrar, err := makeRegistrar("", registrarConfig{})
+	require.Nil(t, err)
+	require.IsType(t, tapdance.DecoyRegistrar{}, registrar)
+
+	// -api-endpoint alone implies the api registrar
+	for _, name := range []string{"", "api"} {
+		registrar, err = makeRegistrar(name, registrarConfig{apiEndpoint: "https://a.test,https://b.test"})
+		require.Nil(t, err)
+		api, ok := registrar.(tapdance.APIRegistrar)
+		require.True(t, ok)
+		require.Equal(t, []string{"https://a.test", "https://b.test"}, api.Endpoints)
+		require.IsType(t, tapdance.DecoyRegistrar{}, api.SecondaryRegistrar)
+	}
+
+	_, err = makeRegistrar("api", registrarConfig{})
+	require.EqualError(t, err, "the api registrar requires -api-endpoint")
+	_, err = makeRegistrar("decoy", registrarConfig{apiEndpoint: "https://a.test"})
+	require.EqualError(t, err, "-api-endpoint cannot be used with the decoy registrar")
+	_, err = makeRegistrar("dns", registrarConfig{})
+	require.EqualError(t, err, `unknown registrar "dns", expected one of api, decoy`)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dimuls/gotapdance/tapdance"
+	"github.com/sirupsen/logrus"
+)
+
+// udpIdleTimeout - a UDP client's tunnel is closed after this long without datagrams
+// in either direction
+const udpIdleTimeout = 2 * time.Minute
+
+// udpQueueLen - datagrams buffered per client while its tunnel is being established;
+// further datagrams are dropped, as UDP allows
+const udpQueueLen = 64
+
+// udpClient - the datagrams of one local UDP client, relayed over its own tunnel
+type udpClient struct {
+	addr    *net.UDPAddr
+	packets chan []byte
+}
+
+// connectUDP relays UDP datagrams received on localPort to connect_target, with a
+// Conjure connection registered with CovertUDP per client address.
+func connectUDP(tdDialer tapdance.Dialer, connect_target string, localPort int) error {
+	if _, _, err := net.SplitHostPort(connect_target); err != nil {
+		return fmt.Errorf("failed to parse host and port from connect_target %s: %v",
+			connect_target, err)
+	}
+	tdDialer.CovertUDP = true
+
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: localPort})
+	if err != nil {
+		return fmt.Errorf("error listening on port %v: %v", localPort, err)
+	}
+	defer l.Close()
+
+	var clientsMu sync.Mutex
+	clients := make(map[string]*udpClient)
+	removeClient := func(client *udpClient) {
+		clientsMu.Lock()
+		delete(clients, client.addr.String())
+		close(client.packets)
+		clientsMu.Unlock()
+	}
+
+	buf := make([]byte, 1<<16)
+	for {
+		n, addr, err := l.ReadFromUDP(buf)
+		if err != nil {
+			return fmt.Errorf("error reading client datagram: %v", err)
+		}
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+
+		clientsMu.Lock()
+		client, ok := clients[addr.String()]
+		if !ok {
+			client = &udpClient{addr: addr, packets: make(chan []byte, udpQueueLen)}
+			clients[addr.String()] = client
+			go func() {
+				manageUDPClient(tdDialer, connect_target, l, client)
+				removeClient(client)
+			}()
+		}
+		select {
+		case client.packets <- packet:
+		default:
+			tapdance.Logger().Debugf("dropping datagram from %v: queue full", addr)
+		}
+		clientsMu.Unlock()
+	}
+}
+
+// manageUDPClient dials a tunnel for the client and relays its datagrams until the
+// tunnel fails or is idle for udpIdleTimeout.
+func manageUDPClient(tdDialer tapdance.Dialer, connect_target string, l *net.UDPConn, client *udpClient) {
+	tdConn, err := tdDialer.Dial("tcp", connect_target)
+	if err != nil || tdConn == nil {
+		tapdance.Logger().WithFields(logrus.Fields{
+			"client": client.addr.String(),
+			"covert": connect_target,
+		}).Errorf("failed to dial %s: %v", connect_target, err)
+		return
+	}
+	tunnel := tapdance.NewDatagramConn(tdConn)
+	defer tunnel.Close()
+
+	tunnelStart := time.Now()
+	var datagramsUp, datagramsDown int64
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case packet, ok := <-client.packets:
+				if !ok {
+					return
+				}
+				if _, err := tunnel.Write(packet); err != nil {
+					tunnel.Close()
+					return
+				}
+				atomic.AddInt64(&datagramsUp, 1)
+				tunnel.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 1<<16)
+	for {
+		tunnel.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+		n, err := tunnel.Read(buf)
+		if err != nil {
+			break
+		}
+		if _, err := l.WriteToUDP(buf[:n], client.addr); err != nil {
+			break
+		}
+		datagramsDown++
+	}
+
+	tapdance.Logger().WithFields(logrus.Fields{
+		"client":         client.addr.String(),
+		"covert":         connect_target,
+		"datagrams_up":   atomic.LoadInt64(&datagramsUp),
+		"datagrams_down": datagramsDown,
+		"duration":       time.Since(tunnelStart).String(),
+	}).Info("udp tunnel closed")
+}
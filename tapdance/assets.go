@@ -1,16 +1,21 @@
 package tapdance
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
 	"path"
 	"strings"
 	"sync"
+	"time"
 
 	pb "github.com/dimuls/gotapdance/protobuf"
 	ps "github.com/dimuls/gotapdance/tapdance/phantoms"
@@ -23,18 +28,47 @@ type assets struct {
 
 	config *pb.ClientConf
 
+	// generation of the ClientConf the phantom subnets came from, kept when decoys
+	// are overridden. Selects the phantom selection algorithm version.
+	phantomsGeneration uint32
+
 	roots *x509.CertPool
 
 	filenameRoots      string
 	filenameClientConf string
 
 	socksAddr string
+
+	// noClientConfUpdates pins the ClientConf: see DisableClientConfUpdates
+	noClientConfUpdates bool
 }
 
 // could reset this internally to refresh assets and avoid woes of singleton testing
 var assetsInstance *assets
 var assetsOnce sync.Once
 
+var (
+	// ErrAssetsDirMissing - The assets directory does not exist or is not a directory.
+	// The built-in default ClientConf is used.
+	ErrAssetsDirMissing = errors.New("assets directory missing")
+
+	// ErrClientConfInvalid - The ClientConf file could not be parsed. The previous,
+	// or built-in default, ClientConf is used.
+	ErrClientConfInvalid = errors.New("invalid ClientConf")
+)
+
+// checkAssetsDir - Get an ErrAssetsDirMissing error if dir is not a directory
+func checkAssetsDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAssetsDirMissing, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %v is not a directory", ErrAssetsDirMissing, dir)
+	}
+	return nil
+}
+
 // Assets is an access point to asset managing singleton.
 // First access to singleton sets path. Assets(), if called
 // before SetAssetsDir() sets path to "./assets/"
@@ -50,6 +84,9 @@ func Assets() *assets {
 
 // AssetsSetDir sets the directory to read assets from.
 // Functionally equivalent to Assets() after initialization, unless dir changes.
+// The error wraps ErrAssetsDirMissing if dir does not exist, ErrClientConfInvalid if
+// its ClientConf can't be parsed, or an os.ErrNotExist error if it has no ClientConf.
+// The assets remain usable in all cases, falling back to the built-in defaults.
 func AssetsSetDir(dir string) (*assets, error) {
 	var err error
 	_initAssets := func() { err = initAssets(dir) }
@@ -58,7 +95,7 @@ func AssetsSetDir(dir string) (*assets, error) {
 		defer assetsInstance.Unlock()
 		if dir != assetsInstance.path {
 
-			if _, err := os.Stat(dir); err != nil {
+			if err := checkAssetsDir(dir); err != nil {
 				Logger().Warnf("Assets path unchanged %v.\n", err)
 				return assetsInstance, err
 			}
@@ -112,10 +149,15 @@ func initAssets(path string) error {
 	assetsInstance = &assets{
 		path:               path,
 		config:             &defaultClientConf,
+		phantomsGeneration: defaultGeneration,
 		filenameRoots:      "roots",
 		filenameClientConf: "ClientConf",
 		socksAddr:          "",
 	}
+	if err := checkAssetsDir(path); err != nil {
+		Logger().Warnf("Assets: %v", err)
+		return err
+	}
 	err := assetsInstance.readConfigs()
 	return err
 }
@@ -142,16 +184,11 @@ func (a *assets) readConfigs() error {
 	}
 
 	readClientConf := func(filename string) error {
-		buf, err := ioutil.ReadFile(filename)
+		clientConf, err := readClientConfFile(filename)
 		if err != nil {
 			return err
 		}
-		clientConf := &pb.ClientConf{}
-		err = proto.Unmarshal(buf, clientConf)
-		if err != nil {
-			return err
-		}
-		a.config = clientConf
+		a.setConfig(clientConf)
 		return nil
 	}
 
@@ -178,6 +215,81 @@ func (a *assets) readConfigs() error {
 	return err
 }
 
+func readClientConfFile(filename string) (*pb.ClientConf, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	clientConf := &pb.ClientConf{}
+	err = proto.Unmarshal(buf, clientConf)
+	if err != nil {
+		return nil, fmt.Errorf("%w %v: %v", ErrClientConfInvalid, filename, err)
+	}
+	return clientConf, nil
+}
+
+// Reload re-reads the ClientConf from the assets directory and swaps it in if its
+// generation is newer than the one currently used; older (or same) generations are
+// ignored. The ClientConf is replaced as a whole, so concurrent readers see either
+// the old or the new decoy list, never a mix. Returns whether the ClientConf changed.
+func (a *assets) Reload() (bool, error) {
+	clientConfFilename := path.Join(a.GetAssetsDir(), a.filenameClientConf)
+	clientConf, err := readClientConfFile(clientConfFilename)
+	if err != nil {
+		Logger().Warn("Assets: failed to reload ClientConf file: " + err.Error())
+		return false, err
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if clientConf.GetGeneration() <= a.config.GetGeneration() {
+		Logger().Debugf("Assets: ignoring ClientConf generation %v, using %v",
+			clientConf.GetGeneration(), a.config.GetGeneration())
+		return false, nil
+	}
+
+	Logger().Infof("Assets: reloaded ClientConf generation %v -> %v",
+		a.config.GetGeneration(), clientConf.GetGeneration())
+	a.setConfig(clientConf)
+	return true, nil
+}
+
+// setConfig - Swap in a ClientConf received as a whole (from disk or station).
+// Caller must hold the lock, or have exclusive access.
+func (a *assets) setConfig(conf *pb.ClientConf) {
+	a.config = conf
+	a.phantomsGeneration = conf.GetGeneration()
+}
+
+// WatchReload polls the ClientConf file in the assets directory every interval and
+// calls Reload when its modification time changes, until ctx is done.
+// Intended to be run in its own goroutine by long-running proxies.
+func (a *assets) WatchReload(ctx context.Context, interval time.Duration) {
+	clientConfFilename := path.Join(a.GetAssetsDir(), a.filenameClientConf)
+	var lastModTime time.Time
+	if info, err := os.Stat(clientConfFilename); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(clientConfFilename)
+		if err != nil || !info.ModTime().After(lastModTime) {
+			continue
+		}
+		lastModTime = info.ModTime()
+		a.Reload()
+	}
+}
+
 // Picks random decoy, returns Server Name Indication and addr in format ipv4:port
 func (a *assets) GetDecoyAddress() (sni string, addr string) {
 	a.RLock()
@@ -198,11 +310,21 @@ func (a *assets) GetDecoyAddress() (sni string, addr string) {
 
 // Get all Decoys from ClientConf
 func (a *assets) GetAllDecoys() []*pb.TLSDecoySpec {
+	a.RLock()
+	defer a.RUnlock()
+
 	return a.config.GetDecoyList().GetTlsDecoys()
 }
 
 // Get all Decoys from ClientConf that have an IPv6 address
 func (a *assets) GetV6Decoys() []*pb.TLSDecoySpec {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.getV6Decoys()
+}
+
+func (a *assets) getV6Decoys() []*pb.TLSDecoySpec {
 	v6Decoys := make([]*pb.TLSDecoySpec, 0)
 	allDecoys := a.config.GetDecoyList().GetTlsDecoys()
 
@@ -217,6 +339,9 @@ func (a *assets) GetV6Decoys() []*pb.TLSDecoySpec {
 
 // Get all Decoys from ClientConf that have an IPv6 address
 func (a *assets) GetV4Decoys() []*pb.TLSDecoySpec {
+	a.RLock()
+	defer a.RUnlock()
+
 	v6Decoys := make([]*pb.TLSDecoySpec, 0)
 	allDecoys := a.config.GetDecoyList().GetTlsDecoys()
 
@@ -245,6 +370,10 @@ func (a *assets) GetDecoy() *pb.TLSDecoySpec {
 	//[TODO]{priority:soon} stop enforcing values >= defaults.
 	// Fix ackhole instead
 	// No value checks when using
+	if chosenDecoy.GetTimeout() < timeoutMin || chosenDecoy.GetTcpwin() < sendLimitMin {
+		// only holding the read lock: adjust a copy, shared ClientConf stays untouched
+		chosenDecoy = proto.Clone(chosenDecoy).(*pb.TLSDecoySpec)
+	}
 	if chosenDecoy.GetTimeout() < timeoutMin {
 		timeout := uint32(timeoutMax)
 		chosenDecoy.Timeout = &timeout
@@ -261,7 +390,7 @@ func (a *assets) GetV6Decoy() *pb.TLSDecoySpec {
 	a.RLock()
 	defer a.RUnlock()
 
-	decoys := a.GetV6Decoys()
+	decoys := a.getV6Decoys()
 	chosenDecoy := &pb.TLSDecoySpec{}
 	if len(decoys) == 0 {
 		return chosenDecoy
@@ -298,6 +427,82 @@ func (a *assets) GetConjurePubkey() *[32]byte {
 	return &pKey
 }
 
+// GetObfs4Params - Get the obfs4 bridge parameters of the station, nil if the
+// ClientConf has none
+func (a *assets) GetObfs4Params() *pb.Obfs4Params {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.config.GetObfs4Params()
+}
+
+// GetFrontingParams - Get the fronted transport parameters, nil if the ClientConf
+// has none
+func (a *assets) GetFrontingParams() *pb.FrontingParams {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.config.GetFrontingParams()
+}
+
+// GetPhantomServerName - Get the default SNI of the TLS transport connections to
+// phantoms, "" if the ClientConf has none
+func (a *assets) GetPhantomServerName() string {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.config.GetPhantomServerName()
+}
+
+// GetKeyLengths - Get the lengths of the registration payload keys and IVs set by the
+// ClientConf, DefaultKeyLengths for those it does not set
+func (a *assets) GetKeyLengths() KeyLengths {
+	a.RLock()
+	defer a.RUnlock()
+
+	return keyLengthsFromConf(a.config.GetKeyLengths())
+}
+
+// DisableClientConfUpdates - Stop, or resume, applying the ClientConfs sent by
+// stations, pinning the local assets for reproducible testing.
+//
+// ClientConfs are numbered by generation, increasing with each update of the decoys
+// or phantom subnets, which also selects the phantom selection algorithm version.
+// Clients report the generation of their ClientConf with every registration, and
+// TapDance stations reply with their own ClientConf when it is newer; clients only
+// apply ClientConfs of a higher generation. While updates are disabled, the maximum
+// generation is reported so stations send none, and any received is ignored. The
+// local generation is unchanged, so is the phantom selection. ClientConfs loaded from
+// the assets directory (see Reload) still apply. To pin the generation for the
+// registrations of a single Dialer instead, see Dialer.PinClientConf.
+func (a *assets) DisableClientConfUpdates(disable bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.noClientConfUpdates = disable
+}
+
+// ClientConfUpdatesDisabled - Whether ClientConfs sent by stations are ignored
+func (a *assets) ClientConfUpdatesDisabled() bool {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.noClientConfUpdates
+}
+
+// getReportedGeneration - Get the ClientConf generation to report to stations:
+// the maximum generation when updates are disabled, or pinned by the caller (see
+// Dialer.PinClientConf), so stations send none
+func (a *assets) getReportedGeneration(pinned bool) uint32 {
+	a.RLock()
+	defer a.RUnlock()
+
+	if a.noClientConfUpdates || pinned {
+		return ^uint32(0)
+	}
+	return a.config.GetGeneration()
+}
+
 func (a *assets) GetGeneration() uint32 {
 	a.RLock()
 	defer a.RUnlock()
@@ -331,16 +536,50 @@ func (a *assets) SetClientConf(conf *pb.ClientConf) (err error) {
 	a.Lock()
 	defer a.Unlock()
 
-	a.config = conf
+	a.setConfig(conf)
 	err = a.saveClientConf()
 	return
 }
 
-// Not goroutine-safe, use at your own risk
+// Not goroutine-safe, use at your own risk.
+// Use OverrideDecoys to modify the ClientConf in use while dialing.
 func (a *assets) GetClientConfPtr() *pb.ClientConf {
 	return a.config
 }
 
+// OverrideDecoys replaces currently used decoys and generation without storing
+// config to disk. The ClientConf is copied and swapped, so concurrent readers
+// keep a consistent view of the previous one.
+func (a *assets) OverrideDecoys(decoys []*pb.TLSDecoySpec, gen uint32) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.overrideDecoys(decoys, gen)
+}
+
+// swapDecoys - Like OverrideDecoys, returning the decoys and generation replaced. Both
+// happen under the lock, so no concurrent ClientConf update is lost in between.
+func (a *assets) swapDecoys(decoys []*pb.TLSDecoySpec, gen uint32) ([]*pb.TLSDecoySpec, uint32) {
+	a.Lock()
+	defer a.Unlock()
+
+	previousDecoys := a.config.GetDecoyList().GetTlsDecoys()
+	previousGeneration := a.config.GetGeneration()
+	a.overrideDecoys(decoys, gen)
+	return previousDecoys, previousGeneration
+}
+
+// overrideDecoys - See OverrideDecoys. Caller must hold the lock.
+func (a *assets) overrideDecoys(decoys []*pb.TLSDecoySpec, gen uint32) {
+	conf := &pb.ClientConf{}
+	if a.config != nil {
+		conf = proto.Clone(a.config).(*pb.ClientConf)
+	}
+	conf.DecoyList = &pb.DecoyList{TlsDecoys: decoys}
+	conf.Generation = &gen
+	a.config = conf
+}
+
 // Overwrite currently used decoys and store config to disk
 func (a *assets) SetDecoys(decoys []*pb.TLSDecoySpec) (err error) {
 	a.Lock()
@@ -354,6 +593,77 @@ func (a *assets) SetDecoys(decoys []*pb.TLSDecoySpec) (err error) {
 	return
 }
 
+// decoyListEntry - a decoy as described in a user-supplied decoy list
+type decoyListEntry struct {
+	SNI    string `json:"sni"`
+	IP     string `json:"ip"`
+	V6IP   string `json:"v6ip"`
+	Weight int    `json:"weight"`
+	NoSNI  bool   `json:"no_sni"`
+}
+
+// toDecoySpec - validate the entry and convert it into a TLSDecoySpec
+func (e *decoyListEntry) toDecoySpec() (*pb.TLSDecoySpec, error) {
+	if e.SNI == "" && !e.NoSNI {
+		return nil, errors.New("sni is empty and no_sni is not set")
+	}
+	if e.IP == "" && e.V6IP == "" {
+		return nil, errors.New("neither ip nor v6ip is set")
+	}
+
+	decoy := &pb.TLSDecoySpec{Hostname: &e.SNI}
+	if e.NoSNI {
+		decoy.NoSni = proto.Bool(true)
+	}
+	if e.IP != "" {
+		ip := net.ParseIP(e.IP).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("ip \"%s\" is not a valid IPv4 address", e.IP)
+		}
+		ipv4 := binary.BigEndian.Uint32(ip)
+		decoy.Ipv4Addr = &ipv4
+	}
+	if e.V6IP != "" {
+		ip := net.ParseIP(e.V6IP)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("v6ip \"%s\" is not a valid IPv6 address", e.V6IP)
+		}
+		decoy.Ipv6Addr = ip.To16()
+	}
+	return decoy, nil
+}
+
+// LoadDecoyList reads a JSON list of {"sni", "ip", "v6ip", "weight", "no_sni"} entries and uses
+// them as the current decoys with max generation, so the station won't send a
+// ClientConf overriding them. The list is not stored to disk.
+// Malformed entries are skipped with a warning. An entry with weight N is listed
+// N times, making it proportionally more likely to be selected.
+func (a *assets) LoadDecoyList(r io.Reader) error {
+	var entries []decoyListEntry
+	err := json.NewDecoder(r).Decode(&entries)
+	if err != nil {
+		return fmt.Errorf("failed to parse decoy list: %v", err)
+	}
+
+	var decoys []*pb.TLSDecoySpec
+	for i := range entries {
+		decoy, err := entries[i].toDecoySpec()
+		if err != nil {
+			Logger().Warnf("Assets: skipping decoy list entry %d: %v", i, err)
+			continue
+		}
+		for w := 0; w < maxInt(entries[i].Weight, 1); w++ {
+			decoys = append(decoys, decoy)
+		}
+	}
+	if len(decoys) == 0 {
+		return errors.New("no valid decoys in decoy list")
+	}
+
+	a.OverrideDecoys(decoys, ^uint32(0))
+	return nil
+}
+
 // Checks if decoy is in currently used ClientConf decoys list
 func (a *assets) IsDecoyInList(decoy *pb.TLSDecoySpec) bool {
 	ipv4str := decoy.GetIpAddrStr()
@@ -386,9 +696,21 @@ func (a *assets) saveClientConf() error {
 
 // SetStatsSocksAddr - Provide a socks address for reporting stats from the client in the form "addr:port"
 func (a *assets) SetStatsSocksAddr(addr string) {
+	a.Lock()
+	defer a.Unlock()
+
 	a.socksAddr = addr
 }
 
+// GetPhantomSelectorVersion - Get the phantom selection algorithm version matching the
+// generation of the ClientConf providing the phantom subnets.
+func (a *assets) GetPhantomSelectorVersion() uint {
+	a.RLock()
+	defer a.RUnlock()
+
+	return PhantomSelectorVersion(a.phantomsGeneration)
+}
+
 // GetPhantomSubnets -
 func (a *assets) GetPhantomSubnets() *pb.PhantomSubnetsList {
 	a.RLock()
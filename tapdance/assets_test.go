@@ -3,11 +3,14 @@ package tapdance
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -187,3 +190,257 @@ func TestAssets_Pubkey(t *testing.T) {
 	os.Remove(dir2)
 	AssetsSetDir(oldpath)
 }
+
+func TestAssets_Reload(t *testing.T) {
+	oldpath := Assets().path
+	defer AssetsSetDir(oldpath)
+
+	writeClientConf := func(dir string, generation uint32, decoys []*pb.TLSDecoySpec) {
+		conf := &pb.ClientConf{
+			Generation: &generation,
+			DecoyList:  &pb.DecoyList{TlsDecoys: decoys},
+		}
+		buf, err := proto.Marshal(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path.Join(dir, "ClientConf"), buf, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	writeClientConf(dir, 5, []*pb.TLSDecoySpec{pb.InitTLSDecoySpec("4.8.15.16", "ericw.us")})
+	AssetsSetDir(dir)
+	if Assets().GetGeneration() != 5 {
+		t.Fatalf("Expected generation 5, got %v", Assets().GetGeneration())
+	}
+
+	// newer generation is swapped in
+	writeClientConf(dir, 6, []*pb.TLSDecoySpec{pb.InitTLSDecoySpec("11.22.33.44", "what.is.up")})
+	reloaded, err := Assets().Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded || Assets().GetGeneration() != 6 {
+		t.Fatalf("Expected generation 6 to be reloaded, got %v", Assets().GetGeneration())
+	}
+	if !Assets().IsDecoyInList(pb.InitTLSDecoySpec("11.22.33.44", "what.is.up")) {
+		t.Fatal("Decoy 11.22.33.44(what.is.up) is NOT in Decoy List!")
+	}
+
+	// older generation is ignored
+	writeClientConf(dir, 4, []*pb.TLSDecoySpec{pb.InitTLSDecoySpec("4.8.15.16", "ericw.us")})
+	reloaded, err = Assets().Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded || Assets().GetGeneration() != 6 {
+		t.Fatalf("Expected generation 6 to be kept, got %v", Assets().GetGeneration())
+	}
+	if Assets().IsDecoyInList(pb.InitTLSDecoySpec("4.8.15.16", "ericw.us")) {
+		t.Fatal("Decoy 4.8.15.16(ericw.us) is in Decoy List!")
+	}
+}
+
+// Run with -race: readers during registration must not race with ClientConf swaps.
+func TestAssets_ConcurrentSwap(t *testing.T) {
+	oldpath := Assets().path
+	defer AssetsSetDir(oldpath)
+
+	dir := t.TempDir()
+	AssetsSetDir(dir)
+
+	decoysA := []*pb.TLSDecoySpec{pb.InitTLSDecoySpec("4.8.15.16", "ericw.us")}
+	decoysB := []*pb.TLSDecoySpec{
+		pb.InitTLSDecoySpec("11.22.33.44", "what.is.up"),
+		pb.InitTLSDecoySpec("2001:48a8:687f:1::105", "tapdance1.freeaeskey.xyz"),
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, d := range Assets().GetAllDecoys() {
+					_ = d.GetHostname()
+				}
+				_ = Assets().GetDecoy().GetTimeout()
+				_ = Assets().GetV6Decoy().GetIpv6Addr()
+				_ = Assets().GetV4Decoys()
+				_ = Assets().GetGeneration()
+				_ = Assets().GetPhantomSubnets()
+				_ = Assets().GetConjurePubkey()
+			}
+		}()
+	}
+
+	for gen := uint32(1); gen <= 200; gen++ {
+		if gen%2 == 0 {
+			Assets().OverrideDecoys(decoysA, gen)
+		} else {
+			Assets().OverrideDecoys(decoysB, gen)
+		}
+	}
+	err := Assets().SetDecoys(decoysA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+
+	if Assets().GetGeneration() != 200 {
+		t.Fatalf("Expected generation 200, got %v", Assets().GetGeneration())
+	}
+	if !Assets().IsDecoyInList(decoysA[0]) {
+		t.Fatal("Decoy 4.8.15.16(ericw.us) is NOT in Decoy List!")
+	}
+}
+
+func TestAssets_LoadDecoyList(t *testing.T) {
+	oldpath := Assets().path
+	defer AssetsSetDir(oldpath)
+	AssetsSetDir(t.TempDir())
+
+	decoyList := `[
+		{"sni": "ericw.us", "ip": "4.8.15.16"},
+		{"sni": "what.is.up", "ip": "11.22.33.44", "v6ip": "2001:48a8:687f:1::105", "weight": 2},
+		{"sni": "v6only.xyz", "v6ip": "2001:48a8:687f:1::106"},
+		{"sni": "bad.ip", "ip": "1.2.3"},
+		{"sni": "v4.in.v6", "v6ip": "1.2.3.4"},
+		{"ip": "1.2.3.4"},
+		{"sni": "no.ip"}
+	]`
+	err := Assets().LoadDecoyList(strings.NewReader(decoyList))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Assets().GetGeneration() != ^uint32(0) {
+		t.Fatalf("Expected max generation, got %v", Assets().GetGeneration())
+	}
+	if len(Assets().GetAllDecoys()) != 4 {
+		t.Fatalf("Expected 4 decoys (one weighted twice), got %v", len(Assets().GetAllDecoys()))
+	}
+	if len(Assets().GetV6Decoys()) != 3 || len(Assets().GetV4Decoys()) != 3 {
+		t.Fatalf("Expected 3 v6 and 3 v4 decoys, got %v and %v",
+			len(Assets().GetV6Decoys()), len(Assets().GetV4Decoys()))
+	}
+	if !Assets().IsDecoyInList(pb.InitTLSDecoySpec("4.8.15.16", "ericw.us")) {
+		t.Fatal("Decoy 4.8.15.16(ericw.us) is NOT in Decoy List!")
+	}
+	if !Assets().IsDecoyInList(pb.InitTLSDecoySpec("2001:48a8:687f:1::106", "v6only.xyz")) {
+		t.Fatal("Decoy 2001:48a8:687f:1::106(v6only.xyz) is NOT in Decoy List!")
+	}
+	if Assets().IsDecoyInList(pb.InitTLSDecoySpec("1.2.3.4", "v4.in.v6")) {
+		t.Fatal("Decoy 1.2.3.4(v4.in.v6) is in Decoy List!")
+	}
+
+	err = Assets().LoadDecoyList(strings.NewReader(`[{"sni": "no.ip"}]`))
+	if err == nil {
+		t.Fatal("Expected error loading decoy list without valid decoys")
+	}
+	err = Assets().LoadDecoyList(strings.NewReader(`{"sni": "not.a.list"}`))
+	if err == nil {
+		t.Fatal("Expected error loading malformed decoy list")
+	}
+	if len(Assets().GetAllDecoys()) != 4 {
+		t.Fatalf("Expected failed loads to keep 4 decoys, got %v", len(Assets().GetAllDecoys()))
+	}
+
+	err = Assets().LoadDecoyList(strings.NewReader(`[{"ip": "5.6.7.8", "no_sni": true}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoys := Assets().GetAllDecoys()
+	if len(decoys) != 1 || decoys[0].GetHostname() != "" || !decoys[0].GetNoSni() {
+		t.Fatalf("Expected a single SNI-less decoy marked no_sni, got %v", decoys)
+	}
+}
+
+func TestAssets_SetDirErrors(t *testing.T) {
+	oldpath := Assets().path
+	defer AssetsSetDir(oldpath)
+
+	_, err := AssetsSetDir(path.Join(t.TempDir(), "missing"))
+	if !errors.Is(err, ErrAssetsDirMissing) {
+		t.Fatalf("Expected ErrAssetsDirMissing for a missing directory, got %v", err)
+	}
+
+	file := path.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = AssetsSetDir(file)
+	if !errors.Is(err, ErrAssetsDirMissing) {
+		t.Fatalf("Expected ErrAssetsDirMissing for a file, got %v", err)
+	}
+
+	_, err = AssetsSetDir(t.TempDir())
+	if !errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrAssetsDirMissing) {
+		t.Fatalf("Expected a missing ClientConf error, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(path.Join(dir, "ClientConf"), []byte("not a ClientConf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = AssetsSetDir(dir)
+	if !errors.Is(err, ErrClientConfInvalid) {
+		t.Fatalf("Expected ErrClientConfInvalid, got %v", err)
+	}
+}
+
+func TestAssets_DisableClientConfUpdates(t *testing.T) {
+	gen := Assets().GetGeneration()
+	if Assets().getReportedGeneration(false) != gen {
+		t.Fatalf("Expected generation %v to be reported, got %v", gen, Assets().getReportedGeneration(false))
+	}
+
+	Assets().DisableClientConfUpdates(true)
+	defer Assets().DisableClientConfUpdates(false)
+	if Assets().getReportedGeneration(false) != ^uint32(0) {
+		t.Fatalf("Expected the max generation to be reported, got %v", Assets().getReportedGeneration(false))
+	}
+	if Assets().GetGeneration() != gen {
+		t.Fatalf("Expected generation %v to be kept, got %v", gen, Assets().GetGeneration())
+	}
+
+	reg := &ConjureReg{}
+	if reg.generateClientToStation().GetDecoyListGeneration() != ^uint32(0) {
+		t.Fatalf("Expected registrations to report the max generation")
+	}
+}
+
+func TestAssets_PinClientConf(t *testing.T) {
+	gen := Assets().GetGeneration()
+	if Assets().getReportedGeneration(true) != ^uint32(0) {
+		t.Fatalf("Expected the max generation to be reported, got %v", Assets().getReportedGeneration(true))
+	}
+
+	// only the registrations of the pinning Dialer report the max generation
+	pinned := Dialer{DarkDecoy: true, PinClientConf: true}
+	cjSession, err := pinned.makeConjureSession("1.2.3.4:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &ConjureReg{pinClientConf: cjSession.PinClientConf}
+	if reg.generateClientToStation().GetDecoyListGeneration() != ^uint32(0) {
+		t.Fatalf("Expected pinned registrations to report the max generation")
+	}
+	reg = &ConjureReg{}
+	if reg.generateClientToStation().GetDecoyListGeneration() != gen {
+		t.Fatalf("Expected other registrations to report generation %v", gen)
+	}
+	if Assets().ClientConfUpdatesDisabled() {
+		t.Fatalf("Expected ClientConf updates to stay enabled")
+	}
+}
@@ -0,0 +1,148 @@
+package tapdance
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+// Benchmarks of the registration path against in-process decoys and phantoms, to
+// quantify changes affecting registration throughput and connection latency, e.g.
+//	go test ./tapdance -run NONE -bench 'Register|SelectDecoys|SharedKeys|PhantomConnect'
+
+// benchDecoy - Start an in-process decoy, a TLS server with a certificate for
+// example.com, and get a session dialer to it
+func benchDecoy(b testing.TB) func(context.Context, string, string) (net.Conn, error) {
+	decoy := httptest.NewTLSServer(http.NotFoundHandler())
+	b.Cleanup(decoy.Close)
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, decoy.Listener.Addr().String())
+	}
+}
+
+func BenchmarkRegister(b *testing.B) {
+	AssetsSetDir("./assets")
+	decoyDialer := benchDecoy(b)
+
+	for _, width := range []uint{1, 5, 20} {
+		for _, parallel := range []int{1, 8} {
+			b.Run(fmt.Sprintf("width=%d/parallel=%d", width, parallel), func(b *testing.B) {
+				b.SetParallelism(parallel)
+				b.ResetTimer()
+				start := time.Now()
+				b.RunParallel(func(p *testing.PB) {
+					for p.Next() {
+						benchRegister(b, width, decoyDialer)
+					}
+				})
+				b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "regs/s")
+			})
+		}
+	}
+}
+
+// benchRegister - Register through width in-process decoys, until every decoy
+// registration is sent
+func benchRegister(b *testing.B, width uint, decoyDialer func(context.Context, string, string) (net.Conn, error)) {
+	session := makeTestSession(b, "1.2.3.4:1234")
+	session.Width = width
+	session.NoRegistrationSleep = true
+	session.DecoyDialer = decoyDialer
+	session.DecoySelector = func(*ConjureSession, []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+		decoys := make([]*pb.TLSDecoySpec, width)
+		for i := range decoys {
+			decoys[i] = pb.InitTLSDecoySpec("127.0.0.1", "example.com")
+		}
+		return decoys, nil
+	}
+
+	reg, err := DecoyRegistrar{}.Register(session, context.Background())
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	reg.sends.Wait()
+	if !reg.anyDecoySucceeded() {
+		b.Error("no decoy registration succeeded")
+	}
+}
+
+func BenchmarkSelectDecoys(b *testing.B) {
+	AssetsSetDir("./assets")
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	require.Nil(b, err)
+
+	for _, width := range []uint{1, 5, 20} {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := SelectDecoys(seed, both, width); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateSharedKeys(b *testing.B) {
+	var pubkey [32]byte
+	_, err := rand.Read(pubkey[:])
+	require.Nil(b, err)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := generateSharedKeys(pubkey, nil, DefaultKeyLengths); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPhantomConnect - Latency of Connect with the min transport to an
+// in-process phantom, from dialing to the connect tag being sent
+func BenchmarkPhantomConnect(b *testing.B) {
+	AssetsSetDir("./assets")
+	phantom, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(b, err)
+	defer phantom.Close()
+	go func() {
+		for {
+			conn, err := phantom.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	session := makeTestSession(b, "1.2.3.4:1234")
+	session.V6Support = &V6{include: v4, fixed: true}
+	session.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, phantom.Addr().String())
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := reg.Connect(context.Background())
+		if err != nil {
+			b.Fatal(err)
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,68 @@
+package tapdance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// bootstrapGeneration - ClientConf generation reported while bootstrapping, lower
+// than that of any ClientConf a station sends
+const bootstrapGeneration = 0
+
+// Bootstrap - Fetch a fresh ClientConf from the station behind decoy, a single
+// known-good decoy, and apply it in place of the assets ClientConf, e.g. when the
+// assets decoy list is stale or empty and registrations cannot proceed.
+//
+// Conjure registrations are one-way, so the ClientConf is requested with a TapDance
+// handshake through decoy: stations reply to handshakes with their ClientConf when
+// the generation reported by the client is lower than theirs. The decoy is used
+// with generation 0 while bootstrapping, so any ClientConf is sent and applied; it
+// is then stored in the assets directory and used by subsequent dials. On failure,
+// the previous decoys and generation are restored.
+//
+// Overriding the decoys with the maximum generation instead, as for a static single
+// decoy, keeps stations from ever sending a ClientConf. For the same reason,
+// Bootstrap fails while ClientConf updates are disabled.
+func Bootstrap(ctx context.Context, decoy *pb.TLSDecoySpec) error {
+	if Assets().ClientConfUpdatesDisabled() {
+		return errors.New("cannot bootstrap while ClientConf updates are disabled")
+	}
+
+	previousDecoys, previousGeneration := Assets().swapDecoys([]*pb.TLSDecoySpec{decoy}, bootstrapGeneration)
+
+	err := bootstrap(ctx, decoy)
+	if err != nil {
+		Assets().swapDecoys(previousDecoys, previousGeneration)
+		return err
+	}
+	Logger().Infof("Bootstrapped ClientConf generation %v with %v decoys from decoy %v (%v)",
+		Assets().GetGeneration(), len(Assets().GetAllDecoys()), decoy.GetHostname(), decoy.GetIpAddrStr())
+	return nil
+}
+
+// bootstrap - Handshake with the station behind decoy, applying the ClientConf it
+// replies with
+func bootstrap(ctx context.Context, decoy *pb.TLSDecoySpec) error {
+	stationPubkey := Assets().GetPubkey()
+	tdRaw := makeTdRaw(tagHttpGetIncomplete, stationPubkey[:])
+	tdRaw.decoySpec = decoy
+	tdRaw.pinDecoySpec = true
+	tdRaw.sessionId = sessionsTotal.GetAndInc()
+
+	flowConn, err := makeTdFlow(flowRendezvous, tdRaw, "")
+	if err != nil {
+		return err
+	}
+	defer flowConn.Close()
+	err = flowConn.DialContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap from decoy %v (%v): %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err)
+	}
+	if Assets().GetGeneration() == bootstrapGeneration {
+		return fmt.Errorf("station behind decoy %v (%v) sent no ClientConf", decoy.GetHostname(), decoy.GetIpAddrStr())
+	}
+	return nil
+}
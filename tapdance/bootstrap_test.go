@@ -0,0 +1,40 @@
+package tapdance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapFailure(t *testing.T) {
+	AssetsSetDir("./assets")
+	defer AssetsSetDir("./assets")
+	decoys := Assets().GetAllDecoys()
+	generation := Assets().GetGeneration()
+
+	// a failed bootstrap restores the decoys and generation
+	unreachable := pb.InitTLSDecoySpec("127.0.0.1", "example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err := Bootstrap(ctx, unreachable)
+	require.NotNil(t, err)
+	require.Equal(t, decoys, Assets().GetAllDecoys())
+	require.Equal(t, generation, Assets().GetGeneration())
+
+	// the bootstrap decoy is swapped in and out atomically
+	previousDecoys, previousGeneration := Assets().swapDecoys([]*pb.TLSDecoySpec{unreachable}, bootstrapGeneration)
+	require.Equal(t, decoys, previousDecoys)
+	require.Equal(t, generation, previousGeneration)
+	require.Equal(t, []*pb.TLSDecoySpec{unreachable}, Assets().GetAllDecoys())
+	Assets().swapDecoys(previousDecoys, previousGeneration)
+	require.Equal(t, decoys, Assets().GetAllDecoys())
+	require.Equal(t, generation, Assets().GetGeneration())
+
+	Assets().DisableClientConfUpdates(true)
+	defer Assets().DisableClientConfUpdates(false)
+	err = Bootstrap(context.Background(), unreachable)
+	require.EqualError(t, err, "cannot bootstrap while ClientConf updates are disabled")
+}
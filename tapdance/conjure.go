@@ -1,19 +1,28 @@
 package tapdance
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"net/http"
+	"os"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	pt "git.torproject.org/pluggable-transports/goptlib.git"
@@ -22,15 +31,16 @@ import (
 	ps "github.com/dimuls/gotapdance/tapdance/phantoms"
 	tls "github.com/refraction-networking/utls"
 	"gitlab.com/yawning/obfs4.git/common/ntor"
-	"gitlab.com/yawning/obfs4.git/transports/obfs4"
 	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
+	"golang.org/x/net/http2"
 )
 
 // V6 - Struct to track V6 support and cache result across sessions
 type V6 struct {
 	support bool
 	include uint
+	fixed   bool // IP versions set explicitly, see WithIPVersions, not probed
 }
 
 // Registrar defines the interface for a service executing
@@ -47,36 +57,43 @@ type DecoyRegistrar struct {
 }
 
 func (r DecoyRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
-	Logger().Debugf("%v Registering V4 and V6 via DecoyRegistrar", cjSession.IDString())
+	cjSession.logger().Debugf("%v Registering V4 and V6 via DecoyRegistrar", cjSession.IDString())
 
 	// Choose N (width) decoys from decoylist
-	decoys, err := SelectDecoys(cjSession.Keys.SharedSecret, cjSession.V6Support.include, cjSession.Width)
+	var decoys []*pb.TLSDecoySpec
+	var err error
+	if cjSession.RotateDecoys {
+		decoys, err = selectDecoysOffset(cjSession.Keys.SharedSecret, cjSession.V6Support.include, cjSession.Width, cjSession.getDecoyRotation())
+	} else {
+		decoys, err = SelectDecoys(cjSession.Keys.SharedSecret, cjSession.V6Support.include, cjSession.Width)
+	}
 	if err != nil {
-		Logger().Warnf("%v failed to select decoys: %v", cjSession.IDString(), err)
+		cjSession.logger().Warnf("%v failed to select decoys: %v", cjSession.IDString(), err)
 		return nil, err
 	}
+	if len(decoys) == 0 {
+		msg := fmt.Sprintf("no decoys for requested IP version (%v)", v6SupportStr(cjSession.V6Support.include))
+		cjSession.logger().Warnf("%v %v", cjSession.IDString(), msg)
+		return nil, &RegError{msg: msg, code: NoDecoys}
+	}
+	if cjSession.DecoySelector != nil {
+		decoys, err = cjSession.DecoySelector(cjSession, decoys)
+		if err != nil {
+			cjSession.logger().Warnf("%v decoy selector failed: %v", cjSession.IDString(), err)
+			return nil, err
+		}
+		if len(decoys) == 0 {
+			return nil, fmt.Errorf("decoy selector returned no decoys")
+		}
+	}
 	cjSession.RegDecoys = decoys
 
-	phantom4, phantom6, err := SelectPhantom(cjSession.Keys.ConjureSeed, cjSession.V6Support.include)
+	//[reference] Prepare registration
+	reg, err := cjSession.newConjureReg()
 	if err != nil {
-		Logger().Warnf("%v failed to select Phantom: %v", cjSession.IDString(), err)
 		return nil, err
 	}
 
-	//[reference] Prepare registration
-	reg := &ConjureReg{
-		sessionIDStr:   cjSession.IDString(),
-		keys:           cjSession.Keys,
-		stats:          &pb.SessionStats{},
-		phantom4:       phantom4,
-		phantom6:       phantom6,
-		v6Support:      cjSession.V6Support.include,
-		covertAddress:  cjSession.CovertAddress,
-		transport:      cjSession.Transport,
-		TcpDialer:      cjSession.TcpDialer,
-		useProxyHeader: cjSession.UseProxyHeader,
-	}
-
 	if r.TcpDialer != nil {
 		reg.TcpDialer = r.TcpDialer
 	}
@@ -88,57 +105,108 @@ func (r DecoyRegistrar) Register(cjSession *ConjureSession, ctx context.Context)
 
 	width := uint(len(cjSession.RegDecoys))
 	if width < cjSession.Width {
-		Logger().Warnf("%v Using width %v (default %v)", cjSession.IDString(), width, cjSession.Width)
+		cjSession.logger().Warnf("%v Using width %v (default %v)", cjSession.IDString(), width, cjSession.Width)
 	}
 
-	Logger().Debugf("%v Registration - v6:%v, covert:%v, phantoms:%v,[%v], width:%v, transport:%v",
+	cjSession.logger().Debugf("%v Registration - v6:%v, covert:%v, phantoms:%v,[%v], width:%v, transport:%v",
 		reg.sessionIDStr,
 		reg.v6SupportStr(),
 		reg.covertAddress,
-		reg.phantom4.String(),
-		reg.phantom6.String(),
+		reg.phantom4,
+		reg.phantom6,
 		cjSession.Width,
 		cjSession.Transport,
 	)
+	if cjSession.LogSelection {
+		cjSession.logger().Infof("%v %v", reg.sessionIDStr, reg.selectionSummary(cjSession.RegDecoys))
+	}
 
-	//[reference] Send registrations to each decoy
+	//[reference] Send registrations to each decoy, stopped early if the session is closed
+	sendCtx, sendCancel := cjSession.withSessionClose(ctx)
+	var budgetDeadline time.Time
+	if cjSession.RegistrationTimeout > 0 {
+		budgetDeadline = time.Now().Add(cjSession.RegistrationTimeout)
+		timeoutCtx, timeoutCancel := context.WithDeadline(sendCtx, budgetDeadline)
+		closeCancel := sendCancel
+		sendCtx, sendCancel = timeoutCtx, func() { timeoutCancel(); closeCancel() }
+	}
 	dialErrors := make(chan error, width)
+	reg.sends.Add(len(cjSession.RegDecoys))
+	// sendCtx is only released once every send returns, including the lingering
+	// reads on decoy connections after the registrations are sent.
+	var sending sync.WaitGroup
+	sending.Add(len(cjSession.RegDecoys))
 	for _, decoy := range cjSession.RegDecoys {
-		Logger().Debugf("%v Sending Reg: %v, %v", cjSession.IDString(), decoy.GetHostname(), decoy.GetIpAddrStr())
+		cjSession.logger().Debugf("%v Sending Reg: %v, %v", cjSession.IDString(), decoy.GetHostname(), decoy.GetIpAddrStr())
 		//decoyAddr := decoy.GetIpAddrStr()
-		go reg.send(ctx, decoy, dialErrors, cjSession.registrationCallback)
-	}
-
-	//[reference] Dial errors happen immediately so block until all N dials complete
-	var unreachableCount uint = 0
-	for err := range dialErrors {
-		if err != nil {
-			Logger().Debugf("%v %v", cjSession.IDString(), err)
-			if dialErr, ok := err.(RegError); ok && dialErr.code == Unreachable {
-				// If we failed because ipv6 network was unreachable try v4 only.
-				unreachableCount++
-				if unreachableCount < width {
-					continue
-				} else {
-					break
-				}
-			}
+		go func(decoy *pb.TLSDecoySpec) {
+			defer sending.Done()
+			reg.send(sendCtx, decoy, dialErrors, cjSession.registrationCallback)
+		}(decoy)
+	}
+	go func() {
+		sending.Wait()
+		sendCancel()
+	}()
+
+	//[reference] Dial errors happen immediately so block until a registration is sent or all N sends failed
+	var unreachableCount, failedCount uint
+	var lastErr error
+	for failedCount < width {
+		err := <-dialErrors
+		if err == nil {
+			//[reference] if a registration was sent then the network is reachable and we can continue
+			break
+		}
+		cjSession.logger().Debugf("%v %v", cjSession.IDString(), err)
+		if dialErr, ok := err.(*RegError); ok && dialErr.code == Unreachable {
+			// If we failed because ipv6 network was unreachable try v4 only.
+			unreachableCount++
 		}
-		//[reference] if we succeed or fail for any other reason then the network is reachable and we can continue
-		break
+		failedCount++
+		lastErr = err
 	}
 
 	//[reference] if ALL fail to dial return error (retry in parent if ipv6 unreachable)
 	if unreachableCount == width {
-		Logger().Debugf("%v NETWORK UNREACHABLE", cjSession.IDString())
+		cjSession.logger().Debugf("%v NETWORK UNREACHABLE", cjSession.IDString())
 		return nil, &RegError{code: Unreachable, msg: "All decoys failed to register -- Dial Unreachable"}
 	}
 
+	//[reference] fail fast if the registration budget ran out before any decoy accepted.
+	// Handshakes share the budget deadline and may fail on it before sendCtx is done.
+	budgetSpent := sendCtx.Err() == context.DeadlineExceeded ||
+		(!budgetDeadline.IsZero() && !time.Now().Before(budgetDeadline))
+	if budgetSpent && ctx.Err() == nil && !reg.anyDecoySucceeded() {
+		cjSession.logger().Debugf("%v REGISTRATION TIMEOUT", cjSession.IDString())
+		return nil, &RegError{code: RegistrationTimeout, msg: fmt.Sprintf("No decoy registration completed within %v", cjSession.RegistrationTimeout)}
+	}
+
+	//[reference] if no decoy took the registration, e.g. all TLS handshakes failed or ctx
+	// was cancelled, there is no registration to connect with. The error carries the
+	// registration for the outcome of each decoy, see RegisterOnly.
+	if failedCount == width {
+		if cjSession.isClosed() {
+			return nil, errSessionClosed
+		}
+		cjSession.logger().Debugf("%v NO REGISTRATION SENT", cjSession.IDString())
+		return nil, &RegError{code: NoRegistrationSent, err: lastErr, reg: reg,
+			msg: fmt.Sprintf("All %v decoys failed to register, last error: %v", width, lastErr)}
+	}
+
 	// randomized sleeping here to break the intraflow signal
-	toSleep := reg.getRandomDuration(3000, 212, 3449)
-	Logger().Debugf("%v Successfully sent registrations, sleeping for: %v", cjSession.IDString(), toSleep)
-	sleepWithContext(ctx, toSleep)
+	toSleep := reg.getTimings().RegistrationSleep.Duration(reg.getTcpToDecoy())
+	if cjSession.NoRegistrationSleep {
+		toSleep = 0
+	}
+	cjSession.logger().Debugf("%v Successfully sent registrations, sleeping for: %v", cjSession.IDString(), toSleep)
+	sleepCtx, sleepCancel := cjSession.withSessionClose(ctx)
+	sleepWithContext(sleepCtx, toSleep)
+	sleepCancel()
 
+	if cjSession.isClosed() {
+		return nil, errSessionClosed
+	}
 	return reg, nil
 }
 
@@ -150,17 +218,35 @@ type APIRegistrar struct {
 	// Endpoint to use in registration request
 	Endpoint string
 
+	// Endpoints are further registration endpoints, e.g. redundant API servers,
+	// tried in turn after Endpoint when it fails, in order or, if
+	// RandomizeEndpoints is set, in random order. The endpoint that succeeded is
+	// recorded in the registration, see ConjureReg.APIEndpoint.
+	Endpoints          []string
+	RandomizeEndpoints bool
+
 	// HTTP client to use in request
 	Client *http.Client
 
 	// Length of time to delay after confirming successful
 	// registration before attempting a connection,
 	// allowing for propagation throughout the stations.
-	ConnectionDelay time.Duration
-
-	// Maximum number of retries before giving up
+	//
+	// If ConnectionDelayMax is above ConnectionDelay, the delay is
+	// random between the two instead, so that the time from
+	// registration to connection is not a constant to fingerprint.
+	ConnectionDelay    time.Duration
+	ConnectionDelayMax time.Duration
+
+	// Maximum number of retries of each endpoint before trying the next one.
+	// Registrations rejected by an endpoint are not retried on it, see
+	// APIRegistrationError.Temporary.
 	MaxRetries int
 
+	// Delay before the first retry of an endpoint, doubling for each further retry.
+	// Zero uses defaultAPIRetryBackoff, a negative value retries immediately.
+	RetryBackoff time.Duration
+
 	// A secondary registration method to use on failure.
 	// Because the API registration can give us definite
 	// indication of a failure to register, this can be
@@ -169,32 +255,45 @@ type APIRegistrar struct {
 	//
 	// If this field is nil, no secondary registration will
 	// be attempted. If it is non-nil, after failing to register
-	// (retrying MaxRetries times) we will fall back to
+	// (retrying MaxRetries times on every endpoint) we will fall back to
 	// the Register method on this field.
 	SecondaryRegistrar Registrar
 }
 
-func (r APIRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
-	Logger().Debugf("%v registering via APIRegistrar", cjSession.IDString())
-	// TODO: this section is duplicated from DecoyRegistrar; consider consolidating
-	phantom4, phantom6, err := SelectPhantom(cjSession.Keys.ConjureSeed, cjSession.V6Support.include)
-	if err != nil {
-		Logger().Warnf("%v failed to select Phantom: %v", cjSession.IDString(), err)
-		return nil, err
+// defaultAPIRetryBackoff - delay before the first retry of a registration endpoint
+const defaultAPIRetryBackoff = 250 * time.Millisecond
+
+// connectionDelay - Get a delay between ConnectionDelay and ConnectionDelayMax
+func (r APIRegistrar) connectionDelay() time.Duration {
+	if r.ConnectionDelayMax <= r.ConnectionDelay {
+		return r.ConnectionDelay
+	}
+	return r.ConnectionDelay + time.Duration(getRandInt(0, int(r.ConnectionDelayMax-r.ConnectionDelay)))
+}
+
+// endpoints - Get the registration endpoints in the order they are tried
+func (r APIRegistrar) endpoints() []string {
+	var endpoints []string
+	if r.Endpoint != "" {
+		endpoints = append(endpoints, r.Endpoint)
+	}
+	endpoints = append(endpoints, r.Endpoints...)
+	if r.RandomizeEndpoints {
+		for i := len(endpoints) - 1; i > 0; i-- {
+			j := getRandInt(0, i)
+			endpoints[i], endpoints[j] = endpoints[j], endpoints[i]
+		}
 	}
+	return endpoints
+}
+
+func (r APIRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
+	cjSession.logger().Debugf("%v registering via APIRegistrar", cjSession.IDString())
 
 	// [reference] Prepare registration
-	reg := &ConjureReg{
-		sessionIDStr:   cjSession.IDString(),
-		keys:           cjSession.Keys,
-		stats:          &pb.SessionStats{},
-		phantom4:       phantom4,
-		phantom6:       phantom6,
-		v6Support:      cjSession.V6Support.include,
-		covertAddress:  cjSession.CovertAddress,
-		transport:      cjSession.Transport,
-		TcpDialer:      cjSession.TcpDialer,
-		useProxyHeader: cjSession.UseProxyHeader,
+	reg, err := cjSession.newConjureReg()
+	if err != nil {
+		return nil, err
 	}
 
 	c2s := reg.generateClientToStation()
@@ -206,7 +305,7 @@ func (r APIRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (
 
 	payload, err := proto.Marshal(&protoPayload)
 	if err != nil {
-		Logger().Warnf("%v failed to marshal ClientToStation payload: %v", cjSession.IDString(), err)
+		cjSession.logger().Warnf("%v failed to marshal ClientToStation payload: %v", cjSession.IDString(), err)
 		return nil, err
 	}
 
@@ -220,54 +319,113 @@ func (r APIRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (
 		r.Client = &http.Client{Transport: t}
 	}
 
-	tries := 0
-	for tries < r.MaxRetries+1 {
-		tries++
-		err = r.executeHTTPRequest(ctx, cjSession, payload)
-		if err == nil {
-			Logger().Debugf("%v API registration succeeded", cjSession.IDString())
-			if r.ConnectionDelay != 0 {
-				Logger().Debugf("%v sleeping for %v", cjSession.IDString(), r.ConnectionDelay)
-				sleepWithContext(ctx, r.ConnectionDelay)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	endpoints := r.endpoints()
+	if len(endpoints) == 0 {
+		err = errors.New("no API registration endpoint")
+	}
+tryEndpoints:
+	for _, endpoint := range endpoints {
+		backoff := r.RetryBackoff
+		if backoff == 0 {
+			backoff = defaultAPIRetryBackoff
+		}
+		for tries := 1; tries <= r.MaxRetries+1; tries++ {
+			if tries > 1 && backoff > 0 {
+				sleepWithContext(ctx, backoff)
+				backoff *= 2
+			}
+			err = r.executeHTTPRequest(ctx, cjSession, endpoint, payload)
+			if err == nil {
+				cjSession.logger().Debugf("%v API registration succeeded on %v", cjSession.IDString(), endpoint)
+				reg.m.Lock()
+				reg.apiEndpoint = endpoint
+				reg.m.Unlock()
+				if delay := r.connectionDelay(); delay != 0 {
+					cjSession.logger().Debugf("%v sleeping for %v", cjSession.IDString(), delay)
+					sleepWithContext(ctx, delay)
+				}
+				return reg, nil
+			}
+			cjSession.logger().Warnf("%v failed API registration on %v, attempt %d/%d", cjSession.IDString(), endpoint, tries, r.MaxRetries+1)
+			if ctx.Err() != nil {
+				break tryEndpoints
+			}
+			var apiErr APIRegistrationError
+			if errors.As(err, &apiErr) && !apiErr.Temporary() {
+				// rejected, retrying the same registration will not help
+				break
 			}
-			return reg, nil
 		}
-		Logger().Warnf("%v failed API registration, attempt %d/%d", cjSession.IDString(), tries, r.MaxRetries+1)
 	}
 
 	// If we make it here, we failed API registration
-	Logger().Warnf("%v giving up on API registration", cjSession.IDString())
+	cjSession.logger().Warnf("%v giving up on API registration", cjSession.IDString())
 
 	if r.SecondaryRegistrar != nil {
-		Logger().Debugf("%v trying secondary registration method", cjSession.IDString())
+		cjSession.logger().Debugf("%v trying secondary registration method", cjSession.IDString())
 		return r.SecondaryRegistrar.Register(cjSession, ctx)
 	}
 
 	return nil, err
 }
 
-func (r APIRegistrar) executeHTTPRequest(ctx context.Context, cjSession *ConjureSession, payload []byte) error {
-	req, err := http.NewRequestWithContext(ctx, "POST", r.Endpoint, bytes.NewReader(payload))
+func (r APIRegistrar) executeHTTPRequest(ctx context.Context, cjSession *ConjureSession, endpoint string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
 	if err != nil {
-		Logger().Warnf("%v failed to create HTTP request to registration endpoint %s: %v", cjSession.IDString(), r.Endpoint, err)
+		cjSession.logger().Warnf("%v failed to create HTTP request to registration endpoint %s: %v", cjSession.IDString(), endpoint, err)
 		return err
 	}
 
+	// the payload holds the shared secret, only its size is logged
+	cjSession.logger().Debugf("%v sending %d byte registration to endpoint %s", cjSession.IDString(), len(payload), endpoint)
 	resp, err := r.Client.Do(req)
 	if err != nil {
-		Logger().Warnf("%v failed to do HTTP request to registration endpoint %s: %v", cjSession.IDString(), r.Endpoint, err)
+		cjSession.logger().Warnf("%v failed to do HTTP request to registration endpoint %s: %v", cjSession.IDString(), endpoint, err)
 		return err
 	}
 	defer resp.Body.Close()
 
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxAPIErrorBodyLen))
+	cjSession.logger().Debugf("%v registration endpoint %s responded %s: %q", cjSession.IDString(), endpoint, resp.Status, body)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		Logger().Warnf("%v got non-success response code %d from registration endpoint %v", cjSession.IDString(), resp.StatusCode, r.Endpoint)
-		return fmt.Errorf("non-success response code %d on %s", resp.StatusCode, r.Endpoint)
+		cjSession.logger().Warnf("%v got non-success response code %d from registration endpoint %v", cjSession.IDString(), resp.StatusCode, endpoint)
+		return APIRegistrationError{Endpoint: endpoint, StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return nil
 }
 
+// maxAPIErrorBodyLen - bytes of the registration endpoint responses kept for logs
+// and errors
+const maxAPIErrorBodyLen = 512
+
+// APIRegistrationError - Rejection of a registration by an API endpoint, with the
+// HTTP status code and the start of the response body. Network errors, where no
+// response was received, are returned as they are instead.
+type APIRegistrationError struct {
+	Endpoint   string
+	StatusCode int
+	Body       string
+}
+
+func (err APIRegistrationError) Error() string {
+	msg := fmt.Sprintf("non-success response code %d on %s", err.StatusCode, err.Endpoint)
+	if err.Body != "" {
+		msg += fmt.Sprintf(": %q", err.Body)
+	}
+	return msg
+}
+
+// Temporary - Whether retrying may succeed: the endpoint failed (5xx), timed out
+// (408) or is rate limiting (429), rather than rejecting the registration
+func (err APIRegistrationError) Temporary() bool {
+	return err.StatusCode >= 500 || err.StatusCode == http.StatusRequestTimeout ||
+		err.StatusCode == http.StatusTooManyRequests
+}
+
 const (
 	v4 uint = iota
 	v6
@@ -285,26 +443,123 @@ func DialConjureAddr(ctx context.Context, address string, registrationMethod Reg
 
 // DialConjure - Perform Registration and Dial on an existing Conjure session
 func DialConjure(ctx context.Context, cjSession *ConjureSession, registrationMethod Registrar) (net.Conn, error) {
+	conn, _, err := dialConjure(ctx, cjSession, registrationMethod)
+	return conn, err
+}
+
+// dialConjure - DialConjure, also returning the registration the connection was made with
+func dialConjure(ctx context.Context, cjSession *ConjureSession, registrationMethod Registrar) (net.Conn, *ConjureReg, error) {
 
 	if cjSession == nil {
-		return nil, fmt.Errorf("No Session Provided")
+		return nil, nil, fmt.Errorf("No Session Provided")
 	}
 
-	cjSession.setV6Support(both)
+	if err := validateCovertAddress(cjSession.CovertAddress); err != nil {
+		return nil, nil, err
+	}
+
+	// A session forced to IPv6 only, or with fixed IP versions, keeps its mode and
+	// does not probe v6 support.
+	if cjSession.V6Support.include != v6 && !cjSession.V6Support.fixed {
+		cjSession.setV6Support(both)
+	}
 
 	// Choose Phantom Address in Register depending on v6 support.
 	registration, err := registrationMethod.Register(cjSession, ctx)
 	if err != nil {
-		Logger().Debugf("%v Failed to register: %v", cjSession.IDString(), err)
-		return nil, err
+		cjSession.logger().Debugf("%v Failed to register: %v", cjSession.IDString(), err)
+		return nil, nil, err
 	}
 
-	Logger().Debugf("%v Attempting to Connect ...", cjSession.IDString())
+	cjSession.logger().Debugf("%v Attempting to Connect ...", cjSession.IDString())
 
-	return registration.Connect(ctx)
+	conn, err := registration.Connect(ctx)
+	attempts := uint(1)
+	for ; err != nil && attempts <= cjSession.ConnectRetries && ctx.Err() == nil; attempts++ {
+		backoff := cjSession.getConnectRetryBackoff() << (attempts - 1)
+		cjSession.logger().Debugf("%v Failed to connect: %v, retrying in %v", cjSession.IDString(), err, backoff)
+
+		sleepCtx, sleepCancel := cjSession.withSessionClose(ctx)
+		sleepWithContext(sleepCtx, backoff)
+		sleepCancel()
+		if cjSession.isClosed() {
+			return nil, nil, errSessionClosed
+		}
+
+		if cjSession.ReregisterOnRetry {
+			registration, err = registrationMethod.Register(cjSession, ctx)
+			if err != nil {
+				cjSession.logger().Debugf("%v Failed to re-register: %v", cjSession.IDString(), err)
+				return nil, nil, err
+			}
+		}
+		conn, err = registration.Connect(ctx)
+	}
+	if err != nil {
+		return nil, nil, &PhantomUnreachableError{Attempts: attempts, Err: err}
+	}
+	return conn, registration, nil
 	// return Connect(cjSession)
 }
 
+// PhantomUnreachableError - Returned by DialConjure when the registration succeeded but
+// no connection to the phantom could be established, after any retries. Registration
+// failures are returned as they come from the Registrar instead.
+type PhantomUnreachableError struct {
+	// Attempts is the number of connection attempts made
+	Attempts uint
+
+	// Err is the error of the last attempt
+	Err error
+}
+
+func (err *PhantomUnreachableError) Error() string {
+	return fmt.Sprintf("phantom unreachable after %d attempt(s): %v", err.Attempts, err.Err)
+}
+
+func (err *PhantomUnreachableError) Unwrap() error {
+	return err.Err
+}
+
+// RegisterOnly - Perform a registration on an existing Conjure session without
+// connecting to the phantom, with registrationMethod, or a DecoyRegistrar if nil.
+// It waits for every decoy registration to complete, so the
+// returned registration reports which decoys succeeded and which phantoms were selected
+// (see ConjureReg.Digest). Useful to tell registration-path failures apart from phantom
+// reachability failures. When no decoy took the registration, the registration is
+// returned along with a NoRegistrationSent RegError, to report why each decoy failed.
+func RegisterOnly(ctx context.Context, cjSession *ConjureSession, registrationMethod Registrar) (*ConjureReg, error) {
+
+	if cjSession == nil {
+		return nil, fmt.Errorf("No Session Provided")
+	}
+
+	if err := validateCovertAddress(cjSession.CovertAddress); err != nil {
+		return nil, err
+	}
+
+	if cjSession.V6Support.include != v6 && !cjSession.V6Support.fixed {
+		cjSession.setV6Support(both)
+	}
+
+	if registrationMethod == nil {
+		registrationMethod = DecoyRegistrar{}
+	}
+
+	registration, err := registrationMethod.Register(cjSession, ctx)
+	if err != nil {
+		cjSession.logger().Debugf("%v Failed to register: %v", cjSession.IDString(), err)
+		var regErr *RegError
+		if errors.As(err, &regErr) && regErr.code == NoRegistrationSent && regErr.reg != nil {
+			return regErr.reg, err
+		}
+		return nil, err
+	}
+
+	registration.waitForSends(ctx)
+	return registration, nil
+}
+
 // // testV6 -- This is over simple and incomplete (currently unused)
 // // checking for unreachable alone does not account for local ipv6 addresses
 // // [TODO]{priority:winter-break} use getifaddr reverse bindings
@@ -350,57 +605,598 @@ type ConjureSession struct {
 	CovertAddress  string
 	// rtt			   uint // tracked in stats
 
+	// DecoyMinTLSVersion is the lowest TLS version (e.g. tls.VersionTLS12) accepted
+	// from decoys; registrations are not sent to decoys negotiating an older version,
+	// which weakens the cover and changes the handshake from the parroted browser.
+	// Zero means TLS 1.2 (default).
+	DecoyMinTLSVersion uint16
+
+	// VerifyDecoyCertificates verifies the certificates of decoys against the system
+	// roots and their hostname, failing the registrations to decoys that do not
+	// pass. Registering does not require trusting the decoy: the registration is
+	// encrypted for the station, which only needs to see the TLS session. Verifying
+	// detects TLS interception on the path to the decoy, but fails registrations to
+	// decoys with expired or misconfigured certificates. The ClientHello is the same
+	// either way. False skips verification (default).
+	VerifyDecoyCertificates bool
+
+	// DecoyRootCAs are the roots decoy certificates are verified against when
+	// VerifyDecoyCertificates is set, and the front certificate of the fronted
+	// transport always is. When nil, the system roots are used (default).
+	DecoyRootCAs *x509.CertPool
+
+	// DecoyParrots are the ClientHellos parroted in the TLS handshakes with decoys,
+	// used in turn across the registrations of the session so its simultaneous
+	// handshakes do not all share a byte-identical fingerprint. The station only reads
+	// registrations sent over TLS 1.2, so use parrots that do not offer TLS 1.3, such
+	// as tls.HelloChrome_58, HelloChrome_62, HelloFirefox_55, HelloFirefox_56 or
+	// HelloIOS_11_1. When empty, every handshake parrots tls.HelloChrome_62 (default).
+	DecoyParrots []tls.ClientHelloID
+
+	// DecoyTLSRetries is the number of times a decoy whose TLS handshake failed, e.g.
+	// because it rejects the parroted ClientHello, is dialed again for a handshake
+	// with an alternate ClientHello: the next of DecoyParrots if there are several,
+	// otherwise tls.HelloFirefox_56. Each failed decoy and parrot combination is
+	// logged, to help curate the decoy list. Zero gives up on the decoy after the
+	// first failed handshake (default).
+	DecoyTLSRetries uint
+
+	// DecoyALPN overrides the ALPN protocols offered in the ClientHellos to decoys.
+	// The parrots offer those of the browser they imitate, e.g. h2 and http/1.1, which
+	// keeps the fingerprint consistent; a decoy may then negotiate h2 and read the
+	// HTTP/1.1 registration request as a malformed HTTP/2 preface, which the station
+	// ignores. Offering only http/1.1 keeps the decoy exchange valid HTTP at the cost
+	// of deviating from the browser. An empty non-nil slice removes ALPN. When nil,
+	// the ALPN of the parrot is offered (default).
+	DecoyALPN []string
+
+	// ConnectTagVersion selects the connect tags sent on phantom connections, and is
+	// signalled to the station in the registration. ConnectTagV2 fixes the
+	// misspelled string the min transport tag is derived from; only use it with
+	// stations that accept it. Zero means ConnectTagV1 (default), not signalled.
+	ConnectTagVersion uint
+
+	// ConnectTags defines the connect tags of transports, in place of their default
+	// ones. A nil ConnectTagFunc removes the tag of its transport. Applies to the
+	// min, null and TLS transports, whose connections carry no other handshake
+	// identifying the registration. When nil, the default tags are sent (default).
+	ConnectTags map[pb.TransportType]ConnectTagFunc
+
+	// CovertUDP signals the station that CovertAddress is a UDP target. Datagrams are
+	// then exchanged over the connection framed by their length; wrap it with
+	// NewDatagramConn to send and receive them.
+	CovertUDP bool
+
+	// CovertConnectTimeout is sent to the station as the time it may spend connecting
+	// to CovertAddress before it gives up and closes the phantom connection. The first
+	// read from the connection returned by Connect is bounded by the same timeout plus
+	// covertConnectSlack, so the client stops waiting when the station does. Covert
+	// protocols where the server may stay silent longer than that must leave it unset.
+	// Zero leaves the timeout to the station (default).
+	CovertConnectTimeout time.Duration
+
+	// ClientAddress, if set, is the address of the application client whose
+	// connection the session relays, sent to the station in the registration so that
+	// it can log or forward the real client IP. This reveals the client to the
+	// station and to whoever it forwards the address to, so it is only sent when set.
+	// Empty sends none (default).
+	ClientAddress string
+
+	// FixedID, if set, replaces the generated "[<SessionID>-<secret prefix>]" ID
+	// string of the session in logs with "[FixedID]", so the logs of a registration
+	// being debugged are reproducible across runs and unaffected by other sessions.
+	// When empty, the ID is generated from the auto-incremented SessionID (default).
+	FixedID string
+
+	// TestConjureSeed, if set, replaces the ConjureSeed of the session keys in phantom
+	// selection, so that a test can assert which phantoms a full session selects,
+	// e.g. with the seed given to PhantomForSeed. FOR TESTING ONLY: the station derives
+	// the ConjureSeed from the shared secret, so it expects the session on another
+	// phantom than the one it connects to.
+	TestConjureSeed []byte
+
+	// PhantomDialAttempts is the number of TCP dials made to each phantom within the
+	// phantom dial deadline, so that a lost SYN or a transient network error does not
+	// fail the connection and require registering again. The deadline is shared out
+	// between the remaining attempts, which are spaced by a short backoff. Values
+	// below 2 dial once (default).
+	PhantomDialAttempts uint
+
+	// PhantomPort is the TCP port phantoms are connected to, signalled to the station
+	// in the registration when not 443. Only use other ports with stations that
+	// support them. Zero means 443 (default).
+	PhantomPort uint16
+
+	// DecoySelector, if set, is called by DecoyRegistrar with the decoys selected for
+	// the session by SelectDecoys, and returns the decoys to register through instead,
+	// e.g. to log them or to force a specific decoy. Returning an error or no decoys
+	// fails the registration. When nil, the SelectDecoys selection is used (default).
+	DecoySelector func(cjSession *ConjureSession, selected []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error)
+
+	// RotateDecoys mixes a rotating offset into the decoy indexes derived from the
+	// shared secret: each session of the process shifts its selection Width decoys
+	// further than the previous one, from a start that depends on the process start
+	// time, spreading the decoys of the process over the list rather than relying on
+	// the randomness of the secrets alone. The offset is drawn once per session, so its
+	// registrations all go to the same decoys.
+	//
+	// The station accepts registrations on any decoy and does not need the offset.
+	// The indexes derived from the secret stay random, so the decoys of a session do
+	// not reveal those of the other sessions of the process to observers, stations
+	// included. False selects from the shared secret alone (default).
+	RotateDecoys bool
+
+	// offset of the session decoy indexes in the rotation, set by getDecoyRotation
+	decoyRotation     uint64
+	decoyRotationOnce sync.Once
+
+	// LogSelection logs at info level, for each registration through decoys, one line
+	// with the hostnames of the decoys and the selected phantoms, to troubleshoot
+	// sessions in the field without debug logs, which include secrets. The line has
+	// no key material, but still ties the session to its phantoms.
+	LogSelection bool
+
+	// PhantomSelector maps the ConjureSeed to phantom addresses, allowing alternative
+	// selection algorithms. When nil, AssetsPhantomSelector is used.
+	PhantomSelector PhantomSelector
+
+	// ExcludedPhantoms are subnets, e.g. of phantoms known to be blocked on the local
+	// network, that phantoms are never selected in: a phantom selected in one is
+	// re-rolled deterministically with seeds derived from the ConjureSeed, see
+	// ps.SelectExcluding. OPT-IN ONLY: the exclusions are not signalled, so the station
+	// cannot compute the re-rolled phantoms and expects the session on the phantom
+	// selected first, unless it is configured with the same exclusions. Empty (default)
+	// excludes nothing.
+	ExcludedPhantoms []*net.IPNet
+
+	// Obfs4Params overrides the obfs4 bridge parameters of the ClientConf for this
+	// session. Parameters unset in both are derived from the session keys.
+	Obfs4Params *pb.Obfs4Params
+
+	// FrontingParams overrides the fronted transport parameters of the ClientConf for
+	// this session. The fronted transport requires them in either.
+	FrontingParams *pb.FrontingParams
+
+	// PhantomServerName is the SNI presented in the TLS handshakes of the TLS
+	// transport with the phantom, independent of the decoy SNIs and of the masked
+	// decoy server name, so it can be a name the phantom plausibly serves. When empty,
+	// the phantom_server_name of the ClientConf is used if it is a valid hostname,
+	// otherwise the hostname of a decoy the registration was sent to.
+	PhantomServerName string
+
+	// RegPaddingMin and RegPaddingMax bound the number of random extra padding bytes
+	// added to the registration ClientToStation, so registrations vary in size.
+	// The padded message is then aligned to a multiple of RegPaddingAlign; alignment
+	// to 3 bytes is required by the tag encoding and is always enforced.
+	// Zero values give the minimal 3-byte aligned payload (default).
+	RegPaddingAlign uint
+	RegPaddingMin   uint
+	RegPaddingMax   uint
+
+	// Timings overrides the randomized delays used during registration and
+	// connection. When nil, DefaultTimings() are used.
+	Timings *Timings
+
+	// NoRegistrationSleep skips the randomized sleep between sending the registrations
+	// and connecting to the phantom, regardless of Timings. This saves about 3 seconds
+	// per connection, but the phantom connection then closely follows the decoy
+	// registrations, a timing signal linking them that the sleep exists to break.
+	// Only disable it for testing or where that correlation is not a concern.
+	NoRegistrationSleep bool
+
+	// RegistrationTimeout bounds the registration phase: dialing the decoys, the TLS
+	// handshakes and sending the registrations. If no decoy registration completes in
+	// time, Register fails with a RegistrationTimeout RegError. The randomized sleep
+	// and the phantom connection that follow are not included. Zero means no bound
+	// other than the context (default).
+	RegistrationTimeout time.Duration
+
+	// ConnectRetries is the number of times DialConjure retries connecting to the
+	// phantom after the first attempt fails, e.g. because the station was not ready
+	// yet. Retries back off exponentially from ConnectRetryBackoff (1s when zero).
+	// With ReregisterOnRetry, the registration is sent again before each retry.
+	// Zero disables retries (default).
+	ConnectRetries      uint
+	ConnectRetryBackoff time.Duration
+	ReregisterOnRetry   bool
+
+	// DumpRegistrations logs, for debugging, the bytes of every decoy registration:
+	// the plaintext variable and fixed size payloads, the tag and the final HTTP
+	// request, hex encoded. Session keys are never logged. Off by default.
+	DumpRegistrations bool
+
+	// ReadDecoyResponse makes the decoy connections look like genuine page fetches:
+	// after sending a registration, the decoy's HTTP response is read to completion,
+	// up to maxDecoyResponseSize bytes or the decoy read timeout, before closing.
+	// When false, the connection is kept open until the decoy closes it or the
+	// timeout passes, reading and discarding at most one byte (default).
+	ReadDecoyResponse bool
+
+	// PinClientConf pins the ClientConf generation for the registrations of this
+	// session, like Assets().DisableClientConfUpdates does for all of them: they report
+	// the maximum generation, so stations send no ClientConf. Other sessions still
+	// report, and receive updates of, the local generation. Off by default.
+	PinClientConf bool
+
+	// StopAfterFirstRegistration cancels the registrations to the other decoys once
+	// one was sent, saving the traffic of those not written yet: their dials and
+	// handshakes are interrupted, and they are reported with a SendStopped RegError.
+	// Registrations already written are unaffected. Sending to every decoy (default)
+	// is more reliable, since the station may miss the registration on some decoys.
+	StopAfterFirstRegistration bool
+
+	// HTTPRequestTemplate sets the method, path and headers of the HTTP requests
+	// carrying the registrations to decoys, which should match the parroted browser.
+	// When nil, DefaultHTTPRequestTemplate is used.
+	HTTPRequestTemplate *HTTPRequestTemplate
+
 	// THIS IS REQUIRED TO INTERFACE WITH PSIPHON ANDROID
 	//		we use their dialer to prevent connection loopback into our own proxy
 	//		connection when tunneling the whole device.
 	TcpDialer func(context.Context, string, string) (net.Conn, error)
 
+	// DecoyDialer is used for the TCP connections to decoys during registration,
+	// e.g. ProxyDialer(d) to register through an upstream proxy in networks where
+	// that is the only egress. When nil, TcpDialer is used.
+	DecoyDialer func(context.Context, string, string) (net.Conn, error)
+
+	// source address of v6 phantom connections and the net.Dialer bound to it, see
+	// Dialer.PhantomV6Source
+	phantomV6Source net.IP
+	netDialer       *net.Dialer
+
+	// Logger receives the logs of this session. When nil, the TapDance-wide
+	// Logger() is used.
+	Logger LeveledLogger
+
 	// performance tracking
 	stats *pb.SessionStats
+
+	// generator of the client key set by WithKeyGenerator, used by NewConjureSession
+	// once all the options are applied
+	keyGen KeyGenerator
+
+	// lengths of the payload keys and IVs, read from the assets once when the session
+	// is created so that generated and validated keys agree
+	keyLengths KeyLengths
+
+	// closed by Close to stop in-flight registrations
+	closeMu sync.Mutex
+	closed  chan struct{}
 }
 
-func makeConjureSession(covert string, transport pb.TransportType) *ConjureSession {
+var errSessionClosed = errors.New("conjure session closed")
+
+// validateCovertAddress checks that covert is a host:port the station can connect to.
+// The station silently drops registrations with a malformed covert address, so this
+// catches them before registering. An empty address selects the station's default
+// covert. Hostnames are only checked for syntax, never resolved locally.
+func validateCovertAddress(covert string) error {
+	if covert == "" {
+		return nil
+	}
+	host, portStr, err := net.SplitHostPort(covert)
+	if err != nil {
+		return fmt.Errorf("invalid covert address %q: %v", covert, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid covert address %q: port %q is not in range 1-65535", covert, portStr)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid covert address %q: missing host", covert)
+	}
+	if net.ParseIP(host) == nil && !isValidHostname(host) {
+		return fmt.Errorf("invalid covert address %q: %q is neither an IP address nor a hostname", covert, host)
+	}
+	return nil
+}
+
+func isValidHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
 
-	keys, err := generateSharedKeys(getStationKey())
+func makeConjureSession(covert string, transport pb.TransportType) *ConjureSession {
+	cjSession, err := newConjureSession(covert, transport, nil, nil)
 	if err != nil {
 		return nil
 	}
+	return cjSession
+}
+
+// newConjureSession - Create a session with keys for the station public key stationPubkey,
+// or the key from the environment or assets if nil (see getStationKey), generated by
+// keyGen, or ElligatorKeyGenerator if nil.
+func newConjureSession(covert string, transport pb.TransportType, stationPubkey []byte, keyGen KeyGenerator) (*ConjureSession, error) {
+	cjSession := newConjureSessionDefaults(covert, transport)
+	if err := cjSession.generateKeys(stationPubkey, keyGen); err != nil {
+		return nil, err
+	}
+	return cjSession, nil
+}
+
+// generateKeys - Generate the keys of the session for the station public key
+// stationPubkey, or the key from the environment or assets if nil, with the key
+// lengths of the session
+func (cjSession *ConjureSession) generateKeys(stationPubkey []byte, keyGen KeyGenerator) error {
+	pubkey, err := getStationKey(stationPubkey, cjSession.logger())
+	if err != nil {
+		return err
+	}
+	keys, err := generateSharedKeys(pubkey, keyGen, cjSession.keyLengths)
+	if err != nil {
+		return err
+	}
+	cjSession.setKeys(keys)
+	return nil
+}
+
+// makeConjureSessionWithKeys - Create a session with keys generated beforehand instead
+// of from the station public key, e.g. for reproducible tests or by clients deriving
+// them externally. The keys must have the lengths generateSharedKeys gives them.
+func makeConjureSessionWithKeys(covert string, transport pb.TransportType, keys *sharedKeys) (*ConjureSession, error) {
+	cjSession := newConjureSessionDefaults(covert, transport)
+	if err := keys.validate(transport, cjSession.keyLengths); err != nil {
+		return nil, fmt.Errorf("invalid shared keys: %v", err)
+	}
+	cjSession.setKeys(keys)
+	return cjSession, nil
+}
+
+// newConjureSessionDefaults - Create a session with the default parameters and the key
+// lengths of the assets, without keys
+func newConjureSessionDefaults(covert string, transport pb.TransportType) *ConjureSession {
 	//[TODO]{priority:NOW} move v6support initialization to assets so it can be tracked across dials
-	cjSession := &ConjureSession{
-		Keys:           keys,
+	return &ConjureSession{
 		Width:          defaultRegWidth,
 		V6Support:      &V6{support: true, include: both},
 		UseProxyHeader: false,
 		Transport:      transport,
 		CovertAddress:  covert,
 		SessionID:      sessionsTotal.GetAndInc(),
+		keyLengths:     Assets().GetKeyLengths(),
 	}
+}
 
-	sharedSecretStr := make([]byte, hex.EncodedLen(len(keys.SharedSecret)))
-	hex.Encode(sharedSecretStr, keys.SharedSecret)
-	Logger().Debugf("%v Shared Secret  - %s", cjSession.IDString(), sharedSecretStr)
+// setKeys - Set the keys of the session. The keys themselves are never logged.
+func (cjSession *ConjureSession) setKeys(keys *sharedKeys) {
+	cjSession.Keys = keys
 
-	Logger().Debugf("%v covert %s", cjSession.IDString(), covert)
+	cjSession.logger().Debugf("%v covert %s", cjSession.IDString(), cjSession.CovertAddress)
+}
 
-	reprStr := make([]byte, hex.EncodedLen(len(keys.Representative)))
-	hex.Encode(reprStr, keys.Representative)
-	Logger().Debugf("%v Representative - %s", cjSession.IDString(), reprStr)
+// Close - Stop the in-flight registrations of the session and release the decoy
+// connections they keep open. Connections already returned by Connect are not
+// affected. Safe to call multiple times.
+func (cjSession *ConjureSession) Close() error {
+	closed := cjSession.closedChan()
 
-	return cjSession
+	cjSession.closeMu.Lock()
+	defer cjSession.closeMu.Unlock()
+	select {
+	case <-closed:
+	default:
+		close(closed)
+	}
+	return nil
+}
+
+// closedChan - Get the channel closed when the session is closed
+func (cjSession *ConjureSession) closedChan() chan struct{} {
+	cjSession.closeMu.Lock()
+	defer cjSession.closeMu.Unlock()
+	if cjSession.closed == nil {
+		cjSession.closed = make(chan struct{})
+	}
+	return cjSession.closed
+}
+
+func (cjSession *ConjureSession) isClosed() bool {
+	select {
+	case <-cjSession.closedChan():
+		return true
+	default:
+		return false
+	}
+}
+
+// withSessionClose - Derive a context that is also cancelled when the session is closed
+func (cjSession *ConjureSession) withSessionClose(ctx context.Context) (context.Context, context.CancelFunc) {
+	childCtx, cancel := context.WithCancel(ctx)
+	closed := cjSession.closedChan()
+	go func() {
+		select {
+		case <-closed:
+			cancel()
+		case <-childCtx.Done():
+		}
+	}()
+	return childCtx, cancel
 }
 
 // IDString - Get the ID string for the session
 func (cjSession *ConjureSession) IDString() string {
-	if cjSession.Keys == nil || cjSession.Keys.SharedSecret == nil {
-		return fmt.Sprintf("[%v-000000]", strconv.FormatUint(cjSession.SessionID, 10))
+	if cjSession.FixedID != "" {
+		return fmt.Sprintf("[%s]", cjSession.FixedID)
 	}
+	fingerprint := cjSession.SecretFingerprint()
+	if fingerprint == "" {
+		fingerprint = "000000"
+	}
+	return fmt.Sprintf("[%v-%s]", strconv.FormatUint(cjSession.SessionID, 10), fingerprint)
+}
 
-	secret := make([]byte, hex.EncodedLen(len(cjSession.Keys.SharedSecret)))
-	n := hex.Encode(secret, cjSession.Keys.SharedSecret)
-	if n < 6 {
-		return fmt.Sprintf("[%v-000000]", strconv.FormatUint(cjSession.SessionID, 10))
+// secretFingerprintLen - hex characters of the shared secret in session IDs
+const secretFingerprintLen = 6
+
+// SecretFingerprint - Get the first hex characters of the shared secret, as in the
+// IDString of the session, to join client and station logs. Empty without keys.
+//
+// SENSITIVE: these are bits of the session secret. They are too few to recover it,
+// but identify the session to anyone with the station logs.
+func (cjSession *ConjureSession) SecretFingerprint() string {
+	if cjSession.Keys == nil || len(cjSession.Keys.SharedSecret)*2 < secretFingerprintLen {
+		return ""
+	}
+	return hex.EncodeToString(cjSession.Keys.SharedSecret)[:secretFingerprintLen]
+}
+
+// MatchesSecretPrefix - Whether hexPrefix, e.g. from a station log line, is a prefix
+// of the hex encoded shared secret of the session at least as long as the
+// SecretFingerprint.
+func (cjSession *ConjureSession) MatchesSecretPrefix(hexPrefix string) bool {
+	if cjSession.Keys == nil || len(hexPrefix) < secretFingerprintLen {
+		return false
 	}
-	return fmt.Sprintf("[%v-%s]", strconv.FormatUint(cjSession.SessionID, 10), secret[:6])
+	return strings.HasPrefix(hex.EncodeToString(cjSession.Keys.SharedSecret), strings.ToLower(hexPrefix))
+}
+
+// Representative - Get the hex encoded Elligator representative of the session
+// public key, as sent in the registrations, to find them in station logs. Empty
+// without keys.
+//
+// SENSITIVE: the representative identifies the registrations of the session, and
+// should only be logged where the station logs are.
+func (cjSession *ConjureSession) Representative() string {
+	if cjSession.Keys == nil {
+		return ""
+	}
+	return hex.EncodeToString(cjSession.Keys.Representative)
+}
+
+// IDFingerprint - Get the SecretFingerprint from a session IDString, e.g. in client
+// logs, to match against station logs. Empty for IDs without one, such as those of
+// sessions without keys or with a FixedID.
+func IDFingerprint(id string) string {
+	id = strings.TrimSuffix(strings.TrimPrefix(id, "["), "]")
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return ""
+	}
+	fingerprint := id[i+1:]
+	if _, err := hex.DecodeString(fingerprint); err != nil || len(fingerprint) != secretFingerprintLen || fingerprint == "000000" {
+		return ""
+	}
+	return fingerprint
+}
+
+// newConjureReg - Select phantoms and prepare a registration for the session.
+// Shared by the Registrar implementations.
+func (cjSession *ConjureSession) newConjureReg() (*ConjureReg, error) {
+	if err := validatePhantomServerName(cjSession.PhantomServerName); err != nil {
+		return nil, err
+	}
+	if cjSession.TestConjureSeed != nil {
+		cjSession.logger().Warnf("%v selecting phantoms with a test ConjureSeed, which no station expects", cjSession.IDString())
+	}
+	phantom4, phantom6, err := cjSession.SelectSessionPhantoms()
+	if err != nil {
+		cjSession.logger().Warnf("%v phantom selection failed: %v", cjSession.IDString(), err)
+		return nil, err
+	}
+
+	reg := &ConjureReg{
+		sessionIDStr:       cjSession.IDString(),
+		keys:               cjSession.Keys,
+		stats:              &pb.SessionStats{Transport: cjSession.Transport.Enum()},
+		phantom4:           phantom4,
+		phantom6:           phantom6,
+		startTs:            time.Now(),
+		v6Support:          cjSession.V6Support.include,
+		covertAddress:      cjSession.CovertAddress,
+		covertUDP:          cjSession.CovertUDP,
+		covertTimeout:      cjSession.CovertConnectTimeout,
+		clientAddress:      cjSession.ClientAddress,
+		transport:          cjSession.Transport,
+		TcpDialer:          cjSession.TcpDialer,
+		decoyDialer:        cjSession.DecoyDialer,
+		phantomV6Source:    cjSession.phantomV6Source,
+		phantomAttempts:    cjSession.PhantomDialAttempts,
+		phantomPort:        cjSession.PhantomPort,
+		netDialer:          cjSession.netDialer,
+		useProxyHeader:     cjSession.UseProxyHeader,
+		paddingAlign:       cjSession.RegPaddingAlign,
+		paddingMin:         cjSession.RegPaddingMin,
+		paddingMax:         cjSession.RegPaddingMax,
+		timings:            cjSession.Timings,
+		dumpRegistrations:  cjSession.DumpRegistrations,
+		readDecoyResponse:  cjSession.ReadDecoyResponse,
+		pinClientConf:      cjSession.PinClientConf,
+		stopAfterFirst:     cjSession.StopAfterFirstRegistration,
+		requestTemplate:    cjSession.HTTPRequestTemplate,
+		decoyMinTLSVersion: cjSession.DecoyMinTLSVersion,
+		verifyDecoyCerts:   cjSession.VerifyDecoyCertificates,
+		decoyRootCAs:       cjSession.DecoyRootCAs,
+		decoyParrots:       cjSession.DecoyParrots,
+		decoyTLSRetries:    cjSession.DecoyTLSRetries,
+		decoyALPN:          cjSession.DecoyALPN,
+		connectTagVersion:  cjSession.ConnectTagVersion,
+		connectTags:        cjSession.ConnectTags,
+		obfs4Params:        cjSession.getObfs4Params(),
+		frontingParams:     cjSession.getFrontingParams(),
+		phantomServerName:  cjSession.getPhantomServerName(),
+		closed:             cjSession.closedChan(),
+		log:                cjSession.Logger,
+	}
+	return reg, nil
+}
+
+// defaultDecoyParrot - ClientHello parroted with decoys when the session sets none
+var defaultDecoyParrot = tls.HelloChrome_62
+
+// alternateDecoyParrot - ClientHello of handshakes retried after one parroting
+// defaultDecoyParrot failed, from another browser and also without TLS 1.3
+var alternateDecoyParrot = tls.HelloFirefox_56
+
+// defaultDecoyMinTLSVersion - minimum decoy TLS version when the session sets none,
+// the lowest version offered by current browsers
+const defaultDecoyMinTLSVersion = tls.VersionTLS12
+
+// defaultConnectRetryBackoff - delay before the first phantom connection retry when
+// the session does not set ConnectRetryBackoff
+const defaultConnectRetryBackoff = time.Second
+
+func (cjSession *ConjureSession) getConnectRetryBackoff() time.Duration {
+	if cjSession.ConnectRetryBackoff > 0 {
+		return cjSession.ConnectRetryBackoff
+	}
+	return defaultConnectRetryBackoff
+}
+
+// getObfs4Params - Get the obfs4 parameters of the session, falling back to the ClientConf
+func (cjSession *ConjureSession) getObfs4Params() *pb.Obfs4Params {
+	if cjSession.Obfs4Params != nil {
+		return cjSession.Obfs4Params
+	}
+	return Assets().GetObfs4Params()
+}
+
+// Obfs4Args - Get the obfs4 ClientFactory arguments (node-id, public-key and iat-mode)
+// the obfs4 transport uses to reach the station for this session.
+func (cjSession *ConjureSession) Obfs4Args() (*pt.Args, error) {
+	return obfs4Args(cjSession.getObfs4Params(), cjSession.Keys.Obfs4Keys)
+}
+
+// logger - Get the logger for the session, falling back to the TapDance-wide logger
+func (cjSession *ConjureSession) logger() LeveledLogger {
+	if cjSession.Logger != nil {
+		return cjSession.Logger
+	}
+	return Logger()
 }
 
 // String - Print the string for debug and/or logging
@@ -412,6 +1208,17 @@ func (cjSession *ConjureSession) String() string {
 type resultTuple struct {
 	conn net.Conn
 	err  error
+
+	// IP version of the phantom connected to
+	ipVersion uint32
+}
+
+// ipVersion - Get the IP version of ip, 4 or 6
+func ipVersion(ip net.IP) uint32 {
+	if ip.To4() != nil {
+		return 4
+	}
+	return 6
 }
 
 // Simple type alias for brevity
@@ -422,31 +1229,109 @@ func (reg *ConjureReg) connect(ctx context.Context, addr string, dialer dialFunc
 	deadline, deadlineAlreadySet := ctx.Deadline()
 	if !deadlineAlreadySet {
 		//[reference] randomized timeout to Dial dark decoy address
-		deadline = time.Now().Add(reg.getRandomDuration(0, 1061*2, 1953*3))
-		//[TODO]{priority:@sfrolov} explain these numbers and why they were chosen for the boundaries.
+		deadline = time.Now().Add(reg.getTimings().PhantomDialTimeout.Duration(reg.getTcpToDecoy()))
 	}
 	childCtx, childCancelFunc := context.WithDeadline(ctx, deadline)
 	defer childCancelFunc()
 
+	//[reference] Dial phantoms at their canonical address, so that v4-mapped v6 phantoms
+	// are dialed as the v4 phantoms they are, not as "[::ffff:a.b.c.d]:port".
+	// v6 phantoms are dialed from the configured source, if any, once it is usable
+	if ip := net.ParseIP(addr); ip != nil {
+		addr = ip.String()
+		if ip.To4() == nil && reg.phantomV6Source != nil {
+			err := reg.checkPhantomV6Source()
+			if err != nil {
+				return nil, &RegError{msg: err.Error(), code: Unreachable, err: err}
+			}
+			dialer = reg.boundDialer()
+		}
+	}
+
 	//[reference] Connect to Phantom Host
-	phantomAddr := net.JoinHostPort(addr, "443")
+	phantomAddr := net.JoinHostPort(addr, strconv.Itoa(int(reg.getPhantomPort())))
 
 	// conn, err := reg.TcpDialer(childCtx, "tcp", phantomAddr)
-	return dialer(childCtx, "tcp", phantomAddr)
+	attempts := reg.phantomAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := phantomDialRetryBackoff
+	for {
+		// each attempt gets its share of the time left, the last one all of it
+		attemptCtx, attemptCancel := context.WithTimeout(childCtx, time.Until(deadline)/time.Duration(attempts))
+		conn, err := dialer(attemptCtx, "tcp", phantomAddr)
+		attemptCancel()
+		attempts--
+		if err == nil || attempts == 0 || childCtx.Err() != nil {
+			return conn, err
+		}
+
+		reg.logger().Debugf("%v failed to dial phantom %v: %v, %v attempts left", reg.sessionIDStr, addr, err, attempts)
+		sleepWithContext(childCtx, backoff)
+		if childCtx.Err() != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
 }
 
+// phantomDialRetryBackoff - delay before the first phantom dial retry, doubling for
+// each following one
+const phantomDialRetryBackoff = 100 * time.Millisecond
+
+// interfaceAddrs lists the local addresses, replaced in tests.
+var interfaceAddrs = net.InterfaceAddrs
+
+// isUsableV6Source - Whether ip is a v6 address that can reach the Internet
+func isUsableV6Source(ip net.IP) bool {
+	return ip.To4() == nil && ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// checkPhantomV6Source - Check that the configured v6 source is a global v6 address
+// of this host, to fail early rather than dial v6 phantoms from an unusable source.
+func (reg *ConjureReg) checkPhantomV6Source() error {
+	if !isUsableV6Source(reg.phantomV6Source) {
+		return fmt.Errorf("phantom v6 source %v is not a global v6 address", reg.phantomV6Source)
+	}
+
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("failed to list local addresses: %v", err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(reg.phantomV6Source) {
+			return nil
+		}
+	}
+	return fmt.Errorf("phantom v6 source %v is not a local address", reg.phantomV6Source)
+}
+
+// boundDialer - The session net.Dialer, with the v6 source as its LocalAddr
+func (reg *ConjureReg) boundDialer() dialFunc {
+	var d net.Dialer
+	if reg.netDialer != nil {
+		d = *reg.netDialer
+	}
+	d.LocalAddr = &net.TCPAddr{IP: reg.phantomV6Source}
+	return d.DialContext
+}
+
+// getFirstConnection - Dial all provided phantoms at once, the v4 and v6 phantoms of
+// the registration, and return the first connection established, closing the others.
 func (reg *ConjureReg) getFirstConnection(ctx context.Context, dialer dialFunc, phantoms []net.IP) (net.Conn, error) {
 	connChannel := make(chan resultTuple, len(phantoms))
 	for _, p := range phantoms {
 		go func(phantom net.IP) {
+			version := ipVersion(phantom)
 			conn, err := reg.connect(ctx, phantom.String(), dialer)
 			if err != nil {
-				Logger().Infof("%v failed to dial phantom %v: %v", reg.sessionIDStr, phantom.String(), err)
-				connChannel <- resultTuple{nil, err}
+				reg.logger().Infof("%v failed to dial v%d phantom %v: %v", reg.sessionIDStr, version, phantom.String(), err)
+				connChannel <- resultTuple{nil, err, version}
 				return
 			}
-			Logger().Infof("%v Connected to phantom %v using transport %d", reg.sessionIDStr, phantom.String(), reg.transport)
-			connChannel <- resultTuple{conn, nil}
+			reg.logger().Infof("%v Connected to v%d phantom %v using transport %d", reg.sessionIDStr, version, phantom.String(), reg.transport)
+			connChannel <- resultTuple{conn, nil, version}
 		}(p)
 	}
 
@@ -460,7 +1345,7 @@ func (reg *ConjureReg) getFirstConnection(ctx context.Context, dialer dialFunc,
 
 		// If we made it here we're returning the connection, so
 		// set up a goroutine to close the others
-		go func() {
+		go func(open int) {
 			// Close all but one connection (the good one)
 			for open > 1 {
 				t := <-connChannel
@@ -469,8 +1354,9 @@ func (reg *ConjureReg) getFirstConnection(ctx context.Context, dialer dialFunc,
 				}
 				open--
 			}
-		}()
+		}(open)
 
+		reg.setPhantomIPVersion(rt.ipVersion)
 		return rt.conn, nil
 	}
 
@@ -478,64 +1364,137 @@ func (reg *ConjureReg) getFirstConnection(ctx context.Context, dialer dialFunc,
 }
 
 // Connect - Use a registration (result of calling Register) to connect to a phantom
-// Note: This is hacky but should work for v4, v6, or both as any nil phantom addr will
-// return a dial error and be ignored.
+// Note: This works for v4, v6, or both as nil phantom addresses are skipped.
 func (reg *ConjureReg) Connect(ctx context.Context) (net.Conn, error) {
-	phantoms := []net.IP{*reg.phantom4, *reg.phantom6}
-	//[reference] Provide chosen transport to sent bytes (or connect) if necessary
-	switch reg.transport {
-	case pb.TransportType_Min:
-		conn, err := reg.getFirstConnection(ctx, reg.TcpDialer, phantoms)
-		if err != nil {
-			Logger().Infof("%v failed to form phantom connection: %v", reg.sessionIDStr, err)
-			return nil, err
+	conn, err := reg.connectTransport(ctx)
+	if err == nil && !reg.startTs.IsZero() {
+		reg.setTotalTimeToConnect(durationToU32ptrMs(time.Since(reg.startTs)))
+	}
+	return conn, err
+}
+
+// Reconnect - Connect again to the phantom of a registration that was already used
+// with Connect, e.g. after a transient drop of that connection, reusing its phantom
+// and keys instead of registering again.
+//
+// The station only accepts connections for a registration during a validity window
+// after receiving it. Its length is part of the station configuration and is not
+// known to the client.
+// Once the registration has expired, the phantom no longer answers as a station and
+// Reconnect fails like Connect to an unreachable phantom; a new session must then be
+// registered.
+func (reg *ConjureReg) Reconnect(ctx context.Context) (net.Conn, error) {
+	reg.logger().Infof("%v Reconnecting to phantom", reg.sessionIDStr)
+	return reg.connectTransport(ctx)
+}
+
+// connectTransport - Dial the phantoms of the registration and set up the transport
+func (reg *ConjureReg) connectTransport(ctx context.Context) (conn net.Conn, err error) {
+	defer func() {
+		if err == nil && reg.covertTimeout > 0 {
+			conn = newCovertConnectConn(conn, time.Now().Add(reg.covertTimeout+covertConnectSlack))
 		}
+	}()
 
-		// Send hmac(seed, str) bytes to indicate to station (min transport)
-		connectTag := conjureHMAC(reg.keys.SharedSecret, "MinTrasportHMACString")
-		conn.Write(connectTag)
-		return conn, nil
+	transport, ok := transports[reg.transport]
+	if !ok {
+		return nil, fmt.Errorf("Unknown Transport")
+	}
+	return transport.Connect(ctx, reg)
+}
 
-	case pb.TransportType_Obfs4:
-		args := pt.Args{}
-		args.Add("node-id", reg.keys.Obfs4Keys.NodeID.Hex())
-		args.Add("public-key", reg.keys.Obfs4Keys.PublicKey.Hex())
-		args.Add("iat-mode", "1")
+// phantomIPs - Get the selected phantoms of the registration, v4 first
+func (reg *ConjureReg) phantomIPs() []net.IP {
+	phantoms := []net.IP{}
+	if reg.phantom4 != nil {
+		phantoms = append(phantoms, *reg.phantom4)
+	}
+	if reg.phantom6 != nil {
+		phantoms = append(phantoms, *reg.phantom6)
+	}
+	return phantoms
+}
 
-		Logger().Infof("%v node_id = %s; public key = %s", reg.sessionIDStr, reg.keys.Obfs4Keys.NodeID.Hex(), reg.keys.Obfs4Keys.PublicKey.Hex())
+// writeConnectTag - Send the connect tag of the transport, if any, on a new phantom
+// connection.
+func (reg *ConjureReg) writeConnectTag(conn net.Conn) {
+	connectTag := reg.getConnectTag()
+	if connectTag == nil {
+		return
+	}
+	if tag := connectTag(reg.keys.SharedSecret, reg.connectTagVersion); len(tag) > 0 {
+		conn.Write(tag)
+	}
+}
 
-		t := obfs4.Transport{}
-		c, err := t.ClientFactory("")
-		if err != nil {
-			Logger().Infof("%v failed to create client factory: %v", reg.sessionIDStr, err)
-			return nil, err
-		}
+// covertConnectSlack - Margin over the covert connect timeout before the client stops
+// waiting for the covert, covering the phantom connection reaching the station.
+const covertConnectSlack = 2 * time.Second
 
-		parsedArgs, err := c.ParseArgs(&args)
-		if err != nil {
-			Logger().Infof("%v failed to parse obfs4 args: %v", reg.sessionIDStr, err)
-			return nil, err
-		}
+// covertConnectConn - A phantom connection whose reads fail once the covert connect
+// deadline passes without any data from the covert. Read deadlines set by the user
+// still apply, and are the only ones left once data has been received.
+type covertConnectConn struct {
+	net.Conn
+	covertDeadline time.Time
 
-		dialer := func(dialContext context.Context, network string, address string) (net.Conn, error) {
-			d := func(network, address string) (net.Conn, error) { return reg.TcpDialer(dialContext, network, address) }
-			return c.Dial("tcp", address, d, parsedArgs)
-		}
+	m            sync.Mutex
+	readDeadline time.Time // set by the user
+	gotData      bool
+}
 
-		conn, err := reg.getFirstConnection(ctx, dialer, phantoms)
-		if err != nil {
-			Logger().Infof("%v failed to form obfs4 connection: %v", reg.sessionIDStr, err)
-			return nil, err
-		}
+func newCovertConnectConn(conn net.Conn, covertDeadline time.Time) net.Conn {
+	return &covertConnectConn{Conn: conn, covertDeadline: covertDeadline}
+}
 
-		return conn, err
-	case pb.TransportType_Null:
-		// Dial and do nothing to the connection before returning it to the user.
-		return reg.getFirstConnection(ctx, reg.TcpDialer, phantoms)
-	default:
-		// If transport is unrecognized use min transport.
-		return nil, fmt.Errorf("Unknown Transport")
+// effectiveReadDeadline - The earlier of the user and covert deadlines while no
+// data has been received. Must be called with c.m held.
+func (c *covertConnectConn) effectiveReadDeadline() time.Time {
+	if c.gotData || (!c.readDeadline.IsZero() && c.readDeadline.Before(c.covertDeadline)) {
+		return c.readDeadline
 	}
+	return c.covertDeadline
+}
+
+func (c *covertConnectConn) Read(b []byte) (int, error) {
+	c.m.Lock()
+	waiting := !c.gotData
+	if waiting {
+		c.Conn.SetReadDeadline(c.effectiveReadDeadline())
+	}
+	c.m.Unlock()
+
+	n, err := c.Conn.Read(b)
+	if !waiting {
+		return n, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if n > 0 && !c.gotData {
+		c.gotData = true
+		c.Conn.SetReadDeadline(c.readDeadline)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() && n == 0 && !c.gotData &&
+		!time.Now().Before(c.covertDeadline) && c.effectiveReadDeadline().Equal(c.covertDeadline) {
+		return n, fmt.Errorf("no data from the covert before the covert connect timeout: %w", err)
+	}
+	return n, err
+}
+
+func (c *covertConnectConn) SetReadDeadline(t time.Time) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(c.effectiveReadDeadline())
+}
+
+func (c *covertConnectConn) SetDeadline(t time.Time) error {
+	err := c.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
 }
 
 // ConjureReg - Registration structure created for each individual registration within a session.
@@ -546,18 +1505,130 @@ type ConjureReg struct {
 	phantom6       *net.IP
 	useProxyHeader bool
 	covertAddress  string
+	covertUDP      bool
+	covertTimeout  time.Duration // see ConjureSession.CovertConnectTimeout
+	clientAddress  string        // see ConjureSession.ClientAddress
 	phantomSNI     string
 	v6Support      uint
 	transport      pb.TransportType
 
+	// registration payload padding, see ConjureSession.RegPaddingAlign
+	paddingAlign uint
+	paddingMin   uint
+	paddingMax   uint
+
+	phantomAttempts uint   // dials per phantom, see ConjureSession.PhantomDialAttempts
+	phantomPort     uint16 // 0 for defaultPhantomPort
+
+	timings *Timings // nil for DefaultTimings()
+
+	dumpRegistrations bool // see ConjureSession.DumpRegistrations
+
+	readDecoyResponse bool // see ConjureSession.ReadDecoyResponse
+
+	pinClientConf bool // see ConjureSession.PinClientConf
+
+	stopAfterFirst bool // see ConjureSession.StopAfterFirstRegistration
+
+	apiEndpoint string // API endpoint the registration succeeded on, see APIEndpoint
+
+	requestTemplate *HTTPRequestTemplate // nil for DefaultHTTPRequestTemplate
+
+	decoyMinTLSVersion uint16 // 0 for defaultDecoyMinTLSVersion
+	verifyDecoyCerts   bool   // see ConjureSession.VerifyDecoyCertificates
+
+	decoyRootCAs *x509.CertPool // nil for the system roots
+
+	connectTagVersion uint                                // see ConjureSession.ConnectTagVersion
+	connectTags       map[pb.TransportType]ConnectTagFunc // see ConjureSession.ConnectTags
+
+	// parrots used in turn for decoy handshakes, from a random start
+	decoyParrots    []tls.ClientHelloID
+	nextParrotIndex int
+	parrotsStarted  bool
+	decoyTLSRetries uint // see ConjureSession.DecoyTLSRetries
+
+	decoyALPN []string // nil for the ALPN of the parrots, see ConjureSession.DecoyALPN
+
+	obfs4Params *pb.Obfs4Params // see ConjureSession.Obfs4Params
+
+	frontingParams *pb.FrontingParams // see ConjureSession.FrontingParams
+
+	phantomServerName string // see ConjureSession.PhantomServerName
+
+	closed <-chan struct{} // closed with the session
+
 	// THIS IS REQUIRED TO INTERFACE WITH PSIPHON ANDROID
 	//		we use their dialer to prevent connection loopback into our own proxy
 	//		connection when tunneling the whole device.
 	TcpDialer func(context.Context, string, string) (net.Conn, error)
 
-	stats *pb.SessionStats
-	keys  *sharedKeys
-	m     sync.Mutex
+	// dialer for decoy registrations, nil to use TcpDialer
+	decoyDialer func(context.Context, string, string) (net.Conn, error)
+
+	phantomV6Source net.IP      // see Dialer.PhantomV6Source
+	netDialer       *net.Dialer // base for dialers bound to phantomV6Source
+
+	stats   *pb.SessionStats
+	startTs time.Time // registration start, for TotalTimeToConnect
+	keys    *sharedKeys
+	log     LeveledLogger
+	m       sync.Mutex
+
+	// outcome of each decoy registration, and the sends still in flight
+	decoyResults []decoyResult
+	sends        sync.WaitGroup
+
+	// closed once a decoy registration was sent
+	sent     chan struct{}
+	sentOnce sync.Once
+}
+
+// decoyResult - Outcome of sending a registration to a single decoy
+type decoyResult struct {
+	decoy   *pb.TLSDecoySpec
+	address string // dialed, empty if the registration failed before dialing
+	err     error
+}
+
+// addressStr - The address the decoy was dialed at, or its default one
+func (result decoyResult) addressStr() string {
+	if result.address != "" {
+		return result.address
+	}
+	return result.decoy.GetIpAddrStr()
+}
+
+// logger - Get the logger for the registration, falling back to the TapDance-wide logger
+func (reg *ConjureReg) logger() LeveledLogger {
+	if reg.log != nil {
+		return reg.log
+	}
+	return Logger()
+}
+
+// maxRequestSize - The registration request must fit in a single TLS record, the
+// only one the station decrypts
+const maxRequestSize = 16384
+
+// requestSizeError - Error for a registration request too large to be sent, naming
+// the field that contributes the most to it: the covert address, the padding, or the
+// headers of the HTTP request template.
+func (reg *ConjureReg) requestSizeError(msg string, headersLen int) error {
+	field, fieldLen := "HTTP request headers", headersLen
+	if len(reg.covertAddress) > fieldLen {
+		field, fieldLen = "covert address", len(reg.covertAddress)
+	}
+	if int(reg.paddingMax) > fieldLen {
+		field, fieldLen = "registration padding", int(reg.paddingMax)
+	}
+	return fmt.Errorf("%v: %v of %v bytes is too large", msg, field, fieldLen)
+}
+
+// decoyNegotiatedHTTP2 - Whether the decoy chose HTTP/2 with ALPN, so that the
+// registration request is sent as HTTP/2 frames
+func decoyNegotiatedHTTP2(tlsConn *tls.UConn) bool {
+	return tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS
 }
 
 func (reg *ConjureReg) createRequest(tlsConn *tls.UConn, decoy *pb.TLSDecoySpec) ([]byte, error) {
@@ -567,7 +1638,7 @@ func (reg *ConjureReg) createRequest(tlsConn *tls.UConn, decoy *pb.TLSDecoySpec)
 		return nil, err
 	}
 	if len(vsp) > int(^uint16(0)) {
-		return nil, fmt.Errorf("Variable-Size Payload exceeds %v", ^uint16(0))
+		return nil, reg.requestSizeError(fmt.Sprintf("Variable-Size Payload of %v bytes exceeds %v", len(vsp), ^uint16(0)), 0)
 	}
 	encryptedVsp, err := aesGcmEncrypt(vsp, reg.keys.VspKey, reg.keys.VspIv)
 	if err != nil {
@@ -585,22 +1656,80 @@ func (reg *ConjureReg) createRequest(tlsConn *tls.UConn, decoy *pb.TLSDecoySpec)
 	tag = append(encryptedVsp, reg.keys.Representative...)
 	tag = append(tag, encryptedFsp...)
 
-	httpRequest := generateHTTPRequestBeginning(decoy.GetHostname())
-	keystreamOffset := len(httpRequest)
-	keystreamSize := (len(tag)/3+1)*4 + keystreamOffset // we can't use first 2 bits of every byte
+	host := decoy.GetHostname()
+	if host == "" {
+		host, _, _ = net.SplitHostPort(decoy.GetIpAddrStr())
+	}
+	template := reg.getHTTPRequestTemplate()
+	if err := template.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the request is rendered with room for the encoded tag, which is filled in once
+	// the keystream at its offset in the TLS record is known
+	encodedTagLen := reverseEncryptedSize(len(tag))
+	var httpRequest []byte
+	var keystreamOffset int
+	if decoyNegotiatedHTTP2(tlsConn) {
+		httpRequest, keystreamOffset = template.renderHTTP2(host, encodedTagLen, tlsConn.ClientHelloID)
+	} else {
+		httpRequest = template.render(host)
+		keystreamOffset = len(httpRequest)
+		httpRequest = append(httpRequest, make([]byte, encodedTagLen)...)
+		httpRequest = append(httpRequest, []byte("\r\n\r\n")...)
+	}
+	keystreamSize := reverseEncryptKeystreamSize(len(tag)) + keystreamOffset
+	trailerSize := len(httpRequest) - keystreamOffset - encodedTagLen
+	if requestSize := keystreamSize + trailerSize; requestSize > maxRequestSize {
+		return nil, reg.requestSizeError(fmt.Sprintf("registration request of %v bytes exceeds the %v bytes of a TLS record",
+			requestSize, maxRequestSize), keystreamOffset)
+	}
 	wholeKeystream, err := tlsConn.GetOutKeystream(keystreamSize)
 	if err != nil {
 		return nil, err
 	}
 	keystreamAtTag := wholeKeystream[keystreamOffset:]
-	httpRequest = append(httpRequest, reverseEncrypt(tag, keystreamAtTag)...)
-	httpRequest = append(httpRequest, []byte("\r\n\r\n")...)
+	copy(httpRequest[keystreamOffset:], reverseEncrypt(tag, keystreamAtTag))
+
+	if reg.dumpRegistrations {
+		reg.logger().Infof("%v registration dump for %v (%v):\n\tvsp: %x\n\tfsp: %x\n\ttag: %x\n\trequest: %x",
+			reg.sessionIDStr, decoy.GetHostname(), decoy.GetIpAddrStr(), vsp, fsp, tag, httpRequest)
+	}
 	return httpRequest, nil
 }
 
-// Being called in parallel -> no changes to ConjureReg allowed in this function
+// Being called in parallel -> only mutex-protected changes to ConjureReg allowed in this function
 func (reg *ConjureReg) send(ctx context.Context, decoy *pb.TLSDecoySpec, dialError chan error, callback func(*ConjureReg)) {
 
+	// stopped is set when a registration sent to another decoy cancels this send
+	var stopped int32
+
+	// report records the outcome for this decoy before handing it to the registrar
+	var decoyAddr string
+	reported := false
+	report := func(err error) {
+		reported = true
+		if err == nil {
+			reg.sentOnce.Do(func() { close(reg.sentChan()) })
+		} else if atomic.LoadInt32(&stopped) == 1 && errors.Is(err, context.Canceled) {
+			err = &RegError{msg: fmt.Sprintf("stopped after a registration was sent to another decoy: %v", err), code: SendStopped, err: err}
+		}
+		reg.addDecoyResult(decoy, decoyAddr, err)
+		reg.sends.Done()
+		dialError <- err
+	}
+
+	// A panic, e.g. on a malformed decoy, fails this decoy instead of the process
+	defer func() {
+		if r := recover(); r != nil {
+			reg.logger().Errorf("%v panic sending registration to %v - %v: %v\n%s",
+				reg.sessionIDStr, decoy.GetHostname(), decoy.GetIpAddrStr(), r, debug.Stack())
+			if !reported {
+				report(&RegError{msg: fmt.Sprintf("panic sending registration: %v", r), code: Unknown})
+			}
+		}
+	}()
+
 	deadline, deadlineAlreadySet := ctx.Deadline()
 	if !deadlineAlreadySet {
 		deadline = time.Now().Add(getRandomDuration(deadlineTCPtoDecoyMin, deadlineTCPtoDecoyMax))
@@ -608,92 +1737,453 @@ func (reg *ConjureReg) send(ctx context.Context, decoy *pb.TLSDecoySpec, dialErr
 	childCtx, childCancelFunc := context.WithDeadline(ctx, deadline)
 	defer childCancelFunc()
 
-	//[reference] TCP to decoy
+	//[reference] optionally stop before writing once another decoy took the registration
+	if reg.stopAfterFirst {
+		sent := reg.sentChan()
+		go func() {
+			select {
+			case <-sent:
+				atomic.StoreInt32(&stopped, 1)
+				childCancelFunc()
+			case <-childCtx.Done():
+			}
+		}()
+	}
+
+	// decoys from a DecoySelector are not checked by SelectDecoys
+	if decoy.GetIpAddrStr() == "" {
+		reg.logger().Warnf("%v Skipping decoy %q without an IP address", reg.sessionIDStr, decoy.GetHostname())
+		report(&RegError{msg: fmt.Sprintf("decoy %q has no IP address", decoy.GetHostname()), code: DialFailure})
+		return
+	}
+
+	//[reference] TCP to decoy, bounded separately from the TLS handshake
+	dial := func() (net.Conn, string, error) {
+		dialCtx, dialCancelFunc := childCtx, context.CancelFunc(func() {})
+		if dialTimeout := reg.getTimings().DecoyDialTimeout; dialTimeout != (Timing{}) {
+			dialCtx, dialCancelFunc = context.WithTimeout(childCtx, dialTimeout.Duration(0))
+		}
+		defer dialCancelFunc()
+		return reg.dialDecoy(dialCtx, decoy)
+	}
 	tcpToDecoyStartTs := time.Now()
 
-	//[Note] decoy.GetIpAddrStr() will get only v4 addr if a decoy has both
-	dialConn, err := reg.TcpDialer(childCtx, "tcp", decoy.GetIpAddrStr())
+	dialConn, decoyAddr, err := dial()
+
+	reg.setTCPToDecoy(durationToU32ptrMs(time.Since(tcpToDecoyStartTs)))
+	if err != nil {
+		report(classifyDialError(err))
+		return
+	}
+
+	//[reference] connection stats tracking
+	rtt := uint32(time.Since(tcpToDecoyStartTs).Milliseconds())
+	TLSDeadline := time.Now().Add(reg.getTimings().DecoyTLSTimeout.Duration(rtt))
+
+	tlsToDecoyStartTs := time.Now()
+	parrot := reg.nextDecoyParrot()
+	tlsConn, err := reg.createTLSConnParrot(childCtx, dialConn, decoyAddr, decoy.GetHostname(), decoy.GetNoSni(), TLSDeadline, parrot)
+	for retries := reg.decoyTLSRetries; err != nil && retries > 0 && childCtx.Err() == nil; retries-- {
+		dialConn.Close()
+		reg.logger().Infof("%v TLS handshake with decoy %v (%v) parroting %v failed: %v", reg.sessionIDStr,
+			decoy.GetHostname(), decoyAddr, parrot.Str(), err)
+		parrot = reg.alternateParrot(parrot)
+
+		dialConn, decoyAddr, err = dial()
+		if err != nil {
+			report(classifyDialError(err))
+			return
+		}
+		tlsToDecoyStartTs = time.Now()
+		TLSDeadline = time.Now().Add(reg.getTimings().DecoyTLSTimeout.Duration(rtt))
+		tlsConn, err = reg.createTLSConnParrot(childCtx, dialConn, decoyAddr, decoy.GetHostname(), decoy.GetNoSni(), TLSDeadline, parrot)
+	}
+	if err != nil {
+		dialConn.Close()
+		msg := fmt.Sprintf("%v - %v createConn parroting %v: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), parrot.Str(), err.Error())
+		report(&RegError{msg: msg, code: TLSError, err: err})
+		return
+	}
+	reg.setTLSToDecoy(durationToU32ptrMs(time.Since(tlsToDecoyStartTs)))
+
+	//[reference] Create the HTTP request for the registration
+	httpRequest, err := reg.createRequest(tlsConn, decoy)
+	if err != nil {
+		msg := fmt.Sprintf("%v - %v createReq: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
+		report(&RegError{msg: msg, code: TLSError, err: err})
+		return
+	}
+
+	//[reference] Write reg into conn
+	if reg.stopAfterFirst && reg.isSent() {
+		tlsConn.Close()
+		report(&RegError{msg: fmt.Sprintf("%v - %v stopped after a registration was sent to another decoy",
+			decoy.GetHostname(), decoy.GetIpAddrStr()), code: SendStopped})
+		return
+	}
+	_, err = tlsConn.Write(httpRequest)
+	if err != nil {
+		// // This will not get printed because it is executed in a goroutine.
+		// Logger().Errorf("%v - %v Could not send Conjure registration request, error: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
+		tlsConn.Close()
+		msg := fmt.Sprintf("%v - %v Write: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
+		report(&RegError{msg: msg, code: TLSError, err: err})
+		return
+	}
+
+	report(nil)
+	// The decoy connection then lingers like that of a browser. Closing it at the
+	// registration deadline or when the caller gives up would tie its end to the
+	// registration on the wire, so only the read deadline or the session close end it.
+	if reg.readDecoyResponse && decoyNegotiatedHTTP2(tlsConn) {
+		reg.readHTTP2ResponseAndClose(tlsConn, time.Second*15)
+	} else if reg.readDecoyResponse {
+		reg.readResponseAndClose(tlsConn, time.Second*15)
+	} else {
+		reg.readAndClose(dialConn, time.Second*15)
+	}
+	callback(reg)
+}
+
+// decoyFallbackDelay - Delay before also dialing the v6 address of a dual-stack decoy
+// while its v4 address is being dialed (the net.Dialer default fallback delay)
+const decoyFallbackDelay = 300 * time.Millisecond
+
+// decoyAddrs - Get the addresses to dial a decoy at, in order of preference. Both
+// addresses of dual-stack decoys are used when the session supports both IP versions,
+// v4 first, and only the v6 one in v6 only sessions.
+func (reg *ConjureReg) decoyAddrs(decoy *pb.TLSDecoySpec) []string {
+	v4Addr := (&pb.TLSDecoySpec{Ipv4Addr: decoy.Ipv4Addr}).GetIpAddrStr()
+	v6Addr := (&pb.TLSDecoySpec{Ipv6Addr: decoy.Ipv6Addr}).GetIpAddrStr()
+	switch {
+	case reg.v6Support == both && v4Addr != "" && v6Addr != "":
+		return []string{v4Addr, v6Addr}
+	case reg.v6Support == v6 && v6Addr != "":
+		return []string{v6Addr}
+	default:
+		return []string{decoy.GetIpAddrStr()}
+	}
+}
+
+// dialDecoy - Connect to a decoy, racing the addresses of dual-stack decoys happy
+// eyeballs style: each address is dialed decoyFallbackDelay after the previous one,
+// or as soon as it fails. Returns the connection and the address it was made to.
+// On failure, the error of the preferred address is returned.
+func (reg *ConjureReg) dialDecoy(ctx context.Context, decoy *pb.TLSDecoySpec) (net.Conn, string, error) {
+	addrs := reg.decoyAddrs(decoy)
+	dialer := reg.getDecoyDialer()
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn net.Conn
+		addr string
+		err  error
+	}
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	var fallback <-chan time.Time
+	dialNext := func() {
+		addr := addrs[next]
+		go func() {
+			// a panicking dialer fails the address, as send cannot recover it here
+			defer func() {
+				if r := recover(); r != nil {
+					results <- dialResult{nil, addr, fmt.Errorf("panic dialing decoy: %v", r)}
+				}
+			}()
+			conn, err := dialer(raceCtx, "tcp", addr)
+			results <- dialResult{conn, addr, err}
+		}()
+		next++
+		pending++
+		fallback = nil
+		if next < len(addrs) {
+			fallback = time.After(decoyFallbackDelay)
+		}
+	}
+
+	dialNext()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-fallback:
+			dialNext()
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// close the connections still being made
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				reg.addDecoyConnect(result.addr)
+				return result.conn, result.addr, nil
+			}
+			if firstErr == nil || result.addr == addrs[0] {
+				firstErr = result.err
+			}
+			if pending == 0 && next < len(addrs) {
+				dialNext()
+			}
+		}
+	}
+	return nil, addrs[0], firstErr
+}
+
+// classifyDialError - Wrap a decoy dial error in a RegError: Unreachable when the
+// network or host has no route (e.g. no v6 connectivity), DialFailure otherwise,
+// including timeouts and refused connections from a reachable network.
+func classifyDialError(err error) *RegError {
+	if errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH) {
+		return &RegError{msg: err.Error(), code: Unreachable, err: err}
+	}
+	return &RegError{msg: err.Error(), code: DialFailure, err: err}
+}
+
+// createTLSConn - Handshake with the decoy. The handshake is aborted at the deadline,
+// or earlier if ctx is done, so a decoy stalling mid-handshake can't hang the registration.
+// With noSNI the ClientHello carries no SNI, and the hostname (or the IP if there is
+// none) is only used to verify the certificate, if the session verifies them.
+func (reg *ConjureReg) createTLSConn(ctx context.Context, dialConn net.Conn, address string, hostname string, noSNI bool, deadline time.Time) (*tls.UConn, error) {
+	return reg.createTLSConnParrot(ctx, dialConn, address, hostname, noSNI, deadline, reg.nextDecoyParrot())
+}
+
+// createTLSConnParrot - createTLSConn, parroting the given ClientHello
+func (reg *ConjureReg) createTLSConnParrot(ctx context.Context, dialConn net.Conn, address string, hostname string, noSNI bool, deadline time.Time, parrot tls.ClientHelloID) (*tls.UConn, error) {
+	var err error
+	//[reference] TLS to Decoy
+	config := tls.Config{ServerName: hostname, RootCAs: reg.decoyRootCAs, InsecureSkipVerify: !reg.verifyDecoyCerts}
+	if config.ServerName == "" {
+		// if SNI is unset -- try IP
+		config.ServerName, _, err = net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if !noSNI {
+			reg.logger().Warnf("%v Decoy %v has no SNI and is not marked no_sni. Setting SNI to its IP", reg.sessionIDStr, config.ServerName)
+		}
+	}
+	//[TODO]{priority:medium} parroting Chrome 62 ClientHello by default -- parrot newer.
+	tlsConn := tls.UClient(dialConn, &config, parrot)
+	if noSNI {
+		err = tlsConn.RemoveSNIExtension()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tlsConn.BuildHandshakeState()
+	if err != nil {
+		return nil, err
+	}
+	if reg.decoyALPN != nil {
+		setALPN(tlsConn, reg.decoyALPN)
+	}
+	err = tlsConn.MarshalClientHello()
+	if err != nil {
+		return nil, err
+	}
+
+	err = handshakeContext(ctx, tlsConn, dialConn, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parroted hello sets the versions offered, so a decoy downgrading below the
+	// minimum can only be refused once the handshake is done, before registering.
+	err = reg.checkDecoyTLSVersion(tlsConn.ConnectionState().Version)
+	if err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// setALPN - Replace the ALPN protocols offered by the built handshake state of
+// tlsConn, adding the extension before any padding if the parrot has none, or
+// removing it if protocols is empty. The ClientHello must be marshalled again.
+func setALPN(tlsConn *tls.UConn, protocols []string) {
+	tlsConn.HandshakeState.Hello.AlpnProtocols = protocols
+	for i, ext := range tlsConn.Extensions {
+		if alpn, ok := ext.(*tls.ALPNExtension); ok {
+			if len(protocols) == 0 {
+				tlsConn.Extensions = append(tlsConn.Extensions[:i], tlsConn.Extensions[i+1:]...)
+			} else {
+				alpn.AlpnProtocols = protocols
+			}
+			return
+		}
+	}
+	if len(protocols) == 0 {
+		return
+	}
+
+	alpn := &tls.ALPNExtension{AlpnProtocols: protocols}
+	i := len(tlsConn.Extensions)
+	if i > 0 {
+		if _, ok := tlsConn.Extensions[i-1].(*tls.UtlsPaddingExtension); ok {
+			i--
+		}
+	}
+	tlsConn.Extensions = append(tlsConn.Extensions[:i], append([]tls.TLSExtension{alpn}, tlsConn.Extensions[i:]...)...)
+}
+
+// handshakeContext - Perform the TLS handshake of tlsConn over dialConn until the
+// deadline or the context deadline, whichever is first, or until ctx is cancelled.
+func handshakeContext(ctx context.Context, tlsConn *tls.UConn, dialConn net.Conn, deadline time.Time) error {
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	tlsConn.SetDeadline(deadline)
+
+	// utls has no HandshakeContext: expire the deadline to unblock the handshake on cancel
+	handshakeDone := make(chan struct{})
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		select {
+		case <-ctx.Done():
+			dialConn.SetDeadline(time.Now())
+		case <-handshakeDone:
+		}
+	}()
+
+	err := tlsConn.Handshake()
+	close(handshakeDone)
+	<-watcherDone
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// nextDecoyParrot - Get the ClientHello to parrot in the next decoy handshake, cycling
+// through the session parrots from a random one.
+func (reg *ConjureReg) nextDecoyParrot() tls.ClientHelloID {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	if len(reg.decoyParrots) == 0 {
+		return defaultDecoyParrot
+	}
+	if !reg.parrotsStarted {
+		reg.nextParrotIndex = getRandInt(0, len(reg.decoyParrots)-1)
+		reg.parrotsStarted = true
+	}
+	parrot := reg.decoyParrots[reg.nextParrotIndex%len(reg.decoyParrots)]
+	reg.nextParrotIndex++
+	return parrot
+}
+
+// alternateParrot - Get the ClientHello to retry a decoy handshake with after one
+// parroting failed did: the next other one of the session parrots if it has several,
+// otherwise alternateDecoyParrot.
+func (reg *ConjureReg) alternateParrot(failed tls.ClientHelloID) tls.ClientHelloID {
+	reg.m.Lock()
+	parrots := len(reg.decoyParrots)
+	reg.m.Unlock()
+
+	for i := 1; i < parrots; i++ {
+		if parrot := reg.nextDecoyParrot(); parrot != failed {
+			return parrot
+		}
+	}
+	if failed == alternateDecoyParrot {
+		return defaultDecoyParrot
+	}
+	return alternateDecoyParrot
+}
+
+// checkDecoyTLSVersion - Get an error if the TLS version negotiated with a decoy is
+// below the minimum accepted
+func (reg *ConjureReg) checkDecoyTLSVersion(version uint16) error {
+	minVersion := uint16(defaultDecoyMinTLSVersion)
+	if reg.decoyMinTLSVersion != 0 {
+		minVersion = reg.decoyMinTLSVersion
+	}
+	if version < minVersion {
+		return fmt.Errorf("decoy negotiated TLS version %#04x, below the minimum %#04x", version, minVersion)
+	}
+	return nil
+}
+
+func (reg *ConjureReg) addDecoyResult(decoy *pb.TLSDecoySpec, address string, err error) {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	reg.decoyResults = append(reg.decoyResults, decoyResult{decoy: decoy, address: address, err: err})
+}
 
-	reg.setTCPToDecoy(durationToU32ptrMs(time.Since(tcpToDecoyStartTs)))
-	if err != nil {
-		if opErr, ok := err.(*net.OpError); ok && opErr.Err.Error() == "connect: network is unreachable" {
-			dialError <- RegError{msg: err.Error(), code: Unreachable}
-			return
-		}
-		dialError <- err
-		return
-	}
+// addDecoyConnect - Count a decoy TCP connection in the stats of its IP version
+func (reg *ConjureReg) addDecoyConnect(address string) {
+	host, _, _ := net.SplitHostPort(address)
+	ip := net.ParseIP(host)
 
-	//[reference] connection stats tracking
-	rtt := rttInt(uint32(time.Since(tcpToDecoyStartTs).Milliseconds()))
-	delay := getRandomDuration(1061*rtt*2, 1953*rtt*3) //[TODO]{priority:@sfrolov} why these values??
-	TLSDeadline := time.Now().Add(delay)
+	reg.m.Lock()
+	defer reg.m.Unlock()
 
-	tlsToDecoyStartTs := time.Now()
-	tlsConn, err := reg.createTLSConn(dialConn, decoy.GetIpAddrStr(), decoy.GetHostname(), TLSDeadline)
-	if err != nil {
-		dialConn.Close()
-		msg := fmt.Sprintf("%v - %v createConn: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
-		dialError <- RegError{msg: msg, code: TLSError}
-		return
+	if reg.stats == nil {
+		reg.stats = &pb.SessionStats{}
 	}
-	reg.setTLSToDecoy(durationToU32ptrMs(time.Since(tlsToDecoyStartTs)))
-
-	//[reference] Create the HTTP request for the registration
-	httpRequest, err := reg.createRequest(tlsConn, decoy)
-	if err != nil {
-		msg := fmt.Sprintf("%v - %v createReq: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
-		dialError <- RegError{msg: msg, code: TLSError}
-		return
+	if ip.To4() != nil {
+		reg.stats.DecoyV4Connects = proto.Uint32(reg.stats.GetDecoyV4Connects() + 1)
+	} else if ip != nil {
+		reg.stats.DecoyV6Connects = proto.Uint32(reg.stats.GetDecoyV6Connects() + 1)
 	}
+}
 
-	//[reference] Write reg into conn
-	_, err = tlsConn.Write(httpRequest)
-	if err != nil {
-		// // This will not get printed because it is executed in a goroutine.
-		// Logger().Errorf("%v - %v Could not send Conjure registration request, error: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
-		tlsConn.Close()
-		msg := fmt.Sprintf("%v - %v Write: %v", decoy.GetHostname(), decoy.GetIpAddrStr(), err.Error())
-		dialError <- RegError{msg: msg, code: TLSError}
-		return
+// sentChan - Get the channel closed once a decoy registration was sent
+func (reg *ConjureReg) sentChan() chan struct{} {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+	if reg.sent == nil {
+		reg.sent = make(chan struct{})
 	}
+	return reg.sent
+}
 
-	dialError <- nil
-	readAndClose(dialConn, time.Second*15)
-	callback(reg)
+// isSent - Whether a decoy registration was sent
+func (reg *ConjureReg) isSent() bool {
+	select {
+	case <-reg.sentChan():
+		return true
+	default:
+		return false
+	}
 }
 
-func (reg *ConjureReg) createTLSConn(dialConn net.Conn, address string, hostname string, deadline time.Time) (*tls.UConn, error) {
-	var err error
-	//[reference] TLS to Decoy
-	config := tls.Config{ServerName: hostname}
-	if config.ServerName == "" {
-		// if SNI is unset -- try IP
-		config.ServerName, _, err = net.SplitHostPort(address)
-		if err != nil {
-			return nil, err
+// anyDecoySucceeded - Whether a decoy registration has been sent successfully
+func (reg *ConjureReg) anyDecoySucceeded() bool {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	for _, result := range reg.decoyResults {
+		if result.err == nil {
+			return true
 		}
-		Logger().Debugf("%v SNI was nil. Setting it to %v ", reg.sessionIDStr, config.ServerName)
 	}
-	//[TODO]{priority:medium} parroting Chrome 62 ClientHello -- parrot newer.
-	tlsConn := tls.UClient(dialConn, &config, tls.HelloChrome_62)
+	return false
+}
 
-	err = tlsConn.BuildHandshakeState()
-	if err != nil {
-		return nil, err
-	}
-	err = tlsConn.MarshalClientHello()
-	if err != nil {
-		return nil, err
-	}
+// waitForSends - Block until every decoy registration has reported its outcome or
+// the context is done.
+func (reg *ConjureReg) waitForSends(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		reg.sends.Wait()
+		close(done)
+	}()
 
-	tlsConn.SetDeadline(deadline)
-	err = tlsConn.Handshake()
-	if err != nil {
-		return nil, err
+	select {
+	case <-done:
+	case <-ctx.Done():
 	}
-
-	return tlsConn, nil
 }
 
 func (reg *ConjureReg) setTCPToDecoy(tcprtt *uint32) {
@@ -716,6 +2206,69 @@ func (reg *ConjureReg) setTLSToDecoy(tlsrtt *uint32) {
 	reg.stats.TlsToDecoy = tlsrtt
 }
 
+func (reg *ConjureReg) setPhantomIPVersion(version uint32) {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	if reg.stats == nil {
+		reg.stats = &pb.SessionStats{}
+	}
+	reg.stats.PhantomIpVersion = &version
+}
+
+func (reg *ConjureReg) setTotalTimeToConnect(total *uint32) {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	if reg.stats == nil {
+		reg.stats = &pb.SessionStats{}
+	}
+	reg.stats.TotalTimeToConnect = total
+}
+
+// TCPToDecoy - Get the measured TCP connection time to a decoy, or 0 if not measured
+func (reg *ConjureReg) TCPToDecoy() time.Duration {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	return time.Duration(reg.stats.GetTcpToDecoy()) * time.Millisecond
+}
+
+// TLSToDecoy - Get the measured TLS handshake time with a decoy, or 0 if not measured
+func (reg *ConjureReg) TLSToDecoy() time.Duration {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	return time.Duration(reg.stats.GetTlsToDecoy()) * time.Millisecond
+}
+
+// PhantomIPVersion - Get the IP version, 4 or 6, of the phantom last connected to, or
+// 0 before connecting
+func (reg *ConjureReg) PhantomIPVersion() uint {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	return uint(reg.stats.GetPhantomIpVersion())
+}
+
+// APIEndpoint - Get the endpoint an APIRegistrar registered on, or "" for
+// registrations through decoys
+func (reg *ConjureReg) APIEndpoint() string {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	return reg.apiEndpoint
+}
+
+// TotalTimeToConnect - Get the time from the start of the registration until the
+// phantom connection was established, or 0 if not connected yet
+func (reg *ConjureReg) TotalTimeToConnect() time.Duration {
+	reg.m.Lock()
+	defer reg.m.Unlock()
+
+	return time.Duration(reg.stats.GetTotalTimeToConnect()) * time.Millisecond
+}
+
 func (reg *ConjureReg) getPbTransport() pb.TransportType {
 	return pb.TransportType(reg.transport)
 }
@@ -739,16 +2292,17 @@ func (reg *ConjureReg) generateFlags() *pb.RegistrationFlags {
 }
 
 func (reg *ConjureReg) generateClientToStation() *pb.ClientToStation {
+	//[reference] Signal the session intent: connect to the provided covert, or
+	// to the station's default proxy when no covert address is set.
 	var covert *string
+	transition := pb.C2S_Transition_C2S_SESSION_INIT
 	if len(reg.covertAddress) > 0 {
-		//[TODO]{priority:medium} this isn't the correct place to deal with signaling to the station
-		//transition = pb.C2S_Transition_C2S_SESSION_COVERT_INIT
+		transition = pb.C2S_Transition_C2S_SESSION_COVERT_INIT
 		covert = &reg.covertAddress
 	}
 
 	//[reference] Generate ClientToStation protobuf
-	// transition := pb.C2S_Transition_C2S_SESSION_INIT
-	currentGen := Assets().GetGeneration()
+	currentGen := Assets().getReportedGeneration(reg.pinClientConf)
 	transport := reg.getPbTransport()
 	initProto := &pb.ClientToStation{
 		CovertAddress:       covert,
@@ -757,7 +2311,7 @@ func (reg *ConjureReg) generateClientToStation() *pb.ClientToStation {
 		V4Support:           reg.getV4Support(),
 		Transport:           &transport,
 		Flags:               reg.generateFlags(),
-		// StateTransition:     &transition,
+		StateTransition:     &transition,
 
 		//[TODO]{priority:medium} specify width in C2S because different width might
 		// 		be useful in different regions (constant for now.)
@@ -767,21 +2321,74 @@ func (reg *ConjureReg) generateClientToStation() *pb.ClientToStation {
 		initProto.MaskedDecoyServerName = &reg.phantomSNI
 	}
 
-	for (proto.Size(initProto)+AES_GCM_TAG_SIZE)%3 != 0 {
-		initProto.Padding = append(initProto.Padding, byte(0))
+	if reg.covertUDP {
+		initProto.CovertUdp = proto.Bool(true)
+	}
+
+	if reg.covertTimeout > 0 {
+		initProto.CovertConnectTimeoutMs = durationToU32ptrMs(reg.covertTimeout)
+	}
+
+	if port := reg.getPhantomPort(); port != defaultPhantomPort {
+		initProto.PhantomPort = proto.Uint32(uint32(port))
+	}
+
+	if len(reg.clientAddress) > 0 {
+		initProto.ClientAddress = proto.String(reg.clientAddress)
+	}
+
+	if reg.connectTagVersion > ConnectTagV1 {
+		initProto.ConnectTagVersion = proto.Uint32(uint32(reg.connectTagVersion))
 	}
 
+	reg.padClientToStation(initProto)
+
 	return initProto
 }
 
+// padClientToStation - add the configured random padding, then pad until the
+// encrypted size is aligned (always to a multiple of 3 for the tag encoding).
+func (reg *ConjureReg) padClientToStation(initProto *pb.ClientToStation) {
+	if reg.paddingMax > 0 || reg.paddingMin > 0 {
+		extra := getRandInt(int(reg.paddingMin), maxInt(int(reg.paddingMin), int(reg.paddingMax)))
+		initProto.Padding = append(initProto.Padding, make([]byte, extra)...)
+	}
+
+	align := 3
+	if reg.paddingAlign > 0 {
+		align = int(reg.paddingAlign)
+		if align%3 != 0 {
+			align *= 3
+		}
+	}
+
+	for (proto.Size(initProto)+AES_GCM_TAG_SIZE)%align != 0 {
+		initProto.Padding = append(initProto.Padding, byte(0))
+	}
+}
+
 func (reg *ConjureReg) generateVSP() ([]byte, error) {
 	//[reference] Marshal ClientToStation protobuf
 	return proto.Marshal(reg.generateClientToStation())
 }
 
+// The fixed size payload (FSP) carries the length of the encrypted VSP in its first
+// two bytes, big-endian, and the version of the payload format at fspVersionOffset,
+// for the station to dispatch parsing by version as the format evolves. Version 0 is
+// the original format, with the other bytes zero and the VSP a marshalled
+// ClientToStation; stations that predate the version byte parse it unchanged.
+const (
+	fspSize          = 6
+	fspVersionOffset = 3
+
+	payloadVersion0       = 0
+	currentPayloadVersion = payloadVersion0
+)
+
 func (reg *ConjureReg) generateFSP(espSize uint16) []byte {
-	buf := make([]byte, 6)
+	buf := make([]byte, fspSize)
 	binary.BigEndian.PutUint16(buf[0:2], espSize)
+	buf[fspVersionOffset] = currentPayloadVersion
 
 	return buf
 }
@@ -804,7 +2411,11 @@ func (reg *ConjureReg) getV6Support() *bool {
 }
 
 func (reg *ConjureReg) v6SupportStr() string {
-	switch reg.v6Support {
+	return v6SupportStr(reg.v6Support)
+}
+
+func v6SupportStr(support uint) string {
+	switch support {
 	case both:
 		return "Both"
 	case v4:
@@ -824,16 +2435,173 @@ func (reg *ConjureReg) digestStats() string {
 
 	reg.m.Lock()
 	defer reg.m.Unlock()
-	return fmt.Sprintf("{result:\"success\", tcp_to_decoy:%v, tls_to_decoy:%v, total_time_to_connect:%v}",
+	var transport string
+	if reg.stats.Transport != nil {
+		transport = fmt.Sprintf(", transport:%v", reg.stats.GetTransport())
+	}
+	var phantom string
+	if reg.stats.PhantomIpVersion != nil {
+		phantom = fmt.Sprintf(", phantom:v%v", reg.stats.GetPhantomIpVersion())
+	}
+	return fmt.Sprintf("{result:\"success\", tcp_to_decoy:%v, tls_to_decoy:%v, total_time_to_connect:%v%v%v}",
 		reg.stats.GetTcpToDecoy(),
 		reg.stats.GetTlsToDecoy(),
-		reg.stats.GetTotalTimeToConnect())
+		reg.stats.GetTotalTimeToConnect(),
+		transport,
+		phantom)
+}
+
+// selectionSummary - Describe the decoys registered through and the selected
+// phantoms, without any key material, see ConjureSession.LogSelection
+func (reg *ConjureReg) selectionSummary(decoys []*pb.TLSDecoySpec) string {
+	hostnames := make([]string, len(decoys))
+	for i, decoy := range decoys {
+		hostnames[i] = decoy.GetHostname()
+	}
+	return fmt.Sprintf("Registering through decoys [%v], phantoms: v4:%v, v6:%v, transport:%v",
+		strings.Join(hostnames, ", "), reg.phantom4, reg.phantom6, reg.transport)
+}
+
+// Digest - Summarize the registration: selected phantoms, the outcome of each decoy
+// registration and the measured stats.
+func (reg *ConjureReg) Digest() string {
+	reg.m.Lock()
+	results := make([]decoyResult, len(reg.decoyResults))
+	copy(results, reg.decoyResults)
+	apiEndpoint := reg.apiEndpoint
+	reg.m.Unlock()
+
+	var digest strings.Builder
+	fmt.Fprintf(&digest, "%v phantoms: v4:%v, v6:%v\n", reg.sessionIDStr, reg.phantom4, reg.phantom6)
+	if apiEndpoint != "" {
+		fmt.Fprintf(&digest, "%v registered on API endpoint %v\n", reg.sessionIDStr, apiEndpoint)
+	}
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(&digest, "%v decoy %v (%v) failed: %v\n", reg.sessionIDStr,
+				result.decoy.GetHostname(), result.addressStr(), result.err)
+		} else {
+			fmt.Fprintf(&digest, "%v decoy %v (%v) succeeded\n", reg.sessionIDStr,
+				result.decoy.GetHostname(), result.addressStr())
+		}
+	}
+	fmt.Fprintf(&digest, "%v %v", reg.sessionIDStr, reg.digestStats())
+	return digest.String()
+}
+
+// readAndClose - Keep the decoy connection open until it is closed by the decoy, the
+// deadline passes, or the session is closed.
+func (reg *ConjureReg) readAndClose(c net.Conn, readDeadline time.Duration) {
+	c.SetReadDeadline(time.Now().Add(readDeadline))
+	defer reg.interruptReads(c)()
+
+	tinyBuf := []byte{0}
+	c.Read(tinyBuf)
+	c.Close()
+}
+
+// Most bytes of a decoy HTTP response read with ConjureSession.ReadDecoyResponse
+const maxDecoyResponseSize = 1 << 20
+
+// readResponseAndClose - Read the decoy HTTP response to the registration request to
+// completion like a browser would, within maxDecoyResponseSize bytes, the deadline
+// and the session lifetime, then close the connection.
+func (reg *ConjureReg) readResponseAndClose(c net.Conn, readDeadline time.Duration) {
+	c.SetReadDeadline(time.Now().Add(readDeadline))
+	defer reg.interruptReads(c)()
+
+	limited := io.LimitReader(c, maxDecoyResponseSize)
+	resp, err := http.ReadResponse(bufio.NewReader(limited), nil)
+	if err == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	c.Close()
+}
+
+// readHTTP2ResponseAndClose - Like readResponseAndClose, for registration requests
+// sent as HTTP/2 frames: read the frames of the decoy until the response stream ended,
+// acknowledging its SETTINGS like a browser would, then close the connection.
+func (reg *ConjureReg) readHTTP2ResponseAndClose(c *tls.UConn, readDeadline time.Duration) {
+	c.SetReadDeadline(time.Now().Add(readDeadline))
+	defer reg.interruptReads(c)()
+
+	// the stream of the request, which depends on the parroted browser
+	requestStream := http2FingerprintOf(c.ClientHelloID).requestStream
+
+	framer := http2.NewFramer(c, io.LimitReader(c, maxDecoyResponseSize))
+	for done := false; !done; {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			break
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				framer.WriteSettingsAck()
+			}
+		case *http2.HeadersFrame:
+			done = f.StreamID == requestStream && f.StreamEnded()
+		case *http2.DataFrame:
+			done = f.StreamID == requestStream && f.StreamEnded()
+		case *http2.RSTStreamFrame:
+			done = f.StreamID == requestStream
+		case *http2.GoAwayFrame:
+			done = true
+		}
+	}
+	c.Close()
+}
+
+// interruptReads - Unblock reads on c when the session is closed, until the returned
+// function is called, which also ends the watching goroutine.
+func (reg *ConjureReg) interruptReads(c net.Conn) func() {
+	readDone := make(chan struct{})
+	go func() {
+		select {
+		case <-reg.closed:
+			c.SetReadDeadline(time.Now())
+		case <-readDone:
+		}
+	}()
+	return func() { close(readDone) }
+}
+
+// getDecoyDialer - Get the dialer for decoy registrations, falling back to TcpDialer
+func (reg *ConjureReg) getDecoyDialer() dialFunc {
+	if reg.decoyDialer != nil {
+		return reg.decoyDialer
+	}
+	return reg.TcpDialer
+}
+
+// getHTTPRequestTemplate - Get the registration request template, falling back to
+// DefaultHTTPRequestTemplate
+func (reg *ConjureReg) getHTTPRequestTemplate() *HTTPRequestTemplate {
+	if reg.requestTemplate != nil {
+		return reg.requestTemplate
+	}
+	return &DefaultHTTPRequestTemplate
+}
+
+// defaultPhantomPort - port phantoms are connected to when the session sets none
+const defaultPhantomPort = 443
+
+// getPhantomPort - Get the TCP port to connect to phantoms on
+func (reg *ConjureReg) getPhantomPort() uint16 {
+	if reg.phantomPort > 0 {
+		return reg.phantomPort
+	}
+	return defaultPhantomPort
 }
 
-func (reg *ConjureReg) getRandomDuration(base, min, max int) time.Duration {
-	addon := getRandInt(min, max) / 1000 // why this min and max???
-	rtt := rttInt(reg.getTcpToDecoy())
-	return time.Millisecond * time.Duration(base+rtt*addon)
+// getTimings - Get the delays for the registration, falling back to DefaultTimings
+func (reg *ConjureReg) getTimings() *Timings {
+	if reg.timings != nil {
+		return reg.timings
+	}
+	timings := DefaultTimings()
+	return &timings
 }
 
 func (reg *ConjureReg) getTcpToDecoy() uint32 {
@@ -868,13 +2636,7 @@ func (cjSession *ConjureSession) setV6Support(support uint) {
 //	 	session stats and/or errors.
 func (cjSession *ConjureSession) registrationCallback(reg *ConjureReg) {
 	//[TODO]{priority:NOW}
-	Logger().Infof("%v %v", cjSession.IDString(), reg.digestStats())
-}
-
-func (cjSession *ConjureSession) getRandomDuration(base, min, max int) time.Duration {
-	addon := getRandInt(min, max) / 1000 // why this min and max???
-	rtt := rttInt(cjSession.getTcpToDecoy())
-	return time.Millisecond * time.Duration(base+rtt*addon)
+	cjSession.logger().Infof("%v %v", cjSession.IDString(), reg.digestStats())
 }
 
 func (cjSession *ConjureSession) getTcpToDecoy() uint32 {
@@ -903,24 +2665,69 @@ func rttInt(millis uint32) int {
 	return int(millis)
 }
 
-// SelectDecoys - Get an array of `width` decoys to be used for registration
-func SelectDecoys(sharedSecret []byte, version uint, width uint) ([]*pb.TLSDecoySpec, error) {
-
+// decoysOfVersion - Get the decoys of the assets of the IP version, v4, v6 or both
+func decoysOfVersion(version uint) []*pb.TLSDecoySpec {
 	//[reference] prune to v6 only decoys if useV6 is true
-	var allDecoys []*pb.TLSDecoySpec
 	switch version {
 	case v6:
-		allDecoys = Assets().GetV6Decoys()
+		return Assets().GetV6Decoys()
 	case v4:
-		allDecoys = Assets().GetV4Decoys()
-	case both:
-		allDecoys = Assets().GetAllDecoys()
+		return Assets().GetV4Decoys()
 	default:
-		allDecoys = Assets().GetAllDecoys()
+		return decoysWithAddress(Assets().GetAllDecoys())
+	}
+}
+
+// decoysWithAddress - Drop the decoys without an IP address, e.g. malformed assets
+// entries, logging them, as they cannot be dialed
+func decoysWithAddress(decoys []*pb.TLSDecoySpec) []*pb.TLSDecoySpec {
+	for i, decoy := range decoys {
+		if decoy.GetIpAddrStr() != "" {
+			continue
+		}
+		valid := append([]*pb.TLSDecoySpec(nil), decoys[:i]...)
+		for _, decoy := range decoys[i:] {
+			if decoy.GetIpAddrStr() == "" {
+				Logger().Warnf("Skipping decoy %q without an IP address", decoy.GetHostname())
+				continue
+			}
+			valid = append(valid, decoy)
+		}
+		return valid
 	}
+	return decoys
+}
+
+// decoyRotations - Sessions that rotated their decoys, see RotateDecoys
+var decoyRotations CounterUint64
+
+// decoyRotationStart - First offset of the rotation, varying with the process start
+// so processes started together do not all begin with the same offset
+var decoyRotationStart = uint64(time.Now().UnixNano())
+
+// getDecoyRotation - Get the offset of the session decoy indexes in the rotation,
+// taking the next one, Width decoys further, on the first call
+func (cjSession *ConjureSession) getDecoyRotation() uint64 {
+	cjSession.decoyRotationOnce.Do(func() {
+		cjSession.decoyRotation = decoyRotationStart + decoyRotations.GetAndInc()*uint64(cjSession.Width)
+	})
+	return cjSession.decoyRotation
+}
+
+// SelectDecoys - Get an array of `width` decoys to be used for registration
+// The array is empty when there are no decoys of the requested IP version.
+func SelectDecoys(sharedSecret []byte, version uint, width uint) ([]*pb.TLSDecoySpec, error) {
+	return selectDecoysOffset(sharedSecret, version, width, 0)
+}
+
+// selectDecoysOffset - SelectDecoys, with offset added to the index of each decoy in
+// the decoy list, see RotateDecoys
+func selectDecoysOffset(sharedSecret []byte, version uint, width uint, offset uint64) ([]*pb.TLSDecoySpec, error) {
+	allDecoys := decoysOfVersion(version)
 
+	// no decoys of the requested IP version: nothing to select from
 	if len(allDecoys) == 0 {
-		return nil, fmt.Errorf("no decoys")
+		return []*pb.TLSDecoySpec{}, nil
 	}
 
 	decoys := make([]*pb.TLSDecoySpec, width)
@@ -935,6 +2742,7 @@ func SelectDecoys(sharedSecret []byte, version uint, width uint) ([]*pb.TLSDecoy
 		hmacInt = hmacInt.SetBytes(hmac[:8])
 		hmacInt.SetBytes(hmac)
 		hmacInt.Abs(hmacInt)
+		hmacInt.Add(hmacInt, new(big.Int).SetUint64(offset))
 		idx.Mod(hmacInt, numDecoys)
 		decoys[i] = allDecoys[int(idx.Int64())]
 	}
@@ -950,26 +2758,42 @@ func SelectDecoys(sharedSecret []byte, version uint, width uint) ([]*pb.TLSDecoy
 
 // SelectPhantom - select one phantom IP address based on shared secret
 func SelectPhantom(seed []byte, support uint) (*net.IP, *net.IP, error) {
-	phantomSubnets := Assets().GetPhantomSubnets()
+	return selectPhantoms(AssetsPhantomSelector{}, seed, support)
+}
+
+func selectPhantoms(selector PhantomSelector, seed []byte, support uint) (*net.IP, *net.IP, error) {
+	selectFamily := func(v6 bool) (*net.IP, error) {
+		phantom, err := selector.Select(seed, v6)
+		if err != nil {
+			// see ps.ErrNoSubnets, ps.ErrReservedAddress and ErrUnsupportedSelectorVersion
+			family := "v4"
+			if v6 {
+				family = "v6"
+			}
+			return nil, fmt.Errorf("failed to select %v phantom: %w", family, err)
+		}
+		return phantom, nil
+	}
+
 	switch support {
 	case v4:
-		phantomIPv4, err := ps.SelectPhantom(seed, phantomSubnets, ps.V4Only, true)
+		phantomIPv4, err := selectFamily(false)
 		if err != nil {
 			return nil, nil, err
 		}
 		return phantomIPv4, nil, nil
 	case v6:
-		phantomIPv6, err := ps.SelectPhantom(seed, phantomSubnets, ps.V6Only, true)
+		phantomIPv6, err := selectFamily(true)
 		if err != nil {
 			return nil, nil, err
 		}
 		return nil, phantomIPv6, nil
 	case both:
-		phantomIPv4, err := ps.SelectPhantom(seed, phantomSubnets, ps.V4Only, true)
+		phantomIPv4, err := selectFamily(false)
 		if err != nil {
 			return nil, nil, err
 		}
-		phantomIPv6, err := ps.SelectPhantom(seed, phantomSubnets, ps.V6Only, true)
+		phantomIPv6, err := selectFamily(true)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -979,8 +2803,84 @@ func SelectPhantom(seed []byte, support uint) (*net.IP, *net.IP, error) {
 	}
 }
 
-func getStationKey() [32]byte {
-	return *Assets().GetConjurePubkey()
+// SelectSessionPhantoms - Get the phantoms the session's selector maps its ConjureSeed
+// to, per supported address family, without registering. For diagnostics; enable trace
+// logs of the session Logger to also see how each phantom was chosen.
+func (cjSession *ConjureSession) SelectSessionPhantoms() (phantom4, phantom6 *net.IP, err error) {
+	selector := cjSession.PhantomSelector
+	if selector == nil {
+		selector = AssetsPhantomSelector{Tracer: cjSession.logger()}
+	}
+	if len(cjSession.ExcludedPhantoms) > 0 {
+		selector = excludingSelector{selector, cjSession.ExcludedPhantoms, cjSession.logger()}
+	}
+	return selectPhantoms(selector, cjSession.conjureSeed(), cjSession.V6Support.include)
+}
+
+// conjureSeed - Get the seed phantoms are selected with, see TestConjureSeed
+func (cjSession *ConjureSession) conjureSeed() []byte {
+	if cjSession.TestConjureSeed != nil {
+		return cjSession.TestConjureSeed
+	}
+	return cjSession.Keys.ConjureSeed
+}
+
+// PhantomForSeed - compute the phantom address a ConjureSeed maps to within the given
+// subnets, without dialing. Useful for debugging phantom selection mismatches between
+// client and station.
+func PhantomForSeed(seed []byte, subnets []string, v6 bool) (net.IP, error) {
+	subnetsList := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Subnets: subnets}},
+	}
+
+	transform := ps.V4Only
+	if v6 {
+		transform = ps.V6Only
+	}
+
+	phantom, err := ps.SelectPhantomTraced(seed, subnetsList, transform, false, Logger())
+	if err != nil {
+		return nil, err
+	}
+	return *phantom, nil
+}
+
+// StationPubkeyEnv - Environment variable that, when set to a hex encoded 32-byte key,
+// overrides the Conjure station public key of the assets, e.g. to point CI at a
+// staging station without touching the assets.
+const StationPubkeyEnv = "TAPDANCE_STATION_PUBKEY"
+
+// getStationKey - Get the station public key to register with. In order of precedence:
+// the explicit key (from the Dialer), the StationPubkeyEnv environment variable, and
+// the ClientConf of the assets. Only where the key comes from is logged, to logger.
+func getStationKey(explicit []byte, logger LeveledLogger) ([32]byte, error) {
+	var pubkey [32]byte
+
+	if explicit != nil {
+		if len(explicit) != len(pubkey) {
+			return pubkey, fmt.Errorf("station public key must be %d bytes, got %d", len(pubkey), len(explicit))
+		}
+		copy(pubkey[:], explicit)
+		logger.Debugf("Using the station public key from the Dialer")
+		return pubkey, nil
+	}
+
+	if envKey, ok := os.LookupEnv(StationPubkeyEnv); ok && envKey != "" {
+		decoded, err := hex.DecodeString(strings.TrimSpace(envKey))
+		if err != nil {
+			return pubkey, fmt.Errorf("invalid %s: %v", StationPubkeyEnv, err)
+		}
+		if len(decoded) != len(pubkey) {
+			return pubkey, fmt.Errorf("invalid %s: station public key must be %d bytes, got %d", StationPubkeyEnv, len(pubkey), len(decoded))
+		}
+		copy(pubkey[:], decoded)
+		logger.Debugf("Using the station public key from %s", StationPubkeyEnv)
+		return pubkey, nil
+	}
+
+	pubkey = *Assets().GetConjurePubkey()
+	logger.Debugf("Using the station public key from the assets")
+	return pubkey, nil
 }
 
 type Obfs4Keys struct {
@@ -1015,14 +2915,61 @@ func generateObfs4Keys(rand io.Reader) (Obfs4Keys, error) {
 	return keys, err
 }
 
+// defaultObfs4IATMode - obfs4 inter-arrival time obfuscation mode used when
+// no Obfs4Params set one
+const defaultObfs4IATMode = 1
+
+// obfs4Args - Get the obfs4 ClientFactory arguments from params, using the
+// per-session keys for the node ID and public key that params leaves unset.
+func obfs4Args(params *pb.Obfs4Params, keys Obfs4Keys) (*pt.Args, error) {
+	nodeID := keys.NodeID.Hex()
+	if id := params.GetNodeId(); id != nil {
+		if len(id) != ntor.NodeIDLength {
+			return nil, fmt.Errorf("obfs4 node ID must be %d bytes, got %d", ntor.NodeIDLength, len(id))
+		}
+		nodeID = hex.EncodeToString(id)
+	}
+
+	publicKey := keys.PublicKey.Hex()
+	if pub := params.GetPublicKey(); pub != nil {
+		if len(pub) != ntor.PublicKeyLength {
+			return nil, fmt.Errorf("obfs4 public key must be %d bytes, got %d", ntor.PublicKeyLength, len(pub))
+		}
+		publicKey = hex.EncodeToString(pub)
+	}
+
+	iatMode := uint32(defaultObfs4IATMode)
+	if params != nil && params.IatMode != nil {
+		iatMode = params.GetIatMode()
+		if iatMode > 2 {
+			return nil, fmt.Errorf("invalid obfs4 iat-mode %d", iatMode)
+		}
+	}
+
+	args := pt.Args{}
+	args.Add("node-id", nodeID)
+	args.Add("public-key", publicKey)
+	args.Add("iat-mode", strconv.FormatUint(uint64(iatMode), 10))
+	return &args, nil
+}
+
 type sharedKeys struct {
 	SharedSecret, Representative                               []byte
 	FspKey, FspIv, VspKey, VspIv, NewMasterSecret, ConjureSeed []byte
 	Obfs4Keys                                                  Obfs4Keys
 }
 
-func generateSharedKeys(pubkey [32]byte) (*sharedKeys, error) {
-	sharedSecret, representative, err := generateEligatorTransformedKey(pubkey[:])
+// generateSharedKeys - Derive the session keys, with the payload keys and IVs of the
+// given lengths, from a client key generated by keyGen for the station public key
+// pubkey, or by ElligatorKeyGenerator if nil
+func generateSharedKeys(pubkey [32]byte, keyGen KeyGenerator, lengths KeyLengths) (*sharedKeys, error) {
+	if err := lengths.validate(); err != nil {
+		return nil, fmt.Errorf("invalid key lengths: %v", err)
+	}
+	if keyGen == nil {
+		keyGen = ElligatorKeyGenerator{}
+	}
+	sharedSecret, representative, err := keyGen.GenerateKey(pubkey)
 	if err != nil {
 		return nil, err
 	}
@@ -1031,12 +2978,12 @@ func generateSharedKeys(pubkey [32]byte) (*sharedKeys, error) {
 	keys := &sharedKeys{
 		SharedSecret:    sharedSecret,
 		Representative:  representative,
-		FspKey:          make([]byte, 16),
-		FspIv:           make([]byte, 12),
-		VspKey:          make([]byte, 16),
-		VspIv:           make([]byte, 12),
-		NewMasterSecret: make([]byte, 48),
-		ConjureSeed:     make([]byte, 16),
+		FspKey:          make([]byte, lengths.FspKey),
+		FspIv:           make([]byte, lengths.FspIv),
+		VspKey:          make([]byte, lengths.VspKey),
+		VspIv:           make([]byte, lengths.VspIv),
+		NewMasterSecret: make([]byte, newMasterSecretLen),
+		ConjureSeed:     make([]byte, conjureSeedLen),
 	}
 
 	if _, err := tdHkdf.Read(keys.FspKey); err != nil {
@@ -1061,6 +3008,37 @@ func generateSharedKeys(pubkey [32]byte) (*sharedKeys, error) {
 	return keys, err
 }
 
+// validate - Check the keys have the lengths of those from generateSharedKeys with the
+// given lengths, and the obfs4 keys are set for the obfs4 transport.
+func (keys *sharedKeys) validate(transport pb.TransportType, lengths KeyLengths) error {
+	if keys == nil {
+		return errors.New("no keys")
+	}
+	for _, field := range []struct {
+		name string
+		key  []byte
+		len  int
+	}{
+		{"SharedSecret", keys.SharedSecret, 32},
+		{"Representative", keys.Representative, 32},
+		{"FspKey", keys.FspKey, lengths.FspKey},
+		{"FspIv", keys.FspIv, lengths.FspIv},
+		{"VspKey", keys.VspKey, lengths.VspKey},
+		{"VspIv", keys.VspIv, lengths.VspIv},
+		{"NewMasterSecret", keys.NewMasterSecret, newMasterSecretLen},
+		{"ConjureSeed", keys.ConjureSeed, conjureSeedLen},
+	} {
+		if len(field.key) != field.len {
+			return fmt.Errorf("%v is %v bytes, expected %v", field.name, len(field.key), field.len)
+		}
+	}
+	obfs4 := keys.Obfs4Keys
+	if transport == pb.TransportType_Obfs4 && (obfs4.PrivateKey == nil || obfs4.PublicKey == nil || obfs4.NodeID == nil) {
+		return errors.New("Obfs4Keys are required by the obfs4 transport")
+	}
+	return nil
+}
+
 //
 func conjureHMAC(key []byte, str string) []byte {
 	hash := hmac.New(sha256.New, key)
@@ -1072,14 +3050,22 @@ func conjureHMAC(key []byte, str string) []byte {
 type RegError struct {
 	code uint
 	msg  string
+	err  error       // underlying error, if any
+	reg  *ConjureReg // registration no decoy took, for RegisterOnly to report the decoys
 }
 
-func (err RegError) Error() string {
+func (err *RegError) Error() string {
 	return fmt.Sprintf("Registration Error [%v]: %v", err.CodeStr(), err.msg)
 }
 
+// Unwrap - Get the underlying error, e.g. the *net.OpError of a failed decoy dial,
+// for errors.Is and errors.As. Nil if the failure had no underlying error.
+func (err *RegError) Unwrap() error {
+	return err.err
+}
+
 // CodeStr - Get desctriptor associated with error code
-func (err RegError) CodeStr() string {
+func (err *RegError) CodeStr() string {
 	switch err.code {
 	case Unreachable:
 		return "UNREACHABLE"
@@ -1089,6 +3075,14 @@ func (err RegError) CodeStr() string {
 		return "NOT_IMPLEMENTED"
 	case TLSError:
 		return "TLS_ERROR"
+	case RegistrationTimeout:
+		return "REGISTRATION_TIMEOUT"
+	case NoDecoys:
+		return "NO_DECOYS"
+	case SendStopped:
+		return "SEND_STOPPED"
+	case NoRegistrationSent:
+		return "NO_REGISTRATION_SENT"
 	default:
 		return "UNKNOWN"
 	}
@@ -1109,4 +3103,18 @@ const (
 
 	// Unknown - Error occurred without obvious explanation
 	Unknown
+
+	// RegistrationTimeout - No decoy registration completed within the registration budget
+	RegistrationTimeout
+
+	// NoDecoys - No decoys of the requested IP version to register with
+	NoDecoys
+
+	// SendStopped - Registration not sent to the decoy as it was sent to another one,
+	// see ConjureSession.StopAfterFirstRegistration
+	SendStopped
+
+	// NoRegistrationSent - Decoys were reachable, but the registration could not be sent
+	// to any of them, e.g. as every TLS handshake failed
+	NoRegistrationSent
 )
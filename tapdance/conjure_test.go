@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	stdtls "crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,13 +18,21 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	pb "github.com/dimuls/gotapdance/protobuf"
 	tls "github.com/refraction-networking/utls"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/yawning/obfs4.git/transports/obfs4"
 )
 
 func TestTLSFailure(t *testing.T) {
@@ -79,6 +91,59 @@ func TestSelectBoth(t *testing.T) {
 	}
 }
 
+func TestPhantomForSeed(t *testing.T) {
+	seed := []byte{
+		0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7,
+		0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
+	}
+	subnets := []string{"192.122.190.0/24", "2001:48a8:687f:1::/64"}
+
+	phantom4, err := PhantomForSeed(seed, subnets, false)
+	require.Nil(t, err)
+	require.Equal(t, "192.122.190.194", phantom4.String())
+
+	phantom6, err := PhantomForSeed(seed, subnets, true)
+	require.Nil(t, err)
+	require.Equal(t, "2001:48a8:687f:1:41d3:ff12:45b:73c8", phantom6.String())
+
+	// same seed, same answer
+	again, err := PhantomForSeed(seed, subnets, true)
+	require.Nil(t, err)
+	require.True(t, phantom6.Equal(again))
+
+	_, err = PhantomForSeed(seed, []string{"192.122.190.0/24"}, true)
+	require.NotNil(t, err)
+
+	_, err = PhantomForSeed(seed, []string{"not a subnet"}, false)
+	require.NotNil(t, err)
+}
+
+func TestSessionTestConjureSeed(t *testing.T) {
+	AssetsSetDir("./assets")
+	seed := []byte{
+		0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7,
+		0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
+	}
+
+	// every session with the seed selects the phantoms of TestSelectBoth
+	for i := 0; i < 2; i++ {
+		session := makeConjureSession("1.2.3.4:1234", pb.TransportType_Min)
+		require.NotNil(t, session)
+		session.TestConjureSeed = seed
+		reg, err := session.newConjureReg()
+		require.Nil(t, err)
+		require.Equal(t, "192.122.190.194", reg.phantom4.String())
+		require.Equal(t, "2001:48a8:687f:1:41d3:ff12:45b:73c8", reg.phantom6.String())
+	}
+
+	// the keys are untouched
+	session := makeConjureSession("1.2.3.4:1234", pb.TransportType_Min)
+	require.NotNil(t, session)
+	session.TestConjureSeed = seed
+	require.NotEqual(t, seed, session.Keys.ConjureSeed)
+	require.Equal(t, seed, session.conjureSeed())
+}
+
 func TestConjureHMAC(t *testing.T) {
 	// generated using
 	// echo "customString" | hmac256 "1abcd2efgh3ijkl4"
@@ -103,7 +168,7 @@ func TestConjureHMAC(t *testing.T) {
 
 func TestGenerateKeys(t *testing.T) {
 	fakePubkey := [32]byte{0}
-	keys, err := generateSharedKeys(fakePubkey)
+	keys, err := generateSharedKeys(fakePubkey, nil, DefaultKeyLengths)
 	if err != nil {
 		t.Fatalf("Failed to generate Conjure Keys: %v", err)
 	}
@@ -112,6 +177,58 @@ func TestGenerateKeys(t *testing.T) {
 	}
 }
 
+func TestMakeConjureSessionWithKeys(t *testing.T) {
+	keys, err := generateSharedKeys([32]byte{0}, nil, DefaultKeyLengths)
+	require.Nil(t, err)
+
+	session, err := makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Min, keys)
+	require.Nil(t, err)
+	require.True(t, session.Keys == keys)
+	require.Equal(t, "1.2.3.4:443", session.CovertAddress)
+
+	short := *keys
+	short.VspIv = short.VspIv[:8]
+	_, err = makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Min, &short)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "VspIv is 8 bytes, expected 12")
+
+	noObfs4 := *keys
+	noObfs4.Obfs4Keys = Obfs4Keys{}
+	_, err = makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Min, &noObfs4)
+	require.Nil(t, err)
+	_, err = makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Obfs4, &noObfs4)
+	require.NotNil(t, err)
+
+	_, err = makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Min, nil)
+	require.NotNil(t, err)
+}
+
+func TestSessionSecretFingerprint(t *testing.T) {
+	session, err := makeConjureSessionWithKeys("1.2.3.4:443", pb.TransportType_Min, &sharedKeys{
+		SharedSecret:    append([]byte{0xab, 0xcd, 0xef, 0x12}, make([]byte, 28)...),
+		Representative:  bytes.Repeat([]byte{1}, 32),
+		FspKey:          make([]byte, 16),
+		FspIv:           make([]byte, 12),
+		VspKey:          make([]byte, 16),
+		VspIv:           make([]byte, 12),
+		NewMasterSecret: make([]byte, 48),
+		ConjureSeed:     make([]byte, 16),
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "abcdef", session.SecretFingerprint())
+	require.Equal(t, "abcdef", IDFingerprint(session.IDString()))
+	require.Equal(t, strings.Repeat("01", 32), session.Representative())
+	require.True(t, session.MatchesSecretPrefix("ABCDEF12"))
+	require.False(t, session.MatchesSecretPrefix("abcd"))
+	require.False(t, session.MatchesSecretPrefix("abcdef13"))
+
+	require.Equal(t, "", IDFingerprint("[3-000000]"))
+	require.Equal(t, "", IDFingerprint("[fixed]"))
+	require.Equal(t, "", (&ConjureSession{}).SecretFingerprint())
+	require.Equal(t, "[0-000000]", (&ConjureSession{}).IDString())
+}
+
 func TestRegDigest(t *testing.T) {
 	reg := ConjureReg{}
 	soln1 := "{result:\"no stats tracked\"}"
@@ -136,6 +253,33 @@ func TestRegDigest(t *testing.T) {
 	if reg.digestStats() != soln3 {
 		t.Fatalf("Incorrect stats digest returned")
 	}
+
+	reg.setPhantomIPVersion(4)
+
+	soln4 := "{result:\"success\", tcp_to_decoy:1000, tls_to_decoy:1000, total_time_to_connect:1000, phantom:v4}"
+	if reg.digestStats() != soln4 {
+		t.Fatalf("Incorrect stats digest returned")
+	}
+}
+
+func TestRegSelectionSummary(t *testing.T) {
+	AssetsSetDir("./assets")
+	session := makeTestSession(t, "1.2.3.4:1234")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	decoys := []*pb.TLSDecoySpec{
+		pb.InitTLSDecoySpec("192.0.2.1", "a.example.com"),
+		pb.InitTLSDecoySpec("192.0.2.2", "b.example.com"),
+	}
+	summary := reg.selectionSummary(decoys)
+	require.Equal(t, fmt.Sprintf("Registering through decoys [a.example.com, b.example.com], phantoms: v4:%v, v6:%v, transport:Min",
+		reg.phantom4, reg.phantom6), summary)
+
+	// no key material
+	for _, secret := range [][]byte{session.Keys.SharedSecret, session.Keys.ConjureSeed, session.Keys.Representative} {
+		require.NotContains(t, summary, hex.EncodeToString(secret))
+	}
 }
 
 func TestCheckV6Decoys(t *testing.T) {
@@ -175,6 +319,113 @@ func TestSelectDecoys(t *testing.T) {
 	}
 }
 
+func TestSelectDecoysEmptyPool(t *testing.T) {
+	AssetsSetDir("./assets")
+	defer AssetsSetDir("./assets")
+	Assets().OverrideDecoys([]*pb.TLSDecoySpec{pb.InitTLSDecoySpec("192.0.2.1", "example.com")}, 1)
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	decoys, err := SelectDecoys(session.Keys.SharedSecret, v6, 5)
+	require.Nil(t, err)
+	require.Empty(t, decoys)
+
+	session.setV6Support(v6)
+	_, err = DecoyRegistrar{}.Register(session, context.Background())
+	var regErr *RegError
+	require.True(t, errors.As(err, &regErr), "unexpected error: %v", err)
+	require.Equal(t, "NO_DECOYS", regErr.CodeStr())
+	require.Contains(t, err.Error(), "no decoys for requested IP version (V6)")
+}
+
+func TestSelectDecoysWithoutAddress(t *testing.T) {
+	AssetsSetDir("./assets")
+	defer AssetsSetDir("./assets")
+	noIP := &pb.TLSDecoySpec{Hostname: proto.String("noip.example.com")}
+	valid := pb.InitTLSDecoySpec("192.0.2.1", "example.com")
+	Assets().OverrideDecoys([]*pb.TLSDecoySpec{noIP, valid}, 1)
+
+	// decoys without an address are never selected
+	session := makeTestSession(t, "1.2.3.4:1234")
+	decoys, err := SelectDecoys(session.Keys.SharedSecret, both, 5)
+	require.Nil(t, err)
+	require.Len(t, decoys, 5)
+	for _, decoy := range decoys {
+		require.Equal(t, valid, decoy)
+	}
+	rotated, err := selectDecoysOffset(session.Keys.SharedSecret, both, 1, 7)
+	require.Nil(t, err)
+	require.Equal(t, []*pb.TLSDecoySpec{valid}, rotated)
+
+	// nor dialed when given by a DecoySelector
+	var dials int32
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return nil, errors.New("unexpected dial")
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	dialErrors := make(chan error, 1)
+	reg.sends.Add(1)
+	reg.send(context.Background(), noIP, dialErrors, nil)
+	err = <-dialErrors
+	require.Contains(t, err.Error(), `decoy "noip.example.com" has no IP address`)
+	require.Zero(t, atomic.LoadInt32(&dials))
+}
+
+func TestSelectRotatedDecoys(t *testing.T) {
+	AssetsSetDir("./assets")
+	defer AssetsSetDir("./assets")
+	var all []*pb.TLSDecoySpec
+	for i := 1; i <= 10; i++ {
+		all = append(all, pb.InitTLSDecoySpec(fmt.Sprintf("192.0.2.%d", i), "example.com"))
+	}
+	Assets().OverrideDecoys(all, 1)
+
+	// the offset shifts the decoys selected from the shared secret
+	session := makeTestSession(t, "1.2.3.4:1234")
+	selected, err := SelectDecoys(session.Keys.SharedSecret, v4, 3)
+	require.Nil(t, err)
+	rotated, err := selectDecoysOffset(session.Keys.SharedSecret, v4, 3, 12)
+	require.Nil(t, err)
+	require.Len(t, rotated, 3)
+	for i := range selected {
+		require.Equal(t, all[(indexOfDecoy(all, selected[i])+12)%10], rotated[i])
+	}
+
+	// successive sessions take offsets Width apart, each drawn once
+	var previous uint64
+	for i := 0; i < 3; i++ {
+		session := makeTestSession(t, "1.2.3.4:1234")
+		session.Width = 3
+		rotations := make(chan uint64, 4)
+		for j := 0; j < 4; j++ {
+			go func() { rotations <- session.getDecoyRotation() }()
+		}
+		rotation := <-rotations
+		for j := 1; j < 4; j++ {
+			require.Equal(t, rotation, <-rotations)
+		}
+		if i > 0 {
+			require.Equal(t, previous+3, rotation)
+		}
+		previous = rotation
+	}
+
+	rotated, err = selectDecoysOffset(session.Keys.SharedSecret, v6, 3, 12)
+	require.Nil(t, err)
+	require.Empty(t, rotated)
+}
+
+// indexOfDecoy - Index of decoy in decoys, -1 if absent
+func indexOfDecoy(decoys []*pb.TLSDecoySpec, decoy *pb.TLSDecoySpec) int {
+	for i, d := range decoys {
+		if d == decoy {
+			return i
+		}
+	}
+	return -1
+}
+
 func copyFile(fromFile string, toFile string) error {
 	from, err := os.Open(fromFile)
 	if err != nil {
@@ -299,3 +550,1628 @@ func TestAPIRegistrar(t *testing.T) {
 
 	server.Close()
 }
+
+func TestAPIRegistrarFailover(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	var m sync.Mutex
+	hits := make(map[string]int)
+	handler := func(name string, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.Lock()
+			hits[name]++
+			m.Unlock()
+			w.WriteHeader(status)
+		})
+	}
+	down := httptest.NewTLSServer(handler("down", http.StatusServiceUnavailable))
+	defer down.Close()
+	up := httptest.NewTLSServer(handler("up", http.StatusOK))
+	defer up.Close()
+
+	// each endpoint is retried MaxRetries times before the next one
+	registrar := APIRegistrar{
+		Endpoint:     down.URL,
+		Endpoints:    []string{up.URL},
+		Client:       up.Client(),
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}
+	reg, err := registrar.Register(makeTestSession(t, "1.2.3.4:1234"), context.Background())
+	require.Nil(t, err)
+	require.Equal(t, up.URL, reg.APIEndpoint())
+	require.Contains(t, reg.Digest(), "registered on API endpoint "+up.URL)
+	require.Equal(t, map[string]int{"down": 3, "up": 1}, hits)
+
+	// the secondary registrar is used once every endpoint failed
+	secondary := &failingRegistrar{}
+	registrar = APIRegistrar{
+		Endpoints:          []string{down.URL, down.URL},
+		Client:             down.Client(),
+		RetryBackoff:       -1,
+		SecondaryRegistrar: secondary,
+	}
+	session := makeTestSession(t, "1.2.3.4:1234")
+	_, err = registrar.Register(session, context.Background())
+	require.Contains(t, err.Error(), "test registrar always fails")
+	require.Equal(t, session, secondary.session)
+	require.Equal(t, 5, hits["down"])
+
+	registrar = APIRegistrar{Endpoint: "a", Endpoints: []string{"b", "c", "d"}, RandomizeEndpoints: true}
+	require.ElementsMatch(t, []string{"a", "b", "c", "d"}, registrar.endpoints())
+	registrar.RandomizeEndpoints = false
+	require.Equal(t, []string{"a", "b", "c", "d"}, registrar.endpoints())
+}
+
+func TestAPIRegistrarConnectionDelay(t *testing.T) {
+	registrar := APIRegistrar{ConnectionDelay: 750 * time.Millisecond}
+	require.Equal(t, 750*time.Millisecond, registrar.connectionDelay())
+
+	registrar.ConnectionDelay = 500 * time.Millisecond
+	registrar.ConnectionDelayMax = time.Second
+	delays := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		delay := registrar.connectionDelay()
+		require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		require.LessOrEqual(t, delay, time.Second)
+		delays[delay] = true
+	}
+	require.Greater(t, len(delays), 1)
+}
+
+func TestAPIRegistrarRejection(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "invalid covert address", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	// a rejection is not retried, and reports the status and response
+	registrar := APIRegistrar{Endpoint: server.URL, Client: server.Client(), MaxRetries: 3, RetryBackoff: -1}
+	_, err := registrar.Register(makeTestSession(t, "1.2.3.4:1234"), context.Background())
+	var apiErr APIRegistrationError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Equal(t, "invalid covert address\n", apiErr.Body)
+	require.False(t, apiErr.Temporary())
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	require.True(t, APIRegistrationError{StatusCode: http.StatusBadGateway}.Temporary())
+	require.True(t, APIRegistrationError{StatusCode: http.StatusTooManyRequests}.Temporary())
+
+	// network errors are returned as they are
+	server.Close()
+	_, err = registrar.Register(makeTestSession(t, "1.2.3.4:1234"), context.Background())
+	require.NotNil(t, err)
+	require.False(t, errors.As(err, &apiErr))
+}
+
+// failingRegistrar records the session it was asked to register and fails.
+type failingRegistrar struct {
+	session *ConjureSession
+}
+
+func (r *failingRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
+	r.session = cjSession
+	return nil, fmt.Errorf("test registrar always fails")
+}
+
+func TestDialConjureForceV6(t *testing.T) {
+	registrar := &failingRegistrar{}
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.setV6Support(v6)
+	_, err := DialConjure(context.Background(), session, registrar)
+	require.NotNil(t, err)
+	require.Equal(t, v6, registrar.session.V6Support.include)
+
+	session = makeConjureSession("1.2.3.4:1234", pb.TransportType_Min)
+	session.setV6Support(v4)
+	_, err = DialConjure(context.Background(), session, registrar)
+	require.NotNil(t, err)
+	require.Equal(t, both, registrar.session.V6Support.include)
+}
+
+func TestGetFirstConnection(t *testing.T) {
+	reg := &ConjureReg{sessionIDStr: "[test]", phantom4: ipPtr("1.1.1.1"), phantom6: ipPtr("2001:db8::1")}
+
+	// Only the v6 phantom accepts connections.
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr != "[2001:db8::1]:443" {
+			return nil, fmt.Errorf("unreachable %s", addr)
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	require.Equal(t, uint(0), reg.PhantomIPVersion())
+	conn, err := reg.getFirstConnection(context.Background(), dialer, []net.IP{*reg.phantom4, *reg.phantom6})
+	require.Nil(t, err)
+	require.NotNil(t, conn)
+	conn.Close()
+	require.Equal(t, uint(6), reg.PhantomIPVersion())
+	require.Contains(t, reg.digestStats(), "phantom:v6")
+
+	_, err = reg.getFirstConnection(context.Background(), dialer, []net.IP{*reg.phantom4})
+	require.NotNil(t, err)
+}
+
+func TestConnectPhantomDialAttempts(t *testing.T) {
+	// dialer - Fail the first failures dials, the first of them by timing out
+	dialer := func(failures int, dials *int) dialFunc {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			*dials++
+			switch {
+			case *dials == 1 && failures > 0:
+				<-ctx.Done()
+				return nil, ctx.Err()
+			case *dials <= failures:
+				return nil, fmt.Errorf("connection reset")
+			}
+			conn, _ := net.Pipe()
+			return conn, nil
+		}
+	}
+	connect := func(attempts uint, failures int) (int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+		defer cancel()
+		dials := 0
+		reg := &ConjureReg{sessionIDStr: "[test]", phantomAttempts: attempts}
+		conn, err := reg.connect(ctx, "1.1.1.1", dialer(failures, &dials))
+		if conn != nil {
+			conn.Close()
+		}
+		return dials, err
+	}
+
+	// a single dial by default
+	dials, err := connect(0, 1)
+	require.NotNil(t, err)
+	require.Equal(t, 1, dials)
+
+	// the first attempt times out with its share of the deadline, leaving time
+	// for the others
+	dials, err = connect(3, 2)
+	require.Nil(t, err)
+	require.Equal(t, 3, dials)
+
+	dials, err = connect(2, 2)
+	require.NotNil(t, err)
+	require.Equal(t, 2, dials)
+}
+
+// makeTestSession - Create a session whose seed selects phantoms of both families.
+// Seeds landing in a weighted subnet group without v6 subnets fail phantom selection.
+func makeTestSession(t testing.TB, covert string) *ConjureSession {
+	for i := 0; i < 100; i++ {
+		session := makeConjureSession(covert, pb.TransportType_Min)
+		require.NotNil(t, session)
+		if _, _, err := SelectPhantom(session.Keys.ConjureSeed, both); err == nil {
+			return session
+		}
+	}
+	t.Fatal("failed to find a seed selecting phantoms")
+	return nil
+}
+
+func ipPtr(s string) *net.IP {
+	ip := net.ParseIP(s)
+	return &ip
+}
+
+func TestRegisterOnlyDigest(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 2
+	session.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, fmt.Errorf("test dialer always fails")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// no decoy took the registration, whose digest reports why
+	reg, err := RegisterOnly(ctx, session, nil)
+	regErr, ok := err.(*RegError)
+	require.True(t, ok)
+	require.Equal(t, uint(NoRegistrationSent), regErr.code)
+	require.Equal(t, 2, len(reg.decoyResults))
+
+	digest := reg.Digest()
+	require.Contains(t, digest, "phantoms: v4:")
+	require.Contains(t, digest, "failed: Registration Error [DIAL_FAILURE]: test dialer always fails")
+}
+
+func TestGenerateVSPStateTransition(t *testing.T) {
+	reg := &ConjureReg{covertAddress: "1.2.3.4:1234"}
+	vsp, err := reg.generateVSP()
+	require.Nil(t, err)
+	c2s := &pb.ClientToStation{}
+	require.Nil(t, proto.Unmarshal(vsp, c2s))
+	require.Equal(t, pb.C2S_Transition_C2S_SESSION_COVERT_INIT, c2s.GetStateTransition())
+	require.Equal(t, "1.2.3.4:1234", c2s.GetCovertAddress())
+
+	reg = &ConjureReg{}
+	vsp, err = reg.generateVSP()
+	require.Nil(t, err)
+	c2s = &pb.ClientToStation{}
+	require.Nil(t, proto.Unmarshal(vsp, c2s))
+	require.Equal(t, pb.C2S_Transition_C2S_SESSION_INIT, c2s.GetStateTransition())
+	require.Nil(t, c2s.CovertAddress)
+}
+
+func TestGenerateVSPPadding(t *testing.T) {
+	reg := &ConjureReg{covertAddress: "1.2.3.4:1234"}
+	vsp, err := reg.generateVSP()
+	require.Nil(t, err)
+	require.Equal(t, 0, (len(vsp)+AES_GCM_TAG_SIZE)%3)
+	minimal := len(vsp)
+
+	reg = &ConjureReg{covertAddress: "1.2.3.4:1234", paddingMin: 50, paddingMax: 200}
+	sizes := map[int]bool{}
+	for i := 0; i < 20; i++ {
+		vsp, err = reg.generateVSP()
+		require.Nil(t, err)
+		require.Equal(t, 0, (len(vsp)+AES_GCM_TAG_SIZE)%3)
+		require.GreaterOrEqual(t, len(vsp), minimal+50)
+		require.LessOrEqual(t, len(vsp), minimal+200+5)
+		sizes[len(vsp)] = true
+	}
+	require.Greater(t, len(sizes), 1)
+
+	// alignment not divisible by 3 still keeps the mod 3 requirement
+	reg = &ConjureReg{covertAddress: "1.2.3.4:1234", paddingAlign: 16}
+	vsp, err = reg.generateVSP()
+	require.Nil(t, err)
+	require.Equal(t, 0, (len(vsp)+AES_GCM_TAG_SIZE)%48)
+}
+
+func TestGenerateFSPVersion(t *testing.T) {
+	reg := &ConjureReg{}
+	fsp := reg.generateFSP(1234)
+	require.Equal(t, fspSize, len(fsp))
+	require.Equal(t, uint16(1234), binary.BigEndian.Uint16(fsp[0:2]))
+	require.Equal(t, byte(currentPayloadVersion), fsp[fspVersionOffset])
+
+	// the current version is the implicit one of stations without version byte
+	require.Equal(t, make([]byte, fspSize-2), fsp[2:])
+}
+
+func TestCreateTLSConnContextCancel(t *testing.T) {
+	// decoy that accepts TCP but never answers the ClientHello
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	dialConn, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	defer dialConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	reg := &ConjureReg{}
+	start := time.Now()
+	_, err = reg.createTLSConn(ctx, dialConn, l.Addr().String(), "", true, time.Now().Add(time.Minute))
+	require.Equal(t, context.Canceled, err)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestRegisterDecoyDialer(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 2
+	session.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, fmt.Errorf("phantom dialer must not be used for decoys")
+	}
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, fmt.Errorf("decoy dialer used for %s", address)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	reg, err := RegisterOnly(ctx, session, nil)
+	require.NotNil(t, err)
+	require.Equal(t, 2, len(reg.decoyResults))
+	for _, result := range reg.decoyResults {
+		require.Contains(t, result.err.Error(), "decoy dialer used for")
+	}
+}
+
+func TestRegisterAllTLSHandshakesFail(t *testing.T) {
+	// decoys accept TCP but answer the ClientHello with plaintext
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 3
+	session.NoRegistrationSleep = true
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, l.Addr().String())
+	}
+	session.DecoySelector = func(*ConjureSession, []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+		return []*pb.TLSDecoySpec{
+			pb.InitTLSDecoySpec("10.0.0.1", "a.example.com"),
+			pb.InitTLSDecoySpec("10.0.0.2", "b.example.com"),
+			pb.InitTLSDecoySpec("10.0.0.3", "c.example.com"),
+		}, nil
+	}
+
+	// every decoy was reachable, yet no registration was sent: not Unreachable
+	reg, err := DecoyRegistrar{}.Register(session, context.Background())
+	regErr, ok := err.(*RegError)
+	require.True(t, ok, "%v", err)
+	require.Equal(t, uint(NoRegistrationSent), regErr.code)
+	require.Equal(t, "NO_REGISTRATION_SENT", regErr.CodeStr())
+	require.Nil(t, reg)
+
+	// the error carries the registration for the outcome of each decoy
+	reg = regErr.reg
+	require.False(t, reg.anyDecoySucceeded())
+	require.Equal(t, 3, len(reg.decoyResults))
+	for _, result := range reg.decoyResults {
+		var decoyErr *RegError
+		require.True(t, errors.As(result.err, &decoyErr))
+		require.Equal(t, uint(TLSError), decoyErr.code)
+	}
+
+	// and no registration is returned when the sends failed on a cancelled ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reg, err = DecoyRegistrar{}.Register(session, ctx)
+	require.Nil(t, reg)
+	require.NotNil(t, err)
+}
+
+func TestConjureSessionCloseNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// decoys accept TCP but stall the TLS handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	var decoyConns []net.Conn
+	var decoyConnsMu sync.Mutex
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			decoyConnsMu.Lock()
+			decoyConns = append(decoyConns, c)
+			decoyConnsMu.Unlock()
+		}
+	}()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 2
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, l.Addr().String())
+	}
+
+	regErr := make(chan error, 1)
+	go func() {
+		_, err := DecoyRegistrar{}.Register(session, context.Background())
+		regErr <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	require.Nil(t, session.Close())
+	require.Nil(t, session.Close())
+	select {
+	case err = <-regErr:
+		require.Equal(t, errSessionClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Register did not return after session Close")
+	}
+
+	// lingering decoy reads stop as well
+	client, server := net.Pipe()
+	defer server.Close()
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	readDone := make(chan struct{})
+	go func() {
+		reg.readAndClose(client, 15*time.Second)
+		close(readDone)
+	}()
+	select {
+	case <-readDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readAndClose did not return after session Close")
+	}
+
+	l.Close()
+	decoyConnsMu.Lock()
+	for _, c := range decoyConns {
+		c.Close()
+	}
+	decoyConnsMu.Unlock()
+
+	// leak check: wait for goroutines to wind down to where we started
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), before, "goroutines leaked after session Close")
+}
+
+// fixedPhantomSelector maps every seed into a fixed /16 or /112, by the first seed bytes
+type fixedPhantomSelector struct {
+	seeds [][]byte
+}
+
+func (s *fixedPhantomSelector) Select(seed []byte, v6 bool) (*net.IP, error) {
+	s.seeds = append(s.seeds, seed)
+	ip := net.IPv4(10, 0, seed[1], seed[0])
+	if v6 {
+		ip = net.ParseIP("2001:db8::")
+		ip[14], ip[15] = seed[1], seed[0]
+	}
+	return &ip, nil
+}
+
+func TestPhantomSelector(t *testing.T) {
+	seed := []byte{
+		0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7,
+		0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
+	}
+
+	// the default selector is what SelectPhantom uses
+	phantom4, phantom6, err := SelectPhantom(seed, both)
+	require.Nil(t, err)
+	selected4, err := AssetsPhantomSelector{}.Select(seed, false)
+	require.Nil(t, err)
+	selected6, err := AssetsPhantomSelector{}.Select(seed, true)
+	require.Nil(t, err)
+	require.Equal(t, phantom4.String(), selected4.String())
+	require.Equal(t, phantom6.String(), selected6.String())
+
+	// a session uses its own selector, for each family
+	selector := &fixedPhantomSelector{}
+	session := makeConjureSession("1.2.3.4:1234", pb.TransportType_Min)
+	session.PhantomSelector = selector
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(selector.seeds))
+	require.Equal(t, session.Keys.ConjureSeed, selector.seeds[0])
+	seed = session.Keys.ConjureSeed
+	require.Equal(t, net.IPv4(10, 0, seed[1], seed[0]).String(), reg.phantom4.String())
+	require.Equal(t, "2001:db8::", reg.phantom6.Mask(net.CIDRMask(64, 128)).String())
+}
+
+func TestConjureRegStatsGetters(t *testing.T) {
+	reg := &ConjureReg{}
+	require.Equal(t, time.Duration(0), reg.TCPToDecoy())
+	require.Equal(t, time.Duration(0), reg.TLSToDecoy())
+	require.Equal(t, time.Duration(0), reg.TotalTimeToConnect())
+
+	reg.setTCPToDecoy(durationToU32ptrMs(42 * time.Millisecond))
+	reg.setTLSToDecoy(durationToU32ptrMs(84 * time.Millisecond))
+	require.Equal(t, 42*time.Millisecond, reg.TCPToDecoy())
+	require.Equal(t, 84*time.Millisecond, reg.TLSToDecoy())
+
+	// total time is recorded once the phantom connects
+	reg.transport = pb.TransportType_Null
+	reg.startTs = time.Now().Add(-time.Second)
+	reg.TcpDialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	reg.phantom4 = ipPtr("1.1.1.1")
+	conn, err := reg.Connect(context.Background())
+	require.Nil(t, err)
+	conn.Close()
+	require.GreaterOrEqual(t, int64(reg.TotalTimeToConnect()), int64(time.Second))
+	require.Less(t, int64(reg.TotalTimeToConnect()), int64(10*time.Second))
+}
+
+func TestCreateRequestDump(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var b bytes.Buffer
+	testLogger := logrus.New()
+	testLogger.Out = &b
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Logger = testLogger
+	decoy := pb.InitTLSDecoySpec("127.0.0.1", "dump.test")
+
+	for _, dump := range []bool{false, true} {
+		session.DumpRegistrations = dump
+		reg, err := session.newConjureReg()
+		require.Nil(t, err)
+
+		dialConn, err := net.Dial("tcp", server.Listener.Addr().String())
+		require.Nil(t, err)
+		tlsConn := tls.UClient(dialConn, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}, tls.HelloGolang)
+		require.Nil(t, tlsConn.Handshake())
+
+		b.Reset()
+		request, err := reg.createRequest(tlsConn, decoy)
+		require.Nil(t, err)
+		tlsConn.Close()
+
+		if !dump {
+			require.NotContains(t, b.String(), "registration dump")
+			continue
+		}
+		require.Contains(t, b.String(), "registration dump for dump.test")
+		require.Contains(t, b.String(), fmt.Sprintf("request: %x", request))
+		require.NotContains(t, b.String(), fmt.Sprintf("%x", session.Keys.SharedSecret))
+		require.NotContains(t, b.String(), fmt.Sprintf("%x", session.Keys.VspKey))
+	}
+}
+
+func TestValidateCovertAddress(t *testing.T) {
+	valid := []string{
+		"",
+		"1.2.3.4:443",
+		"[2001:db8::1]:80",
+		"example.com:8080",
+		"my-host.example.com.:65535",
+		"localhost:1",
+	}
+	for _, addr := range valid {
+		require.Nil(t, validateCovertAddress(addr), addr)
+	}
+
+	invalid := []string{
+		"1.2.3.4",
+		"1.2.3.4:",
+		":443",
+		"1.2.3.4:0",
+		"1.2.3.4:65536",
+		"1.2.3.4:http",
+		"2001:db8::1:80",
+		"-bad.example.com:443",
+		"bad..example.com:443",
+		"bad host:443",
+	}
+	for _, addr := range invalid {
+		require.NotNil(t, validateCovertAddress(addr), addr)
+	}
+
+	d := Dialer{DarkDecoy: true}
+	_, err := d.DialContext(context.Background(), "tcp", "1.2.3.4:99999")
+	require.Contains(t, err.Error(), "invalid covert address")
+}
+
+func TestObfs4Args(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:443")
+	keys := session.Keys.Obfs4Keys
+
+	// without parameters everything but iat-mode is derived from the session keys
+	args, err := session.Obfs4Args()
+	require.Nil(t, err)
+	nodeID, _ := args.Get("node-id")
+	publicKey, _ := args.Get("public-key")
+	iatMode, _ := args.Get("iat-mode")
+	require.Equal(t, keys.NodeID.Hex(), nodeID)
+	require.Equal(t, keys.PublicKey.Hex(), publicKey)
+	require.Equal(t, "1", iatMode)
+
+	id := bytes.Repeat([]byte{0xaa}, 20)
+	session.Obfs4Params = &pb.Obfs4Params{NodeId: id, IatMode: proto.Uint32(0)}
+	args, err = session.Obfs4Args()
+	require.Nil(t, err)
+	nodeID, _ = args.Get("node-id")
+	publicKey, _ = args.Get("public-key")
+	iatMode, _ = args.Get("iat-mode")
+	require.Equal(t, hex.EncodeToString(id), nodeID)
+	require.Equal(t, keys.PublicKey.Hex(), publicKey)
+	require.Equal(t, "0", iatMode)
+
+	// the args are accepted by the obfs4 client factory
+	cf, err := (&obfs4.Transport{}).ClientFactory("")
+	require.Nil(t, err)
+	_, err = cf.ParseArgs(args)
+	require.Nil(t, err)
+
+	session.Obfs4Params = &pb.Obfs4Params{PublicKey: []byte{1, 2, 3}}
+	_, err = session.Obfs4Args()
+	require.NotNil(t, err)
+	session.Obfs4Params = &pb.Obfs4Params{IatMode: proto.Uint32(3)}
+	_, err = session.Obfs4Args()
+	require.NotNil(t, err)
+}
+
+func TestSelectSessionPhantoms(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.PhantomSelector = PhantomSelectorV1{Subnets: &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Weight: proto.Uint32(1), Subnets: []string{"10.0.0.0/8", "2001:db8::/32"}}},
+	}}
+
+	phantom4, phantom6, err := session.SelectSessionPhantoms()
+	require.Nil(t, err)
+	require.NotNil(t, phantom4)
+	require.NotNil(t, phantom6)
+
+	// they are the phantoms a registration connects to
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, phantom4.String(), reg.phantom4.String())
+	require.Equal(t, phantom6.String(), reg.phantom6.String())
+
+	// the selection from the assets is traced to the session logger
+	var b bytes.Buffer
+	testLogger := logrus.New()
+	testLogger.Out = &b
+	testLogger.Level = logrus.TraceLevel
+	session.PhantomSelector = nil
+	session.Logger = testLogger
+	phantom4, _, err = session.SelectSessionPhantoms()
+	require.Nil(t, err)
+	require.Contains(t, b.String(), "-> "+phantom4.String())
+}
+
+func TestRegistrationTimeout(t *testing.T) {
+	// decoys accept TCP but never complete the TLS handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 2
+	session.RegistrationTimeout = 200 * time.Millisecond
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, l.Addr().String())
+	}
+
+	start := time.Now()
+	_, err = DecoyRegistrar{}.Register(session, context.Background())
+	require.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	regErr, ok := err.(*RegError)
+	require.True(t, ok, "unexpected error: %v", err)
+	require.Equal(t, "REGISTRATION_TIMEOUT", regErr.CodeStr())
+}
+
+func TestGetStationKey(t *testing.T) {
+	assetsKey := *Assets().GetConjurePubkey()
+	envKey := bytes.Repeat([]byte{0x11}, 32)
+	explicitKey := bytes.Repeat([]byte{0x22}, 32)
+
+	key, err := getStationKey(nil, Logger())
+	require.Nil(t, err)
+	require.Equal(t, assetsKey, key)
+
+	t.Setenv(StationPubkeyEnv, hex.EncodeToString(envKey))
+	key, err = getStationKey(nil, Logger())
+	require.Nil(t, err)
+	require.Equal(t, envKey, key[:])
+
+	// an explicit key takes precedence over the environment
+	key, err = getStationKey(explicitKey, Logger())
+	require.Nil(t, err)
+	require.Equal(t, explicitKey, key[:])
+	_, err = getStationKey(explicitKey[:31], Logger())
+	require.NotNil(t, err)
+
+	t.Setenv(StationPubkeyEnv, "not hex")
+	_, err = getStationKey(nil, Logger())
+	require.NotNil(t, err)
+	d := Dialer{DarkDecoy: true}
+	_, err = d.makeConjureSession("1.2.3.4:443")
+	require.Contains(t, err.Error(), StationPubkeyEnv)
+
+	t.Setenv(StationPubkeyEnv, hex.EncodeToString(envKey[:16]))
+	_, err = getStationKey(nil, Logger())
+	require.NotNil(t, err)
+}
+
+// stubRegistrar registers without contacting decoys
+type stubRegistrar struct {
+	registrations int
+}
+
+func (r *stubRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
+	r.registrations++
+	return cjSession.newConjureReg()
+}
+
+func TestDialConjureConnectRetries(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Transport = pb.TransportType_Null
+	session.V6Support.include = v6 // kept by DialConjure: one phantom dial per attempt
+	session.ConnectRetryBackoff = time.Millisecond
+
+	var dials int
+	failDials := 2
+	session.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials++
+		if dials <= failDials {
+			return nil, fmt.Errorf("connection reset")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	// without retries the phantom failure is reported as such
+	registrar := &stubRegistrar{}
+	_, err := DialConjure(context.Background(), session, registrar)
+	unreachable, ok := err.(*PhantomUnreachableError)
+	require.True(t, ok, "unexpected error: %v", err)
+	require.Equal(t, uint(1), unreachable.Attempts)
+
+	dials = 0
+	session.ConnectRetries = 2
+	session.ReregisterOnRetry = true
+	registrar = &stubRegistrar{}
+	conn, err := DialConjure(context.Background(), session, registrar)
+	require.Nil(t, err)
+	conn.Close()
+	require.Equal(t, 3, dials)
+	require.Equal(t, 3, registrar.registrations)
+
+	dials = 0
+	failDials = 5
+	session.ReregisterOnRetry = false
+	registrar = &stubRegistrar{}
+	_, err = DialConjure(context.Background(), session, registrar)
+	unreachable, ok = err.(*PhantomUnreachableError)
+	require.True(t, ok, "unexpected error: %v", err)
+	require.Equal(t, uint(3), unreachable.Attempts)
+	require.Equal(t, 1, registrar.registrations)
+}
+
+func TestDecoyMinTLSVersion(t *testing.T) {
+	// misconfigured decoy offering only TLS 1.0
+	decoy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	decoy.TLS = &stdtls.Config{MinVersion: stdtls.VersionTLS10, MaxVersion: stdtls.VersionTLS10}
+	decoy.StartTLS()
+	defer decoy.Close()
+
+	dialConn, err := net.Dial("tcp", decoy.Listener.Addr().String())
+	require.Nil(t, err)
+	defer dialConn.Close()
+	tlsConn := tls.UClient(dialConn, &tls.Config{ServerName: "example.com", InsecureSkipVerify: true}, tls.HelloChrome_62)
+	require.Nil(t, tlsConn.Handshake())
+	version := tlsConn.ConnectionState().Version
+	require.Equal(t, uint16(tls.VersionTLS10), version)
+
+	// rejected by default, unless the minimum is lowered
+	err = (&ConjureReg{}).checkDecoyTLSVersion(version)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "below the minimum")
+	require.Nil(t, (&ConjureReg{decoyMinTLSVersion: tls.VersionTLS10}).checkDecoyTLSVersion(version))
+	require.Nil(t, (&ConjureReg{}).checkDecoyTLSVersion(tls.VersionTLS12))
+}
+
+func TestCreateTLSConnNoSNI(t *testing.T) {
+	serverNames := make(chan string, 1)
+	decoy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	decoy.TLS = &stdtls.Config{
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			serverNames <- hello.ServerName
+			return nil, nil
+		},
+	}
+	decoy.StartTLS()
+	defer decoy.Close()
+
+	// the test certificate does not verify, but the server sees the hello first
+	sniSent := func(hostname string, noSNI bool) string {
+		dialConn, err := net.Dial("tcp", decoy.Listener.Addr().String())
+		require.Nil(t, err)
+		defer dialConn.Close()
+		reg := &ConjureReg{verifyDecoyCerts: true}
+		_, err = reg.createTLSConn(context.Background(), dialConn, decoy.Listener.Addr().String(), hostname, noSNI, time.Now().Add(5*time.Second))
+		require.NotNil(t, err)
+		return <-serverNames
+	}
+
+	require.Equal(t, "example.com", sniSent("example.com", false))
+	require.Equal(t, "", sniSent("", true))
+}
+
+func TestVerifyDecoyCertificates(t *testing.T) {
+	decoy := httptest.NewTLSServer(http.NotFoundHandler())
+	defer decoy.Close()
+
+	handshake := func(reg *ConjureReg) error {
+		dialConn, err := net.Dial("tcp", decoy.Listener.Addr().String())
+		require.Nil(t, err)
+		defer dialConn.Close()
+		_, err = reg.createTLSConn(context.Background(), dialConn, decoy.Listener.Addr().String(), "example.com", false, time.Now().Add(5*time.Second))
+		return err
+	}
+
+	// the untrusted test certificate is accepted unless verifying
+	require.Nil(t, handshake(&ConjureReg{}))
+	err := handshake(&ConjureReg{verifyDecoyCerts: true})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "certificate")
+
+	// and verified against the roots when verifying
+	roots := x509.NewCertPool()
+	roots.AddCert(decoy.Certificate())
+	require.Nil(t, handshake(&ConjureReg{verifyDecoyCerts: true, decoyRootCAs: roots}))
+
+	// from the Dialer to the registration
+	d := Dialer{DarkDecoy: true, VerifyDecoyCertificates: true, DecoyRootCAs: roots}
+	session, err := d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	session.V6Support = &V6{include: v4, fixed: true}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.True(t, reg.verifyDecoyCerts)
+	require.Equal(t, roots, reg.decoyRootCAs)
+}
+
+func TestCreateTLSConnALPN(t *testing.T) {
+	alpns := make(chan []string, 1)
+	decoy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	decoy.TLS = &stdtls.Config{
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			alpns <- hello.SupportedProtos
+			return nil, nil
+		},
+	}
+	decoy.StartTLS()
+	defer decoy.Close()
+
+	alpnSent := func(decoyALPN []string) []string {
+		dialConn, err := net.Dial("tcp", decoy.Listener.Addr().String())
+		require.Nil(t, err)
+		defer dialConn.Close()
+		reg := &ConjureReg{decoyALPN: decoyALPN}
+		reg.createTLSConn(context.Background(), dialConn, decoy.Listener.Addr().String(), "example.com", false, time.Now().Add(5*time.Second))
+		return <-alpns
+	}
+
+	// the parrot offers the ALPN of Chrome by default
+	require.Equal(t, []string{"h2", "http/1.1"}, alpnSent(nil))
+	require.Equal(t, []string{"http/1.1"}, alpnSent([]string{"http/1.1"}))
+	require.Empty(t, alpnSent([]string{}))
+}
+
+func TestCovertConnectTimeout(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Nil(t, reg.generateClientToStation().CovertConnectTimeoutMs)
+
+	session.CovertConnectTimeout = 1500 * time.Millisecond
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, uint32(1500), reg.generateClientToStation().GetCovertConnectTimeoutMs())
+
+	// a covert that never answers fails the first read at the deadline
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := newCovertConnectConn(client, time.Now().Add(100*time.Millisecond))
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "covert connect timeout")
+	require.Less(t, int64(time.Since(start)), int64(2*time.Second))
+
+	// once the covert has answered, the deadline no longer applies
+	client, server = net.Pipe()
+	defer server.Close()
+	conn = newCovertConnectConn(client, time.Now().Add(100*time.Millisecond))
+	go func() {
+		server.Write([]byte{1})
+		time.Sleep(300 * time.Millisecond)
+		server.Write([]byte{2})
+	}()
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	require.Nil(t, err)
+	_, err = conn.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, byte(2), buf[0])
+}
+
+func TestDecoySelector(t *testing.T) {
+	forced := pb.InitTLSDecoySpec("10.0.0.1", "forced.example.com")
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)}
+
+	var m sync.Mutex
+	var dialed []string
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Width = 2
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		m.Lock()
+		defer m.Unlock()
+		dialed = append(dialed, address)
+		return nil, unreachable
+	}
+
+	var selected []*pb.TLSDecoySpec
+	session.DecoySelector = func(cjSession *ConjureSession, defaults []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+		require.Equal(t, session, cjSession)
+		selected = defaults
+		return []*pb.TLSDecoySpec{forced}, nil
+	}
+	_, err := DecoyRegistrar{}.Register(session, context.Background())
+	require.NotNil(t, err)
+	require.Len(t, selected, 2)
+	require.Equal(t, []*pb.TLSDecoySpec{forced}, session.RegDecoys)
+	require.Equal(t, []string{"10.0.0.1:443"}, dialed)
+
+	// a failing selector aborts the registration before any decoy is dialed
+	dialed = nil
+	session.DecoySelector = func(*ConjureSession, []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+		return nil, errors.New("no decoy for you")
+	}
+	_, err = DecoyRegistrar{}.Register(session, context.Background())
+	require.EqualError(t, err, "no decoy for you")
+	require.Empty(t, dialed)
+}
+
+// stubInterfaceAddrs - Make the given CIDRs the local addresses for the test
+func stubInterfaceAddrs(t *testing.T, cidrs ...string) {
+	var addrs []net.Addr
+	for _, cidr := range cidrs {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		require.Nil(t, err)
+		ipNet.IP = ip
+		addrs = append(addrs, ipNet)
+	}
+	original := interfaceAddrs
+	interfaceAddrs = func() ([]net.Addr, error) { return addrs, nil }
+	t.Cleanup(func() { interfaceAddrs = original })
+}
+
+func TestPhantomV6Source(t *testing.T) {
+	var dialed []string
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	reg := &ConjureReg{sessionIDStr: "[test]"}
+
+	// without a source, phantoms are dialed with the given dialer, local addresses unseen
+	stubInterfaceAddrs(t)
+	conn, err := reg.connect(context.Background(), "2001:db8::1", dialer)
+	require.Nil(t, err)
+	conn.Close()
+
+	// a configured source must be a global v6 address of this host
+	stubInterfaceAddrs(t, "127.0.0.1/8", "2001:db8::100/64", "2001:db8::200/64")
+	reg.phantomV6Source = net.ParseIP("2001:db8::300")
+	_, err = reg.connect(context.Background(), "2001:db8::1", dialer)
+	regErr, ok := err.(*RegError)
+	require.True(t, ok, "unexpected error: %v", err)
+	require.Equal(t, "UNREACHABLE", regErr.CodeStr())
+	require.Contains(t, err.Error(), "not a local address")
+	reg.phantomV6Source = net.ParseIP("fd00::1")
+	_, err = reg.connect(context.Background(), "2001:db8::1", dialer)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "not a global v6 address")
+
+	// v4 phantoms are not affected
+	conn, err = reg.connect(context.Background(), "1.1.1.1", dialer)
+	require.Nil(t, err)
+	conn.Close()
+	require.Equal(t, []string{"[2001:db8::1]:443", "1.1.1.1:443"}, dialed)
+
+	// the phantom is then dialed from the source, with the session net.Dialer settings
+	var controlled bool
+	reg.phantomV6Source = net.ParseIP("2001:db8::200")
+	reg.netDialer = &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		controlled = true
+		return fmt.Errorf("stop before connecting")
+	}}
+	_, err = reg.connect(context.Background(), "2001:db8::1", dialer)
+	require.NotNil(t, err)
+	require.True(t, controlled)
+	require.Len(t, dialed, 2)
+}
+
+func TestPhantomAddressFamilies(t *testing.T) {
+	var dialed []string
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	reg := &ConjureReg{sessionIDStr: "[test]"}
+
+	// v6 phantoms are bracketed, v4-mapped ones dialed as v4
+	for _, test := range []struct {
+		phantom net.IP
+		version uint32
+		addr    string
+	}{
+		{net.ParseIP("192.0.2.1"), 4, "192.0.2.1:443"},
+		{net.ParseIP("192.0.2.1").To4(), 4, "192.0.2.1:443"},
+		{net.ParseIP("2001:db8::1"), 6, "[2001:db8::1]:443"},
+		{net.ParseIP("::ffff:192.0.2.1"), 4, "192.0.2.1:443"},
+	} {
+		dialed = nil
+		require.Equal(t, test.version, ipVersion(test.phantom), "%v", test.phantom)
+		conn, err := reg.getFirstConnection(context.Background(), dialer, []net.IP{test.phantom})
+		require.Nil(t, err)
+		conn.Close()
+		require.Equal(t, []string{test.addr}, dialed, "%v", test.phantom)
+	}
+	for _, addr := range []string{"::ffff:192.0.2.1", "::FFFF:C000:0201"} {
+		dialed = nil
+		conn, err := reg.connect(context.Background(), addr, dialer)
+		require.Nil(t, err)
+		conn.Close()
+		require.Equal(t, []string{"192.0.2.1:443"}, dialed)
+	}
+
+	// phantoms of each family are reachable at the formatted addresses
+	listen := func(network, addr string) (net.Listener, uint16) {
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, 0
+		}
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+		return l, uint16(l.Addr().(*net.TCPAddr).Port)
+	}
+	var d net.Dialer
+	l4, port4 := listen("tcp4", "127.0.0.1:0")
+	require.NotNil(t, l4)
+	defer l4.Close()
+	for _, phantom := range []string{"127.0.0.1", "::ffff:127.0.0.1"} {
+		reg.phantomPort = port4
+		conn, err := reg.connect(context.Background(), phantom, d.DialContext)
+		require.Nil(t, err, phantom)
+		conn.Close()
+	}
+	l6, port6 := listen("tcp6", "[::1]:0")
+	if l6 == nil {
+		t.Skip("no v6 loopback")
+	}
+	defer l6.Close()
+	reg.phantomPort = port6
+	conn, err := reg.connect(context.Background(), "::1", d.DialContext)
+	require.Nil(t, err)
+	conn.Close()
+}
+
+func TestReconnect(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.Transport = pb.TransportType_Min
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	reg.phantom6 = nil
+
+	var dialed []string
+	tags := make(chan []byte, 2)
+	reg.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		client, server := net.Pipe()
+		go func() {
+			tag := make([]byte, 32)
+			io.ReadFull(server, tag)
+			tags <- tag
+			server.Close()
+		}()
+		return client, nil
+	}
+
+	conn, err := reg.Connect(context.Background())
+	require.Nil(t, err)
+	conn.Close()
+	connectTime := reg.TotalTimeToConnect()
+
+	time.Sleep(5 * time.Millisecond)
+	conn, err = reg.Reconnect(context.Background())
+	require.Nil(t, err)
+	conn.Close()
+
+	// same phantom and connect tag, without counting as a new connection
+	phantom := net.JoinHostPort(reg.phantom4.String(), "443")
+	require.Equal(t, []string{phantom, phantom}, dialed)
+	require.Equal(t, <-tags, <-tags)
+	require.Equal(t, connectTime, reg.TotalTimeToConnect())
+}
+
+func TestRegErrorUnwrap(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ENETUNREACH)}
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	dialErrors := make(chan error, 1)
+	reg.sends.Add(1)
+	reg.send(context.Background(), pb.InitTLSDecoySpec("10.0.0.1", "example.com"), dialErrors, nil)
+	err = <-dialErrors
+
+	var regErr *RegError
+	require.True(t, errors.As(err, &regErr))
+	require.Equal(t, "UNREACHABLE", regErr.CodeStr())
+	require.Contains(t, regErr.Error(), "Registration Error [UNREACHABLE]")
+	var opErr *net.OpError
+	require.True(t, errors.As(err, &opErr))
+	require.Equal(t, "dial", opErr.Op)
+	require.True(t, errors.Is(err, syscall.ENETUNREACH))
+
+	// errors without an underlying cause unwrap to nil
+	require.Nil(t, errors.Unwrap(&RegError{code: DialFailure, msg: "no cause"}))
+}
+
+// brokenConn panics on use
+type brokenConn struct {
+	net.Conn
+}
+
+func TestSendRecoversPanic(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		panic("decoy dialer bug")
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	send := func(decoy *pb.TLSDecoySpec) *RegError {
+		dialErrors := make(chan error, 1)
+		reg.sends.Add(1)
+		go reg.send(context.Background(), decoy, dialErrors, nil)
+		var regErr *RegError
+		require.True(t, errors.As(<-dialErrors, &regErr))
+		return regErr
+	}
+
+	// a panicking dialer fails the dial
+	regErr := send(pb.InitTLSDecoySpec("10.0.0.1", "example.com"))
+	require.Equal(t, "DIAL_FAILURE", regErr.CodeStr())
+	require.Contains(t, regErr.Error(), "decoy dialer bug")
+
+	// a decoy without address fails without dialing
+	regErr = send(nil)
+	require.Equal(t, "DIAL_FAILURE", regErr.CodeStr())
+	require.Contains(t, regErr.Error(), "has no IP address")
+
+	// a panic in send itself, here on a broken connection, fails the decoy
+	reg.decoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return brokenConn{}, nil
+	}
+	regErr = send(pb.InitTLSDecoySpec("10.0.0.1", "example.com"))
+	require.Equal(t, "UNKNOWN", regErr.CodeStr())
+	require.Contains(t, regErr.Error(), "panic")
+	reg.sends.Wait()
+}
+
+func TestStopAfterFirstRegistration(t *testing.T) {
+	AssetsSetDir("./assets")
+	decoyDialer := benchDecoy(t)
+
+	// one decoy answers, two are black-holed until their dials are cancelled, and the
+	// last refuses connections after the registration was sent
+	register := func(stopAfterFirst bool) *ConjureReg {
+		session := makeTestSession(t, "1.2.3.4:1234")
+		session.StopAfterFirstRegistration = stopAfterFirst
+		session.NoRegistrationSleep = true
+		session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if address == "10.0.0.1:443" {
+				return decoyDialer(ctx, network, address)
+			}
+			if address == "10.0.0.4:443" {
+				time.Sleep(300 * time.Millisecond)
+				return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+			}
+			<-ctx.Done()
+			return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+		}
+		timings := DefaultTimings()
+		timings.DecoyDialTimeout = Timing{Base: time.Second}
+		session.Timings = &timings
+		session.DecoySelector = func(*ConjureSession, []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+			return []*pb.TLSDecoySpec{
+				pb.InitTLSDecoySpec("10.0.0.1", "example.com"),
+				pb.InitTLSDecoySpec("10.0.0.2", "example.com"),
+				pb.InitTLSDecoySpec("10.0.0.3", "example.com"),
+				pb.InitTLSDecoySpec("10.0.0.4", "example.com"),
+			}, nil
+		}
+		reg, err := DecoyRegistrar{}.Register(session, context.Background())
+		require.Nil(t, err)
+		reg.sends.Wait()
+		return reg
+	}
+	codes := func(reg *ConjureReg) []string {
+		var codes []string
+		for _, result := range reg.decoyResults {
+			var regErr *RegError
+			if result.err == nil {
+				codes = append(codes, "SENT")
+			} else if errors.As(result.err, &regErr) {
+				codes = append(codes, regErr.CodeStr())
+			}
+		}
+		return codes
+	}
+
+	start := time.Now()
+	reg := register(true)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+	// only the sends cancelled are reported stopped, not those failing on their own
+	require.ElementsMatch(t, []string{"SENT", "SEND_STOPPED", "SEND_STOPPED", "DIAL_FAILURE"}, codes(reg))
+
+	// by default, every decoy is tried to the end
+	reg = register(false)
+	require.ElementsMatch(t, []string{"SENT", "DIAL_FAILURE", "DIAL_FAILURE", "DIAL_FAILURE"}, codes(reg))
+}
+
+func TestSendDecoyDialTimeout(t *testing.T) {
+	// a black-holed decoy: the dial only returns when its context is done
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	}
+	timings := DefaultTimings()
+	timings.DecoyDialTimeout = Timing{Base: 100 * time.Millisecond}
+	session.Timings = &timings
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	dialErrors := make(chan error, 1)
+	reg.sends.Add(1)
+	start := time.Now()
+	reg.send(context.Background(), pb.InitTLSDecoySpec("10.0.0.1", "example.com"), dialErrors, nil)
+	err = <-dialErrors
+	require.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.GreaterOrEqual(t, reg.stats.GetTcpToDecoy(), uint32(100))
+	require.Nil(t, reg.stats.TlsToDecoy)
+}
+
+func TestSendDecoyTLSRetries(t *testing.T) {
+	// a decoy rejecting ClientHellos with GREASE cipher suites, like Chrome sends
+	greased := make(chan bool, 4)
+	decoy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	decoy.TLS = &stdtls.Config{
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			for _, suite := range hello.CipherSuites {
+				if suite&0x0f0f == 0x0a0a {
+					greased <- true
+					return nil, errors.New("rejected ClientHello")
+				}
+			}
+			greased <- false
+			return nil, nil
+		},
+	}
+	decoy.StartTLS()
+	defer decoy.Close()
+
+	sendToDecoy := func(retries uint) error {
+		session := makeTestSession(t, "1.2.3.4:1234")
+		session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, decoy.Listener.Addr().String())
+		}
+		session.DecoyTLSRetries = retries
+		session.VerifyDecoyCertificates = true
+		reg, err := session.newConjureReg()
+		require.Nil(t, err)
+
+		dialErrors := make(chan error, 1)
+		reg.sends.Add(1)
+		reg.send(context.Background(), pb.InitTLSDecoySpec("10.0.0.1", "example.com"), dialErrors, nil)
+		return <-dialErrors
+	}
+
+	err := sendToDecoy(0)
+	require.Contains(t, err.Error(), "parroting "+defaultDecoyParrot.Str())
+	require.Equal(t, true, <-greased)
+	require.Len(t, greased, 0)
+
+	// the retry parrots Firefox, accepted by the decoy, whose test certificate still
+	// fails verification
+	err = sendToDecoy(1)
+	require.Contains(t, err.Error(), "parroting "+alternateDecoyParrot.Str())
+	require.Contains(t, err.Error(), "certificate")
+	require.Equal(t, true, <-greased)
+	require.Equal(t, false, <-greased)
+}
+
+// timeoutError - A net.Error timing out, like a dial reaching its deadline
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyDialError(t *testing.T) {
+	dialErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	for _, test := range []struct {
+		err  error
+		code string
+	}{
+		{dialErr(os.NewSyscallError("connect", syscall.ENETUNREACH)), "UNREACHABLE"},
+		{dialErr(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), "UNREACHABLE"},
+		{dialErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), "DIAL_FAILURE"},
+		{dialErr(timeoutError{}), "DIAL_FAILURE"},
+		{context.DeadlineExceeded, "DIAL_FAILURE"},
+		{errors.New("proxy refused the connection"), "DIAL_FAILURE"},
+	} {
+		regErr := classifyDialError(test.err)
+		require.Equal(t, test.code, regErr.CodeStr(), "%v", test.err)
+		require.Equal(t, test.err, errors.Unwrap(regErr))
+	}
+}
+
+func TestDecoyParrots(t *testing.T) {
+	require.Equal(t, tls.HelloChrome_62, (&ConjureReg{}).nextDecoyParrot())
+
+	parrots := []tls.ClientHelloID{tls.HelloChrome_58, tls.HelloFirefox_56, tls.HelloIOS_11_1}
+	reg := &ConjureReg{decoyParrots: parrots}
+	first := reg.nextDecoyParrot()
+	start := -1
+	for i, p := range parrots {
+		if p == first {
+			start = i
+		}
+	}
+	require.NotEqual(t, -1, start)
+	for i := 1; i < 6; i++ {
+		require.Equal(t, parrots[(start+i)%len(parrots)], reg.nextDecoyParrot())
+	}
+
+	// successive decoy handshakes offer different ClientHellos
+	hellos := make(chan []uint16, 2)
+	decoy := httptest.NewUnstartedServer(http.NotFoundHandler())
+	decoy.TLS = &stdtls.Config{
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			hellos <- hello.CipherSuites
+			return nil, nil
+		},
+	}
+	decoy.StartTLS()
+	defer decoy.Close()
+
+	reg = &ConjureReg{decoyParrots: []tls.ClientHelloID{tls.HelloChrome_62, tls.HelloFirefox_56}, verifyDecoyCerts: true}
+	for i := 0; i < 2; i++ {
+		dialConn, err := net.Dial("tcp", decoy.Listener.Addr().String())
+		require.Nil(t, err)
+		// the test certificate does not verify, but the server sees the hello first
+		_, err = reg.createTLSConn(context.Background(), dialConn, decoy.Listener.Addr().String(), "example.com", false, time.Now().Add(5*time.Second))
+		require.NotNil(t, err)
+		dialConn.Close()
+	}
+	require.NotEqual(t, <-hellos, <-hellos)
+}
+
+func TestReadDecoyResponse(t *testing.T) {
+	// serveResponse - Write a response with a body of bodySize bytes, keeping the
+	// connection open like a keep-alive server, and report the bytes written
+	serveResponse := func(server net.Conn, bodySize int) <-chan int {
+		written := make(chan int, 1)
+		go func() {
+			header := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", bodySize)
+			n, _ := server.Write(append([]byte(header), make([]byte, bodySize)...))
+			written <- n - len(header)
+		}()
+		return written
+	}
+	reg := &ConjureReg{}
+
+	client, server := net.Pipe()
+	defer server.Close()
+	written := serveResponse(server, 200000)
+	start := time.Now()
+	reg.readResponseAndClose(client, 5*time.Second)
+	require.Less(t, int64(time.Since(start)), int64(2*time.Second))
+	require.Equal(t, 200000, <-written)
+
+	// responses are read up to maxDecoyResponseSize
+	client, server = net.Pipe()
+	defer server.Close()
+	written = serveResponse(server, 2*maxDecoyResponseSize)
+	reg.readResponseAndClose(client, 5*time.Second)
+	require.Less(t, <-written, maxDecoyResponseSize)
+
+	// by default, a single byte is read: not even the whole header
+	client, server = net.Pipe()
+	defer server.Close()
+	written = serveResponse(server, 200000)
+	reg.readAndClose(client, 5*time.Second)
+	require.Negative(t, <-written)
+}
+
+func TestReadAndCloseSessionClosed(t *testing.T) {
+	closed := make(chan struct{})
+	reg := &ConjureReg{closed: closed}
+	baseline := runtime.NumGoroutine()
+
+	// lingering reads return as soon as the session is closed, well before the
+	// deadline, without leaving goroutines behind
+	var servers []net.Conn
+	readsDone := make(chan struct{}, 20)
+	for i := 0; i < 20; i++ {
+		client, server := net.Pipe()
+		servers = append(servers, server)
+		go func() {
+			reg.readAndClose(client, 15*time.Second)
+			readsDone <- struct{}{}
+		}()
+	}
+	close(closed)
+	for i := 0; i < 20; i++ {
+		select {
+		case <-readsDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("readAndClose did not return after the session was closed")
+		}
+	}
+	for _, server := range servers {
+		server.Close()
+	}
+
+	leaked := func() bool { return runtime.NumGoroutine() > baseline }
+	for deadline := time.Now().Add(5 * time.Second); leaked() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.False(t, leaked(), "%v goroutines left from %v", runtime.NumGoroutine(), baseline)
+
+	// a session closed before reading does not wait for the deadline either
+	client, server := net.Pipe()
+	defer server.Close()
+	start := time.Now()
+	reg.readResponseAndClose(client, 15*time.Second)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestSendLingersPastRegistration(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	// the decoy never answers the registration, reading until the client closes
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	decoy, err := stdtls.Listen("tcp", "127.0.0.1:0", server.TLS)
+	require.Nil(t, err)
+	defer decoy.Close()
+	go func() {
+		for {
+			conn, err := decoy.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.DecoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, decoy.Addr().String())
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialErrors := make(chan error, 1)
+	sendDone := make(chan struct{})
+	reg.sends.Add(1)
+	go reg.send(ctx, pb.InitTLSDecoySpec("10.0.0.1", "example.com"), dialErrors, func(*ConjureReg) { close(sendDone) })
+	require.Nil(t, <-dialErrors)
+
+	// the decoy connection outlives the registration context
+	cancel()
+	select {
+	case <-sendDone:
+		t.Fatal("decoy connection closed with the registration context")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// until the session is closed
+	require.Nil(t, session.Close())
+	select {
+	case <-sendDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("decoy connection not closed with the session")
+	}
+}
+
+func TestDialDecoyHappyEyeballs(t *testing.T) {
+	decoy := pb.InitTLSDecoySpec("192.0.2.1", "example.com")
+	decoy.Ipv6Addr = net.ParseIP("2001:db8::1")
+
+	// dialDecoyWith - Dial the decoy in a session of the given IP versions, where
+	// the v4 address fails with v4Err or hangs if nil, and get the dialed addresses
+	dialDecoyWith := func(support uint, v4Err error) (*ConjureReg, []string, string, error) {
+		var m sync.Mutex
+		var dialed []string
+		reg := &ConjureReg{v6Support: support, stats: &pb.SessionStats{}}
+		reg.decoyDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+			m.Lock()
+			dialed = append(dialed, address)
+			m.Unlock()
+			if address == "192.0.2.1:443" {
+				if v4Err != nil {
+					return nil, v4Err
+				}
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		conn, addr, err := reg.dialDecoy(context.Background(), decoy)
+		if conn != nil {
+			conn.Close()
+		}
+		m.Lock()
+		defer m.Unlock()
+		return reg, append([]string(nil), dialed...), addr, err
+	}
+
+	// v4 only sessions keep to v4
+	_, dialed, _, err := dialDecoyWith(v4, syscall.ENETUNREACH)
+	require.ErrorIs(t, err, syscall.ENETUNREACH)
+	require.Equal(t, []string{"192.0.2.1:443"}, dialed)
+
+	// a hanging v4 address falls back to v6 after the delay
+	start := time.Now()
+	reg, _, addr, err := dialDecoyWith(both, nil)
+	require.Nil(t, err)
+	require.Equal(t, "[2001:db8::1]:443", addr)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(decoyFallbackDelay))
+	require.Equal(t, uint32(1), reg.stats.GetDecoyV6Connects())
+	require.Equal(t, uint32(0), reg.stats.GetDecoyV4Connects())
+
+	// a failing v4 address falls back to v6 right away
+	start = time.Now()
+	_, dialed, addr, err = dialDecoyWith(both, syscall.ECONNREFUSED)
+	require.Nil(t, err)
+	require.Equal(t, "[2001:db8::1]:443", addr)
+	require.Less(t, int64(time.Since(start)), int64(decoyFallbackDelay))
+	require.Equal(t, []string{"192.0.2.1:443", "[2001:db8::1]:443"}, dialed)
+
+	// v6 only sessions dial the v6 address
+	_, dialed, _, err = dialDecoyWith(v6, nil)
+	require.Nil(t, err)
+	require.Equal(t, []string{"[2001:db8::1]:443"}, dialed)
+}
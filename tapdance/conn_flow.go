@@ -540,6 +540,11 @@ func (flowConn *TapdanceFlowConn) idStr() string {
 
 func (flowConn *TapdanceFlowConn) processProto(msg *pb.StationToClient) error {
 	handleConfigInfo := func(conf *pb.ClientConf) {
+		if Assets().ClientConfUpdatesDisabled() || flowConn.tdRaw.pinClientConf {
+			Logger().Infoln(flowConn.idStr()+" not applying new config: updates"+
+				" disabled (generation", conf.GetGeneration(), ")")
+			return
+		}
 		currGen := Assets().GetGeneration()
 		if conf.GetGeneration() < currGen {
 			Logger().Infoln(flowConn.idStr()+" not appliying new config due"+
@@ -0,0 +1,304 @@
+package tapdance
+
+import (
+	"bytes"
+	"context"
+	stdtls "crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// The fronted transport tunnels the phantom stream meek-style, in HTTPS requests to a
+// domain served by a CDN (the front), with the Host header of a relay behind the same
+// CDN that forwards them to the station. Censors only see HTTPS to the front domain.
+//
+// Each request POSTs the bytes written since the previous one, and its response
+// carries the bytes received from the station in the meantime. Requests are sent as
+// soon as there is data to send, and otherwise poll at increasing intervals. The
+// X-Session-Id header, derived from the shared secret, tells the station which
+// registration the requests belong to.
+const (
+	frontedSessionIDHeader = "X-Session-Id"
+	frontedSessionIDString = "FrontedTransportSessionIDString"
+
+	// Most bytes sent in one request
+	frontedMaxPayload = 64 * 1024
+
+	// Bounds of the polling interval, which grows while no data flows
+	frontedPollMin = 100 * time.Millisecond
+	frontedPollMax = 5 * time.Second
+
+	// Timeout of each request
+	frontedRequestTimeout = 30 * time.Second
+)
+
+// frontedTransport - Connects with the fronted transport, see dialFronted
+type frontedTransport struct{}
+
+func (frontedTransport) Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error) {
+	conn, err := reg.dialFronted(ctx)
+	if err != nil {
+		reg.logger().Infof("%v failed to form fronted connection: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// frontedSessionID - Session ID of a registration for the fronted relay
+func frontedSessionID(sharedSecret []byte) string {
+	return hex.EncodeToString(conjureHMAC(sharedSecret, frontedSessionIDString)[:16])
+}
+
+// dialFronted - Connect through the fronted relay of reg.frontingParams. The phantom
+// is not dialed: the relay reaches the station.
+func (reg *ConjureReg) dialFronted(ctx context.Context) (net.Conn, error) {
+	params := reg.frontingParams
+	if params.GetFrontDomain() == "" || params.GetRelayHost() == "" {
+		return nil, errors.New("no fronting parameters for the fronted transport")
+	}
+	path := params.GetPath()
+	if path == "" {
+		path = "/"
+	}
+
+	client := &http.Client{
+		Transport: &frontedRoundTripper{reg: reg, frontDomain: params.GetFrontDomain(), rootCAs: reg.decoyRootCAs},
+		Timeout:   frontedRequestTimeout,
+	}
+	url := "https://" + net.JoinHostPort(params.GetFrontDomain(), "443") + path
+	return newFrontedConn(ctx, reg, client, url, params.GetRelayHost())
+}
+
+// frontedRoundTripper - Sends the requests of a fronted connection over TLS
+// connections to the front parroting the decoy ClientHellos, like those of the
+// browser they imitate, in HTTP/2 or HTTP/1.1 as the front picks from the ALPN of
+// the parrot. The front certificate is verified, against the DecoyRootCAs of the
+// session if set.
+type frontedRoundTripper struct {
+	reg         *ConjureReg
+	frontDomain string
+	rootCAs     *x509.CertPool // nil for the system roots
+
+	m         sync.Mutex
+	transport http.RoundTripper // nil until a first connection negotiated the protocol
+}
+
+func (rt *frontedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.m.Lock()
+	if rt.transport == nil {
+		conn, err := rt.dialTLS(req.Context(), "tcp", req.URL.Host)
+		if err != nil {
+			rt.m.Unlock()
+			return nil, err
+		}
+		rt.transport = rt.newTransport(conn)
+	}
+	transport := rt.transport
+	rt.m.Unlock()
+
+	return transport.RoundTrip(req)
+}
+
+// CloseIdleConnections - Close the idle connections to the front, see http.Client
+func (rt *frontedRoundTripper) CloseIdleConnections() {
+	rt.m.Lock()
+	defer rt.m.Unlock()
+
+	if closer, ok := rt.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// newTransport - Get the transport of the protocol conn negotiated, which sends its
+// first requests on conn and dials the front again when it needs more connections.
+func (rt *frontedRoundTripper) newTransport(conn *tls.UConn) http.RoundTripper {
+	first := make(chan net.Conn, 1)
+	first <- conn
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		select {
+		case c := <-first:
+			return c, nil
+		default:
+			return rt.dialTLS(ctx, network, address)
+		}
+	}
+
+	if conn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		return &http2.Transport{DialTLS: func(network, address string, _ *stdtls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, address)
+		}}
+	}
+	return &http.Transport{DialTLSContext: dial}
+}
+
+// dialTLS - Connect to the front and perform the TLS handshake, parroting the next
+// decoy ClientHello
+func (rt *frontedRoundTripper) dialTLS(ctx context.Context, network, address string) (*tls.UConn, error) {
+	dialConn, err := rt.reg.TcpDialer(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	config := tls.Config{ServerName: rt.frontDomain, RootCAs: rt.rootCAs}
+	tlsConn := tls.UClient(dialConn, &config, rt.reg.nextDecoyParrot())
+	err = handshakeContext(ctx, tlsConn, dialConn, time.Now().Add(tlsTransportHandshakeTimeout))
+	if err != nil {
+		dialConn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// frontedTunnel - Moves the bytes of a fronted connection between the pipe end given
+// to the user and the HTTPS requests to the relay.
+type frontedTunnel struct {
+	client    *http.Client
+	url       string
+	host      string
+	sessionID string
+
+	local net.Conn // the pipe end opposite to the user's
+
+	sessionIDStr string
+	logger       LeveledLogger
+
+	m       sync.Mutex
+	pending bytes.Buffer  // written by the user, not sent yet
+	done    bool          // the user closed the connection
+	wake    chan struct{} // signalled when there is data to send or on close
+}
+
+// newFrontedConn - Open a fronted connection, checking the relay is reachable with a
+// first empty request.
+func newFrontedConn(ctx context.Context, reg *ConjureReg, client *http.Client, url, host string) (net.Conn, error) {
+	t := &frontedTunnel{
+		client:       client,
+		url:          url,
+		host:         host,
+		sessionID:    frontedSessionID(reg.keys.SharedSecret),
+		sessionIDStr: reg.sessionIDStr,
+		logger:       reg.logger(),
+		wake:         make(chan struct{}, 1),
+	}
+	received, err := t.roundTrip(ctx, nil)
+	if err != nil {
+		client.CloseIdleConnections()
+		return nil, err
+	}
+
+	conn, local := net.Pipe()
+	t.local = local
+	go t.readLoop()
+	go t.pollLoop(received)
+	return conn, nil
+}
+
+// roundTrip - Send payload to the relay and get the bytes it returns
+func (t *frontedTunnel) roundTrip(ctx context.Context, payload []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Host = t.host
+	req.Header.Set(frontedSessionIDHeader, t.sessionID)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fronted relay returned %v", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// readLoop - Queue the bytes written by the user until the connection is closed
+func (t *frontedTunnel) readLoop() {
+	buf := make([]byte, frontedMaxPayload)
+	for {
+		n, err := t.local.Read(buf)
+		t.m.Lock()
+		t.pending.Write(buf[:n])
+		if err != nil {
+			t.done = true
+		}
+		t.m.Unlock()
+
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pollLoop - Exchange data with the relay, starting by passing received to the user,
+// until the user closes the connection or a request fails.
+func (t *frontedTunnel) pollLoop(received []byte) {
+	defer t.client.CloseIdleConnections()
+	defer t.local.Close()
+
+	interval := frontedPollMin
+	for {
+		if len(received) > 0 {
+			if _, err := t.local.Write(received); err != nil {
+				return
+			}
+		}
+
+		t.m.Lock()
+		payload := append([]byte(nil), t.pending.Next(frontedMaxPayload)...)
+		done := t.done
+		t.m.Unlock()
+
+		if len(payload) == 0 && len(received) == 0 {
+			if done {
+				return
+			}
+			// idle: poll at a growing interval, unless the user writes first
+			select {
+			case <-t.wake:
+				received = nil
+				continue
+			case <-time.After(interval):
+			}
+			if interval = interval * 3 / 2; interval > frontedPollMax {
+				interval = frontedPollMax
+			}
+		} else {
+			interval = frontedPollMin
+		}
+
+		var err error
+		received, err = t.roundTrip(context.Background(), payload)
+		if err != nil {
+			t.logger.Infof("%v fronted transport request failed: %v", t.sessionIDStr, err)
+			return
+		}
+	}
+}
+
+// getFrontingParams - Get the fronted transport parameters of the session, falling
+// back to the ClientConf
+func (cjSession *ConjureSession) getFrontingParams() *pb.FrontingParams {
+	if cjSession.FrontingParams != nil {
+		return cjSession.FrontingParams
+	}
+	return Assets().GetFrontingParams()
+}
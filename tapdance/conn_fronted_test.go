@@ -0,0 +1,143 @@
+package tapdance
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrontedConn(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	reg := &ConjureReg{sessionIDStr: "[test]", keys: &sharedKeys{SharedSecret: secret}}
+
+	// the relay echoes the bytes of each request in its response
+	var m sync.Mutex
+	var requests int
+	relay := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "relay.example.com" || r.URL.Path != "/relay" ||
+			r.Header.Get(frontedSessionIDHeader) != frontedSessionID(secret) {
+			http.NotFound(w, r)
+			return
+		}
+		m.Lock()
+		requests++
+		m.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer relay.Close()
+
+	conn, err := newFrontedConn(context.Background(), reg, relay.Client(), relay.URL+"/relay", "relay.example.com")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", "fronted world"} {
+		_, err = conn.Write([]byte(msg))
+		require.Nil(t, err)
+		buf := make([]byte, len(msg))
+		_, err = io.ReadFull(conn, buf)
+		require.Nil(t, err)
+		require.Equal(t, msg, string(buf))
+	}
+	m.Lock()
+	require.GreaterOrEqual(t, requests, 3)
+	m.Unlock()
+
+	// the relay is checked when connecting
+	_, err = newFrontedConn(context.Background(), reg, relay.Client(), relay.URL+"/relay", "other.example.com")
+	require.Contains(t, err.Error(), "404")
+}
+
+func TestFrontedRoundTripper(t *testing.T) {
+	for _, h2 := range []bool{true, false} {
+		// the front answers with the HTTP version of the request
+		front := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.ProtoMajor)
+		}))
+		front.EnableHTTP2 = h2
+		front.StartTLS()
+		defer front.Close()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(front.Certificate())
+		var dials int
+		reg := &ConjureReg{
+			sessionIDStr: "[test]",
+			TcpDialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dials++
+				return (&net.Dialer{}).DialContext(ctx, network, front.Listener.Addr().String())
+			},
+		}
+		client := &http.Client{Transport: &frontedRoundTripper{reg: reg, frontDomain: "example.com", rootCAs: roots}}
+
+		// the parrot negotiates HTTP/2 with fronts supporting it, and connections are reused
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get("https://example.com/")
+			require.Nil(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if h2 {
+				require.Equal(t, "2", string(body))
+			} else {
+				require.Equal(t, "1", string(body))
+			}
+		}
+		require.Equal(t, 1, dials)
+		client.CloseIdleConnections()
+
+		// the front certificate is verified
+		client = &http.Client{Transport: &frontedRoundTripper{reg: reg, frontDomain: "example.com"}}
+		_, err := client.Get("https://example.com/")
+		require.NotNil(t, err)
+	}
+}
+
+func TestDialFrontedRootCAs(t *testing.T) {
+	front := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer front.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(front.Certificate())
+	reg := &ConjureReg{
+		sessionIDStr: "[test]",
+		keys:         &sharedKeys{SharedSecret: bytes.Repeat([]byte{1}, 32)},
+		frontingParams: &pb.FrontingParams{
+			FrontDomain: proto.String("example.com"),
+			RelayHost:   proto.String("relay.example.com"),
+		},
+		TcpDialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, front.Listener.Addr().String())
+		},
+	}
+
+	// the front certificate is verified against the decoy roots of the session
+	_, err := reg.dialFronted(context.Background())
+	require.NotNil(t, err)
+	reg.decoyRootCAs = roots
+	conn, err := reg.dialFronted(context.Background())
+	require.Nil(t, err)
+	conn.Close()
+}
+
+func TestFrontedTransportParams(t *testing.T) {
+	reg := &ConjureReg{
+		sessionIDStr:   "[test]",
+		transport:      pb.TransportType_Fronted,
+		keys:           &sharedKeys{SharedSecret: bytes.Repeat([]byte{1}, 32)},
+		frontingParams: &pb.FrontingParams{FrontDomain: proto.String("cdn.example.com")},
+	}
+	_, err := reg.Connect(context.Background())
+	require.Contains(t, err.Error(), "no fronting parameters")
+}
@@ -48,6 +48,8 @@ type tdRawConn struct {
 
 	useProxyHeader bool // request the station to prepend the connection with PROXY header
 
+	pinClientConf bool // report the maximum generation, see Dialer.PinClientConf
+
 	// dark decoy variables
 	darkDecoyUsed      bool
 	darkDecoySNI       string
@@ -357,7 +359,7 @@ func (tdRaw *tdRawConn) generateVSP() ([]byte, error) {
 		transition = pb.C2S_Transition_C2S_SESSION_COVERT_INIT
 		covert = &tdRaw.covert
 	}
-	currGen := Assets().GetGeneration()
+	currGen := Assets().getReportedGeneration(tdRaw.pinClientConf)
 	initProto := &pb.ClientToStation{
 		CovertAddress:       covert,
 		StateTransition:     &transition,
@@ -414,7 +416,7 @@ func (tdRaw *tdRawConn) prepareTDRequest(handshakeType tdTagType) (string, error
 		transition = pb.C2S_Transition_C2S_SESSION_COVERT_INIT
 		covert = &tdRaw.covert
 	}
-	currGen := Assets().GetGeneration()
+	currGen := Assets().getReportedGeneration(tdRaw.pinClientConf)
 	initProto := &pb.ClientToStation{
 		CovertAddress:       covert,
 		StateTransition:     &transition,
@@ -506,7 +508,7 @@ func (tdRaw *tdRawConn) writeTransition(transition pb.C2S_Transition) (n int, er
 	const paddingSmoothness = 5
 	paddingDecrement := 0 // reduce potential padding size by this value
 
-	currGen := Assets().GetGeneration()
+	currGen := Assets().getReportedGeneration(tdRaw.pinClientConf)
 	msg := pb.ClientToStation{
 		DecoyListGeneration: &currGen,
 		StateTransition:     &transition,
@@ -581,7 +583,7 @@ Content-Disposition: form-data; name=\"td.zip\"
 	}
 
 	keystreamOffset := len(httpTag)
-	keystreamSize := (len(tag)/3+1)*4 + keystreamOffset // we can't use first 2 bits of every byte
+	keystreamSize := reverseEncryptKeystreamSize(len(tag)) + keystreamOffset
 	wholeKeystream, err := tdRaw.tlsConn.GetOutKeystream(keystreamSize)
 	if err != nil {
 		return httpTag, err
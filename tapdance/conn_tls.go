@@ -0,0 +1,124 @@
+package tapdance
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// The TLS transport camouflages the phantom connection as HTTPS: the phantom is
+// connected to with a TLS handshake parroting the decoy ClientHellos, and the min
+// transport connect tag and the tunneled data are then sent inside the TLS
+// connection, which the station terminates.
+//
+// The station has no certificate for the SNI, so the certificate is not verified
+// and nothing authenticates the station to the client. This resists passive
+// observers and probes of the phantom, not an active attacker intercepting the TLS
+// connection, who sees the connect tag like with the min transport.
+
+// tlsTransportHandshakeTimeout - time allowed to the TLS handshake with the phantom,
+// on top of the phantom dial
+const tlsTransportHandshakeTimeout = 10 * time.Second
+
+// tlsTransport - Connects with the TLS transport, see dialTLS
+type tlsTransport struct{}
+
+func (tlsTransport) Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error) {
+	conn, err := reg.dialTLS(ctx, reg.phantomIPs())
+	if err != nil {
+		reg.logger().Infof("%v failed to form TLS phantom connection: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialTLS - Connect to the first reachable phantom with the TLS transport
+func (reg *ConjureReg) dialTLS(ctx context.Context, phantoms []net.IP) (net.Conn, error) {
+	dialConn, err := reg.getFirstConnection(ctx, reg.TcpDialer, phantoms)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn, err := reg.tlsTransportHandshake(ctx, dialConn, reg.tlsTransportServerName())
+	if err != nil {
+		dialConn.Close()
+		return nil, err
+	}
+
+	reg.writeConnectTag(tlsConn)
+	return tlsConn, nil
+}
+
+// tlsTransportHandshake - Perform the TLS handshake with the phantom over dialConn,
+// parroting the next decoy ClientHello and sending serverName as SNI, if any.
+func (reg *ConjureReg) tlsTransportHandshake(ctx context.Context, dialConn net.Conn, serverName string) (*tls.UConn, error) {
+	config := tls.Config{ServerName: serverName, InsecureSkipVerify: true}
+	tlsConn := tls.UClient(dialConn, &config, reg.nextDecoyParrot())
+	if serverName == "" {
+		if err := tlsConn.RemoveSNIExtension(); err != nil {
+			return nil, err
+		}
+	}
+
+	err := handshakeContext(ctx, tlsConn, dialConn, time.Now().Add(tlsTransportHandshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// tlsTransportServerName - SNI of the TLS transport: the phantom server name if set,
+// or the masked decoy server name signalled to the station, otherwise the hostname
+// of a decoy the registration was sent to, so the phantom connection looks like one
+// more connection to that site. Empty, for no SNI, if there is none.
+func (reg *ConjureReg) tlsTransportServerName() string {
+	if reg.phantomServerName != "" {
+		return reg.phantomServerName
+	}
+	if reg.phantomSNI != "" {
+		return reg.phantomSNI
+	}
+
+	reg.m.Lock()
+	defer reg.m.Unlock()
+	var serverName string
+	for _, result := range reg.decoyResults {
+		hostname := result.decoy.GetHostname()
+		if hostname == "" || result.decoy.GetNoSni() {
+			continue
+		}
+		if result.err == nil {
+			return hostname
+		}
+		if serverName == "" {
+			serverName = hostname
+		}
+	}
+	return serverName
+}
+
+// validatePhantomServerName - Check that name, if set, is a hostname that can be sent
+// as SNI, which excludes IP addresses
+func validatePhantomServerName(name string) error {
+	if name != "" && (net.ParseIP(name) != nil || !isValidHostname(name)) {
+		return fmt.Errorf("invalid phantom server name %q: not a hostname", name)
+	}
+	return nil
+}
+
+// getPhantomServerName - Get the SNI of the TLS transport set for the session, or
+// the default of the ClientConf if valid
+func (cjSession *ConjureSession) getPhantomServerName() string {
+	if cjSession.PhantomServerName != "" {
+		return cjSession.PhantomServerName
+	}
+	name := Assets().GetPhantomServerName()
+	if err := validatePhantomServerName(name); err != nil {
+		cjSession.logger().Warnf("%v ignoring the ClientConf phantom server name: %v", cjSession.IDString(), err)
+		return ""
+	}
+	return name
+}
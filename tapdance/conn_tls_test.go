@@ -0,0 +1,110 @@
+package tapdance
+
+import (
+	"bytes"
+	"context"
+	stdtls "crypto/tls"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSTransport(t *testing.T) {
+	// a station terminating TLS, checking the connect tag and echoing what follows
+	certServer := httptest.NewTLSServer(nil)
+	defer certServer.Close()
+	serverNames := make(chan string, 1)
+	l, err := stdtls.Listen("tcp", "127.0.0.1:0", &stdtls.Config{
+		Certificates: certServer.TLS.Certificates,
+		GetConfigForClient: func(hello *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			serverNames <- hello.ServerName
+			return nil, nil
+		},
+	})
+	require.Nil(t, err)
+	defer l.Close()
+
+	secret := bytes.Repeat([]byte{1}, 32)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tag := make([]byte, 32)
+		if _, err := io.ReadFull(conn, tag); err != nil || !bytes.Equal(tag, minTransportConnectTag(secret, ConnectTagV1)) {
+			return
+		}
+		io.Copy(conn, conn)
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	var d net.Dialer
+	reg := &ConjureReg{
+		sessionIDStr: "[test]",
+		transport:    pb.TransportType_TLS,
+		keys:         &sharedKeys{SharedSecret: secret},
+		TcpDialer:    d.DialContext,
+		phantomPort:  uint16(portNum),
+		decoyResults: []decoyResult{{decoy: pb.InitTLSDecoySpec("192.0.2.1", "decoy.example.com")}},
+	}
+	reg.phantom4 = ipPtr("127.0.0.1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := reg.Connect(ctx)
+	require.Nil(t, err)
+	defer conn.Close()
+	require.Equal(t, "decoy.example.com", <-serverNames)
+
+	_, err = conn.Write([]byte("hello"))
+	require.Nil(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestPhantomServerName(t *testing.T) {
+	AssetsSetDir("./assets")
+	setClientConfName := func(name *string) {
+		Assets().Lock()
+		defer Assets().Unlock()
+		Assets().config.PhantomServerName = name
+	}
+	defer setClientConfName(nil)
+
+	// the session name comes first, then that of the ClientConf, then a decoy one
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.PhantomServerName = "cdn.example.com"
+	setClientConfName(proto.String("default.example.com"))
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	reg.phantomSNI = "masked.example.com"
+	require.Equal(t, "cdn.example.com", reg.tlsTransportServerName())
+
+	session.PhantomServerName = ""
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, "default.example.com", reg.tlsTransportServerName())
+
+	setClientConfName(proto.String("192.0.2.1"))
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	reg.decoyResults = []decoyResult{{decoy: pb.InitTLSDecoySpec("192.0.2.1", "decoy.example.com")}}
+	require.Equal(t, "decoy.example.com", reg.tlsTransportServerName())
+
+	for _, name := range []string{"192.0.2.1", "::1", "bad name", "-bad.example.com"} {
+		session.PhantomServerName = name
+		_, err = session.newConjureReg()
+		require.NotNil(t, err, name)
+	}
+}
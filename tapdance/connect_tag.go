@@ -0,0 +1,51 @@
+package tapdance
+
+import (
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// Strings the min transport connect tag is derived from, by connect tag version.
+// The original one is misspelled, and stations match it as is.
+const (
+	minTransportHMACStringV1 = "MinTrasportHMACString"
+	minTransportHMACStringV2 = "MinTransportHMACString"
+)
+
+// Connect tag versions. Stations must accept a version before clients use it.
+const (
+	// ConnectTagV1 - Original connect tags, used when the session sets no version
+	ConnectTagV1 = 1
+
+	// ConnectTagV2 - Connect tags derived from the corrected min transport string
+	ConnectTagV2 = 2
+)
+
+// ConnectTagFunc - Get the tag a transport writes first on a phantom connection, so
+// the station can match the connection to its registration. Nil for no tag.
+type ConnectTagFunc func(sharedSecret []byte, version uint) []byte
+
+// defaultConnectTags - Connect tags of the transports that send one. Obfs4 has none:
+// its handshake identifies the registration. The TLS transport sends the min
+// transport tag inside its TLS connection. Never modified, sessions override it
+// with ConjureSession.ConnectTags.
+var defaultConnectTags = map[pb.TransportType]ConnectTagFunc{
+	pb.TransportType_Min: minTransportConnectTag,
+	pb.TransportType_TLS: minTransportConnectTag,
+}
+
+// getConnectTag - Get the connect tag of the registration transport, nil if it has
+// none
+func (reg *ConjureReg) getConnectTag() ConnectTagFunc {
+	if tag, ok := reg.connectTags[reg.transport]; ok {
+		return tag
+	}
+	return defaultConnectTags[reg.transport]
+}
+
+// minTransportConnectTag - hmac(sharedSecret, str), with str by connect tag version
+func minTransportConnectTag(sharedSecret []byte, version uint) []byte {
+	if version >= ConnectTagV2 {
+		return conjureHMAC(sharedSecret, minTransportHMACStringV2)
+	}
+	return conjureHMAC(sharedSecret, minTransportHMACStringV1)
+}
@@ -0,0 +1,68 @@
+package tapdance
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectTags(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+
+	// connectTagWritten - Connect with the transport and get the bytes sent
+	connectTagWritten := func(transport pb.TransportType, version uint, tags map[pb.TransportType]ConnectTagFunc) []byte {
+		written := make(chan []byte, 1)
+		reg := &ConjureReg{
+			sessionIDStr:      "[test]",
+			transport:         transport,
+			keys:              &sharedKeys{SharedSecret: secret},
+			connectTagVersion: version,
+			connectTags:       tags,
+			phantom4:          ipPtr("1.1.1.1"),
+			TcpDialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+				client, server := net.Pipe()
+				go func() {
+					b, _ := ioutil.ReadAll(server)
+					written <- b
+				}()
+				return client, nil
+			},
+		}
+		conn, err := reg.Connect(context.Background())
+		require.Nil(t, err)
+		conn.Close()
+		return <-written
+	}
+
+	v1, _ := hex.DecodeString("4fdb442b44fd0246c3574aa9ffe113e777a55a18a55ff640049e30220a428d84")
+	v2, _ := hex.DecodeString("03c9f9c9f4d41a6abb89581e0754aa3724deda69a123ac054a55b79a15a2b1b4")
+	require.Equal(t, v1, connectTagWritten(pb.TransportType_Min, 0, nil))
+	require.Equal(t, v1, connectTagWritten(pb.TransportType_Min, ConnectTagV1, nil))
+	require.Equal(t, v2, connectTagWritten(pb.TransportType_Min, ConnectTagV2, nil))
+	require.Empty(t, connectTagWritten(pb.TransportType_Null, 0, nil))
+
+	// sessions can define the tags of transports, or remove them
+	tags := map[pb.TransportType]ConnectTagFunc{
+		pb.TransportType_Null: func(sharedSecret []byte, version uint) []byte {
+			return append([]byte{byte(version)}, sharedSecret[:2]...)
+		},
+		pb.TransportType_Min: nil,
+	}
+	require.Equal(t, []byte{2, 1, 1}, connectTagWritten(pb.TransportType_Null, ConnectTagV2, tags))
+	require.Empty(t, connectTagWritten(pb.TransportType_Min, ConnectTagV2, tags))
+	require.Equal(t, v1, connectTagWritten(pb.TransportType_Min, 0, nil))
+
+	// the version is signalled to the station, unless it is the default
+	session := makeTestSession(t, "1.2.3.4:1234")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Nil(t, reg.generateClientToStation().ConnectTagVersion)
+	reg.connectTagVersion = ConnectTagV2
+	require.Equal(t, uint32(ConnectTagV2), reg.generateClientToStation().GetConnectTagVersion())
+}
@@ -0,0 +1,52 @@
+package tapdance
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultCopyBufferSize - Size of the buffers io.Copy allocates, used by BufferPools
+// created with a size of 0
+const DefaultCopyBufferSize = 32 * 1024
+
+// BufferPool - Copy buffers of a fixed size, shared by the tunnels of a proxy so that
+// many concurrent tunnels reuse buffers instead of allocating two each. Larger buffers
+// mean fewer read and write calls on high throughput tunnels, at the cost of memory
+// per active copy. A nil *BufferPool copies with io.Copy's own buffers.
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool - Create a pool of buffers of size bytes, DefaultCopyBufferSize if 0
+func NewBufferPool(size int) *BufferPool {
+	if size <= 0 {
+		size = DefaultCopyBufferSize
+	}
+	p := &BufferPool{size: size}
+	p.pool.New = func() interface{} {
+		buf := make([]byte, size)
+		return &buf
+	}
+	return p
+}
+
+// Size - Get the size of the buffers of the pool
+func (p *BufferPool) Size() int {
+	if p == nil {
+		return DefaultCopyBufferSize
+	}
+	return p.size
+}
+
+// Copy - io.CopyBuffer from src to dst with a buffer of the pool. As with
+// io.CopyBuffer, the buffer is bypassed when src implements io.WriterTo or dst
+// implements io.ReaderFrom, e.g. between two *net.TCPConn.
+func (p *BufferPool) Copy(dst io.Writer, src io.Reader) (int64, error) {
+	if p == nil {
+		return io.Copy(dst, src)
+	}
+	buf := p.pool.Get().(*[]byte)
+	defer p.pool.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
@@ -0,0 +1,70 @@
+package tapdance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// onlyWriter and onlyReader hide io.ReaderFrom and io.WriterTo so copies go
+// through the buffer
+type onlyWriter struct{ io.Writer }
+type onlyReader struct{ io.Reader }
+
+func TestBufferPoolCopy(t *testing.T) {
+	data := bytes.Repeat([]byte("tapdance"), 100000)
+	for _, p := range []*BufferPool{nil, NewBufferPool(0), NewBufferPool(1024)} {
+		var out bytes.Buffer
+		n, err := p.Copy(onlyWriter{&out}, onlyReader{bytes.NewReader(data)})
+		require.Nil(t, err)
+		require.Equal(t, int64(len(data)), n)
+		require.Equal(t, data, out.Bytes())
+	}
+	require.Equal(t, DefaultCopyBufferSize, NewBufferPool(0).Size())
+	require.Equal(t, 1024, NewBufferPool(1024).Size())
+}
+
+// BenchmarkBufferPoolCopy - Throughput of copies into a loopback TCP connection by
+// buffer size
+func BenchmarkBufferPoolCopy(b *testing.B) {
+	const copySize = 8 << 20
+	for _, size := range []int{4 << 10, 32 << 10, 128 << 10, 512 << 10} {
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.Nil(b, err)
+			defer l.Close()
+			go func() {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				io.Copy(io.Discard, c)
+				c.Close()
+			}()
+			conn, err := net.Dial("tcp", l.Addr().String())
+			require.Nil(b, err)
+			defer conn.Close()
+
+			pool := NewBufferPool(size)
+			b.SetBytes(copySize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := pool.Copy(onlyWriter{conn}, onlyReader{io.LimitReader(zeroReader{}, copySize)})
+				require.Nil(b, err)
+			}
+		})
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
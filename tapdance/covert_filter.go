@@ -0,0 +1,113 @@
+package tapdance
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// CovertFilter - Restricts the covert destinations a Dialer tunnels to. Rules are
+// either CIDR blocks (or single IP addresses), which match covert addresses given as
+// literal IPs, or host globs such as "*.example.com" matched case-insensitively
+// against the covert host (see path.Match). Hostnames are never resolved locally,
+// so CIDR rules do not match covert addresses given by name.
+//
+// A covert address is rejected if it matches any deny rule, or if allow rules are set
+// and it matches none of them. A nil *CovertFilter allows everything.
+type CovertFilter struct {
+	allow []covertRule
+	deny  []covertRule
+}
+
+type covertRule struct {
+	subnet *net.IPNet
+	glob   string
+}
+
+// NewCovertFilter - Create a filter from allow and deny rules. Returns an error if a
+// rule is neither a valid CIDR, IP address nor host glob.
+func NewCovertFilter(allow, deny []string) (*CovertFilter, error) {
+	var f CovertFilter
+	var err error
+	if f.allow, err = parseCovertRules(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parseCovertRules(deny); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseCovertRules(rules []string) ([]covertRule, error) {
+	var parsed []covertRule
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if strings.Contains(rule, "/") {
+			_, subnet, err := net.ParseCIDR(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid covert filter rule %q: %v", rule, err)
+			}
+			parsed = append(parsed, covertRule{subnet: subnet})
+		} else if ip := net.ParseIP(rule); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			parsed = append(parsed, covertRule{subnet: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}})
+		} else {
+			if _, err := path.Match(rule, ""); err != nil {
+				return nil, fmt.Errorf("invalid covert filter rule %q: %v", rule, err)
+			}
+			parsed = append(parsed, covertRule{glob: strings.ToLower(rule)})
+		}
+	}
+	return parsed, nil
+}
+
+func (r covertRule) match(host string, ip net.IP) bool {
+	if r.subnet != nil {
+		return ip != nil && r.subnet.Contains(ip)
+	}
+	matched, _ := path.Match(r.glob, host)
+	return matched
+}
+
+// Check - Get an error if the covert address (host:port) is not allowed. An empty
+// address leaves the destination to the station, and is only rejected when allow
+// rules are set.
+func (f *CovertFilter) Check(covert string) error {
+	if f == nil {
+		return nil
+	}
+	if covert == "" {
+		if len(f.allow) > 0 {
+			return fmt.Errorf("covert address required by allowlist")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(covert)
+	if err != nil {
+		return err
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, rule := range f.deny {
+		if rule.match(host, ip) {
+			return fmt.Errorf("covert address %q is denied", covert)
+		}
+	}
+	if len(f.allow) == 0 {
+		return nil
+	}
+	for _, rule := range f.allow {
+		if rule.match(host, ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("covert address %q is not allowed", covert)
+}
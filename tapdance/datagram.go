@@ -0,0 +1,72 @@
+package tapdance
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxDatagramSize - Largest datagram that fits the 2-byte length framing
+const maxDatagramSize = 1<<16 - 1
+
+// DatagramConn - Carries datagrams over a stream connection, such as a Conjure connection
+// registered with CovertUDP, each framed by its length as a 2-byte big-endian integer.
+// Read and Write transfer exactly one datagram per call, like a connected net.UDPConn.
+type DatagramConn struct {
+	net.Conn
+
+	readMu  sync.Mutex
+	writeMu sync.Mutex
+}
+
+// NewDatagramConn - Frame datagrams over conn
+func NewDatagramConn(conn net.Conn) *DatagramConn {
+	return &DatagramConn{Conn: conn}
+}
+
+// Read - Read the next datagram into p. If p is too small the rest of the datagram
+// is discarded and io.ErrShortBuffer is returned with the truncated length.
+func (c *DatagramConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+		return 0, err
+	}
+	size := int(binary.BigEndian.Uint16(header[:]))
+
+	if size > len(p) {
+		n, err := io.ReadFull(c.Conn, p)
+		if err != nil {
+			return n, err
+		}
+		if _, err := io.CopyN(io.Discard, c.Conn, int64(size-n)); err != nil {
+			return n, err
+		}
+		return n, io.ErrShortBuffer
+	}
+
+	return io.ReadFull(c.Conn, p[:size])
+}
+
+// Write - Send p as a single datagram
+func (c *DatagramConn) Write(p []byte) (int, error) {
+	if len(p) > maxDatagramSize {
+		return 0, fmt.Errorf("datagram of %d bytes exceeds the maximum of %d", len(p), maxDatagramSize)
+	}
+
+	frame := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(frame, uint16(len(p)))
+	copy(frame[2:], p)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,58 @@
+package tapdance
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatagramConn(t *testing.T) {
+	client, server := net.Pipe()
+	sender := NewDatagramConn(client)
+	receiver := NewDatagramConn(server)
+	defer sender.Close()
+	defer receiver.Close()
+
+	datagrams := [][]byte{[]byte("first"), {}, bytes.Repeat([]byte{0x42}, maxDatagramSize), []byte("truncated datagram"), []byte("last")}
+	go func() {
+		for _, d := range datagrams {
+			_, err := sender.Write(d)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for _, d := range datagrams[:3] {
+		n, err := receiver.Read(buf)
+		require.Nil(t, err)
+		require.Equal(t, d, buf[:n])
+	}
+
+	// a short buffer truncates one datagram without desynchronizing the stream
+	n, err := receiver.Read(buf[:9])
+	require.Equal(t, io.ErrShortBuffer, err)
+	require.Equal(t, []byte("truncated"), buf[:n])
+	n, err = receiver.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, []byte("last"), buf[:n])
+
+	_, err = sender.Write(make([]byte, maxDatagramSize+1))
+	require.NotNil(t, err)
+}
+
+func TestCovertUDPRegistration(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:53")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Nil(t, reg.generateClientToStation().CovertUdp)
+
+	session.CovertUDP = true
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	require.True(t, reg.generateClientToStation().GetCovertUdp())
+}
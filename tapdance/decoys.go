@@ -0,0 +1,103 @@
+package tapdance
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// decoyTestWorkers - decoys tested in parallel by TestDecoys
+const decoyTestWorkers = 20
+
+// decoyTestTimeout - time allowed to each decoy for the TCP and TLS handshakes
+const decoyTestTimeout = 10 * time.Second
+
+// DecoyResult - Reachability of a single decoy, as measured by TestDecoys
+type DecoyResult struct {
+	Decoy *pb.TLSDecoySpec
+
+	// TCPRTT is the time to establish the TCP connection, zero if it failed
+	TCPRTT time.Duration
+
+	// TLSTime is the duration of the TLS handshake, zero if it did not complete
+	TLSTime time.Duration
+
+	// Err is nil if the decoy completed the TLS handshake
+	Err error
+}
+
+// TestDecoys - Connect to every decoy in the assets over TCP and TLS, the same way
+// registrations do, and report which are reachable and their RTT. Useful to vet a decoy
+// list before deploying it. The results are in the order of the assets decoy list.
+func TestDecoys(ctx context.Context) []DecoyResult {
+	return TestDecoyList(ctx, Assets().GetAllDecoys())
+}
+
+// TestDecoyList - Like TestDecoys, for the given decoys instead of all those of the
+// assets, e.g. a sample of them for a quick reachability check
+func TestDecoyList(ctx context.Context, decoys []*pb.TLSDecoySpec) []DecoyResult {
+	var d net.Dialer
+	return testDecoys(ctx, decoys, d.DialContext)
+}
+
+// TestDecoyListDialer - Like TestDecoyList, connecting to the decoys with dialer, e.g.
+// the DecoyDialer of registrations so that decoys are tested through the same proxy
+func TestDecoyListDialer(ctx context.Context, decoys []*pb.TLSDecoySpec,
+	dialer func(ctx context.Context, network, addr string) (net.Conn, error)) []DecoyResult {
+	return testDecoys(ctx, decoys, dialer)
+}
+
+func testDecoys(ctx context.Context, decoys []*pb.TLSDecoySpec, dialer dialFunc) []DecoyResult {
+	results := make([]DecoyResult, len(decoys))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < decoyTestWorkers && w < len(decoys); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = testDecoy(ctx, decoys[i], dialer)
+			}
+		}()
+	}
+	for i := range decoys {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func testDecoy(ctx context.Context, decoy *pb.TLSDecoySpec, dialer dialFunc) DecoyResult {
+	result := DecoyResult{Decoy: decoy}
+
+	childCtx, cancel := context.WithTimeout(ctx, decoyTestTimeout)
+	defer cancel()
+
+	tcpStart := time.Now()
+	dialConn, err := dialer(childCtx, "tcp", decoy.GetIpAddrStr())
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer dialConn.Close()
+	result.TCPRTT = time.Since(tcpStart)
+
+	reg := &ConjureReg{sessionIDStr: "[decoy-test]"}
+	deadline, _ := childCtx.Deadline()
+	tlsStart := time.Now()
+	tlsConn, err := reg.createTLSConn(childCtx, dialConn, decoy.GetIpAddrStr(), decoy.GetHostname(), decoy.GetNoSni(), deadline)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.TLSTime = time.Since(tlsStart)
+	tlsConn.Close()
+
+	return result
+}
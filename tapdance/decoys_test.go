@@ -0,0 +1,56 @@
+package tapdance
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestDecoys(t *testing.T) {
+	// a decoy that accepts TCP but hangs up instead of speaking TLS
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	// and one that refuses TCP
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	// decoys are dialed on port 443: map their addresses to the test listeners
+	decoys := []*pb.TLSDecoySpec{
+		pb.InitTLSDecoySpec("127.0.0.2", "refused.example.com"),
+		pb.InitTLSDecoySpec("127.0.0.3", "hangup.example.com"),
+	}
+	testAddrs := map[string]string{
+		"127.0.0.2:443": closedAddr,
+		"127.0.0.3:443": l.Addr().String(),
+	}
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, testAddrs[address])
+	}
+
+	results := testDecoys(context.Background(), decoys, dialer)
+	require.Equal(t, 2, len(results))
+	for i, result := range results {
+		require.Equal(t, decoys[i], result.Decoy)
+		require.NotNil(t, result.Err)
+		require.Zero(t, result.TLSTime)
+	}
+	require.Zero(t, results[0].TCPRTT)
+	require.NotZero(t, results[1].TCPRTT)
+}
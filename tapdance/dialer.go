@@ -2,14 +2,29 @@ package tapdance
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net"
+	"time"
 
 	pb "github.com/dimuls/gotapdance/protobuf"
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/proxy"
 )
 
+// sessionsTotal - Sessions started by the process, numbering them: every session ID
+// is taken with GetAndInc, which is goroutine-safe, so IDs are unique even when
+// sessions start concurrently
 var sessionsTotal CounterUint64
 
+// ResetSessionIDs - Number the next sessions from 0 again, FOR TESTING ONLY, e.g. for
+// reproducible session IDs in logs. Sessions started before and after it may share
+// IDs.
+func ResetSessionIDs() {
+	sessionsTotal.Set(0)
+}
+
 // Dialer contains options and implements advanced functions for establishing TapDance connection.
 type Dialer struct {
 	SplitFlows bool
@@ -19,6 +34,26 @@ type Dialer struct {
 	//		connection when tunneling the whole device.
 	TcpDialer func(context.Context, string, string) (net.Conn, error)
 
+	// NetDialer, if set, is used for the TCP connections to decoys and phantoms when
+	// TcpDialer is not set, e.g. to bind a source address with LocalAddr, set socket
+	// options such as SO_MARK with Control, or tune KeepAlive. When both are nil, a
+	// zero net.Dialer is used.
+	NetDialer *net.Dialer
+
+	// PhantomV6Source, if set, is the source address of connections to v6 phantoms,
+	// e.g. a stable address instead of a temporary privacy address the OS may pick
+	// per connection. v6 phantoms are dialed with a copy of NetDialer whose LocalAddr
+	// is set to it, once checked to be a global v6 address of this host; otherwise
+	// their dial fails early with an Unreachable RegError. A custom TcpDialer can't be
+	// bound, so PhantomV6Source can't be used with one: set NetDialer instead.
+	PhantomV6Source net.IP
+
+	// DecoyDialer, if set, is used instead of TcpDialer for the TCP connections to
+	// decoys during Conjure registration, e.g. ProxyDialer(d) to register through
+	// an upstream proxy, or SourcePortDialer to control the source ports of decoy
+	// connections. Phantom connections still use TcpDialer.
+	DecoyDialer func(context.Context, string, string) (net.Conn, error)
+
 	DarkDecoy bool
 
 	// The type of registrar to use when performing Conjure registrations.
@@ -27,9 +62,166 @@ type Dialer struct {
 	// The type of transport to use for Conjure connections.
 	Transport pb.TransportType
 
+	// TransportWeights, when not empty, replaces Transport: each Conjure session
+	// picks its transport at random by weight, deterministically from its seed, and
+	// records it in its stats. Registrations reused from RegistrationCache keep the
+	// transport picked when registering.
+	TransportWeights TransportWeights
+
+	// PhantomServerName is the SNI of the TLS transport handshakes with phantoms.
+	// See ConjureSession for details.
+	PhantomServerName string
+
 	UseProxyHeader bool
 	V6Support      bool // *bool so that it is a nullable type. that can be overridden
 	Width          int
+
+	// RotateDecoys mixes a rotating offset into the decoy selection of Conjure
+	// sessions, spreading their load over the decoy list. See ConjureSession for
+	// details.
+	RotateDecoys bool
+
+	// LogSelection logs the decoys and phantoms of each Conjure registration at info
+	// level. See ConjureSession for details.
+	LogSelection bool
+
+	// ForceV6 restricts Conjure sessions to IPv6 decoys and phantoms only,
+	// overriding V6Support. Useful to debug v6 phantom reachability in isolation.
+	ForceV6 bool
+
+	// ExcludedPhantoms are subnets phantoms are never selected in. Only use with
+	// stations excluding the same subnets. See ConjureSession for details.
+	ExcludedPhantoms []*net.IPNet
+
+	// PhantomDialAttempts is the number of TCP dials to each phantom within the
+	// phantom dial deadline. See ConjureSession for details. Values below 2 dial once.
+	PhantomDialAttempts int
+
+	// RegPaddingMin and RegPaddingMax bound the random extra padding bytes added to
+	// registrations; RegPaddingAlign sets the size alignment (always a multiple of 3).
+	// See ConjureSession for details. Zero values keep the minimal padding.
+	RegPaddingAlign int
+	RegPaddingMin   int
+	RegPaddingMax   int
+
+	// RegistrationTimeout bounds the decoy registration phase separately from the
+	// phantom connection. See ConjureSession for details.
+	RegistrationTimeout time.Duration
+
+	// ConnectRetries, ConnectRetryBackoff and ReregisterOnRetry configure retries of
+	// failed phantom connections. See ConjureSession for details.
+	ConnectRetries      int
+	ConnectRetryBackoff time.Duration
+	ReregisterOnRetry   bool
+
+	// Timings overrides the randomized delays of Conjure sessions, and
+	// NoRegistrationSleep skips the sleep between registering and connecting at the
+	// cost of stealth. See ConjureSession for details.
+	Timings             *Timings
+	NoRegistrationSleep bool
+
+	// DecoyMinTLSVersion is the lowest TLS version accepted from decoys.
+	// See ConjureSession for details.
+	DecoyMinTLSVersion uint16
+
+	// VerifyDecoyCertificates verifies the certificates of decoys, which is skipped
+	// by default. See ConjureSession for details.
+	VerifyDecoyCertificates bool
+
+	// DecoyRootCAs are the roots decoy and front certificates are verified against,
+	// nil for the system roots. See ConjureSession for details.
+	DecoyRootCAs *x509.CertPool
+
+	// DecoyParrots are the ClientHellos parroted in turn in the handshakes with
+	// decoys. See ConjureSession for details.
+	DecoyParrots []tls.ClientHelloID
+
+	// DecoyTLSRetries is the number of handshakes retried with an alternate
+	// ClientHello after one with a decoy failed. See ConjureSession for details.
+	DecoyTLSRetries int
+
+	// DecoyALPN overrides the ALPN protocols offered to decoys, nil for those of
+	// the parrots. See ConjureSession for details.
+	DecoyALPN []string
+
+	// ConnectTagVersion selects the connect tags sent on phantom connections.
+	// See ConjureSession for details.
+	ConnectTagVersion uint
+
+	// ConnectTags defines the connect tags of transports, in place of their default
+	// ones. See ConjureSession for details.
+	ConnectTags map[pb.TransportType]ConnectTagFunc
+
+	// CovertUDP makes Conjure connections relay datagrams to a UDP covert address.
+	// See ConjureSession for details.
+	CovertUDP bool
+
+	// CovertConnectTimeout bounds how long the station tries to connect to the covert
+	// address, and how long the client waits for it. See ConjureSession for details.
+	CovertConnectTimeout time.Duration
+
+	// SendClientAddress sends the address of the application client a Conjure dial is
+	// for, given with WithClientAddress on the dial context, to the station in the
+	// registration. See ConjureSession.ClientAddress for the privacy implications.
+	// Registrations are then only reused or shared by dials for the same client.
+	SendClientAddress bool
+
+	// FixedID, if set, is the ID string of every session created by this Dialer,
+	// for reproducible logs. See ConjureSession for details.
+	FixedID string
+
+	// StationPubkey, if set, is the 32-byte public key of the Conjure station to
+	// register with, taking precedence over the StationPubkeyEnv environment
+	// variable and the assets.
+	StationPubkey []byte
+
+	// KeyGenerator, if set, generates the client keys of Conjure sessions instead of
+	// ElligatorKeyGenerator (default). See KeyGenerator.
+	KeyGenerator KeyGenerator
+
+	// DumpRegistrations logs the bytes of every decoy registration for debugging.
+	// See ConjureSession for details.
+	DumpRegistrations bool
+
+	// ReadDecoyResponse reads the decoy HTTP responses to completion after
+	// registering, like a genuine page fetch. See ConjureSession for details.
+	ReadDecoyResponse bool
+
+	// PinClientConf pins the ClientConf generation for the registrations of this
+	// Dialer, leaving other Dialers unaffected unlike Assets().DisableClientConfUpdates:
+	// its registrations report the maximum generation so stations send no ClientConf,
+	// and those received on its TapDance connections are ignored.
+	PinClientConf bool
+
+	// StopAfterFirstRegistration cancels the registrations to the other decoys once
+	// one was sent. See ConjureSession for details.
+	StopAfterFirstRegistration bool
+
+	// HTTPRequestTemplate sets the HTTP requests carrying registrations to decoys.
+	// See ConjureSession for details.
+	HTTPRequestTemplate *HTTPRequestTemplate
+
+	// RegistrationCache, if set, keeps the registrations of Conjure connections to
+	// reconnect to their phantoms on later dials to the same covert address, instead
+	// of registering each time. Dialers copied from one another share it. See
+	// RegistrationCache for the protocol constraints.
+	RegistrationCache *RegistrationCache
+
+	// RegistrationGroup, if set, lets concurrent Conjure dials to the same covert
+	// address share a single registration instead of each registering. Dialers
+	// copied from one another share it. See RegistrationGroup for the protocol
+	// constraints.
+	RegistrationGroup *RegistrationGroup
+
+	// CovertFilter, if set, rejects covert addresses it does not allow before
+	// registering. Note that it only sees the address passed to DialContext: with
+	// DialProxy the destination is chosen later by the HTTP CONNECT request.
+	CovertFilter *CovertFilter
+
+	// Logger receives the logs of Conjure sessions created by this Dialer,
+	// allowing each dialer to have its own sink. When nil, the TapDance-wide
+	// Logger() is used.
+	Logger LeveledLogger
 }
 
 // Dial connects to the address on the named network.
@@ -71,11 +263,13 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 			return nil, err
 		}
 	}
+	if err := d.CovertFilter.Check(address); err != nil {
+		return nil, err
+	}
 
-	if d.TcpDialer == nil {
-		// custom dialer is not set, use default
-		defaultDialer := net.Dialer{}
-		d.TcpDialer = defaultDialer.DialContext
+	tcpDialer := d.TcpDialer
+	if tcpDialer == nil {
+		tcpDialer = d.defaultTcpDialer()
 	}
 
 	if !d.SplitFlows {
@@ -84,33 +278,220 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 			if err != nil {
 				return nil, err
 			}
-			flow.tdRaw.TcpDialer = d.TcpDialer
+			flow.tdRaw.TcpDialer = tcpDialer
 			flow.tdRaw.useProxyHeader = d.UseProxyHeader
+			flow.tdRaw.pinClientConf = d.PinClientConf
 			return flow, flow.DialContext(ctx)
 		} else {
 			// _, err := makeTdFlow(flowBidirectional, nil, address)
 			// if err != nil {
 			// 	return nil, err
 			// }
-			cjSession := makeConjureSession(address, d.Transport)
-			cjSession.TcpDialer = d.TcpDialer
-			cjSession.UseProxyHeader = d.UseProxyHeader
-			cjSession.Width = uint(d.Width)
-
-			if d.V6Support {
-				cjSession.V6Support = &V6{include: both, support: true}
-			} else {
-				cjSession.V6Support = &V6{include: v4, support: false}
-			}
 			if len(address) == 0 {
 				return nil, errors.New("Dark Decoys require target address to be set")
 			}
-			return DialConjure(ctx, cjSession, d.DarkDecoyRegistrar)
+			return d.dialConjure(ctx, address)
 		}
 	}
 	return nil, errors.New("SplitFlows are not supported")
 }
 
+// dialConjure connects to address through a Conjure session, reusing a registration
+// from RegistrationCache when it holds a valid one, or sharing the one of a concurrent
+// dial through RegistrationGroup.
+func (d *Dialer) dialConjure(ctx context.Context, address string) (net.Conn, error) {
+	var transport fmt.Stringer = d.Transport
+	if len(d.TransportWeights) > 0 {
+		transport = d.TransportWeights
+	}
+	key := registrationCacheKey(transport, d.CovertUDP, address)
+	var clientAddress string
+	if d.SendClientAddress {
+		clientAddress = clientAddressFromContext(ctx)
+		key += "/" + clientAddress
+	}
+	if conn := d.RegistrationCache.reconnect(ctx, key); conn != nil {
+		return conn, nil
+	}
+
+	conn, reg, err := d.RegistrationGroup.dial(ctx, key, func() (net.Conn, *ConjureReg, error) {
+		cjSession, err := d.makeConjureSession(address)
+		if err != nil {
+			return nil, nil, err
+		}
+		cjSession.ClientAddress = clientAddress
+		return dialConjure(ctx, cjSession, d.DarkDecoyRegistrar)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.RegistrationCache.store(key, reg)
+	return conn, nil
+}
+
+// clientAddressKey - Context key of the address given with WithClientAddress
+type clientAddressKey struct{}
+
+// WithClientAddress - Get a context to dial with for the application client at addr,
+// e.g. the remote address of the connection accepted from it, sent to the station
+// when Dialer.SendClientAddress is set
+func WithClientAddress(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientAddressKey{}, addr)
+}
+
+// clientAddressFromContext - Get the address given with WithClientAddress, if any
+func clientAddressFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(clientAddressKey{}).(string)
+	return addr
+}
+
+// RegisterOnly performs a Conjure registration for the address without connecting
+// to the phantom, and reports which decoys succeeded and which phantoms were selected.
+// The registration is made with DarkDecoyRegistrar, as for a dial. See the
+// package-level RegisterOnly.
+func (d *Dialer) RegisterOnly(ctx context.Context, address string) (*ConjureReg, error) {
+	if len(address) == 0 {
+		return nil, errors.New("Dark Decoys require target address to be set")
+	}
+	if err := d.CovertFilter.Check(address); err != nil {
+		return nil, err
+	}
+	cjSession, err := d.makeConjureSession(address)
+	if err != nil {
+		return nil, err
+	}
+	return RegisterOnly(ctx, cjSession, d.DarkDecoyRegistrar)
+}
+
+// defaultTcpDialer returns the dialer used when TcpDialer is not set: NetDialer if
+// configured, a zero net.Dialer otherwise.
+func (d *Dialer) defaultTcpDialer() func(context.Context, string, string) (net.Conn, error) {
+	if d.NetDialer != nil {
+		return d.NetDialer.DialContext
+	}
+	defaultDialer := net.Dialer{}
+	return defaultDialer.DialContext
+}
+
+// makeConjureSession creates a Conjure session to address configured with the Dialer options.
+func (d *Dialer) makeConjureSession(address string) (*ConjureSession, error) {
+	if err := validateCovertAddress(address); err != nil {
+		return nil, err
+	}
+	if len(d.TransportWeights) > 0 {
+		if err := d.TransportWeights.validate(); err != nil {
+			return nil, err
+		}
+	}
+	// The logger and ID are set before generating keys, which logs with them
+	cjSession := newConjureSessionDefaults(address, d.Transport)
+	cjSession.Logger = d.Logger
+	cjSession.FixedID = d.FixedID
+	if err := cjSession.generateKeys(d.StationPubkey, d.KeyGenerator); err != nil {
+		return nil, fmt.Errorf("failed to create Conjure session: %v", err)
+	}
+	if len(d.TransportWeights) > 0 {
+		cjSession.Transport = d.TransportWeights.pick(cjSession.Keys.ConjureSeed)
+		cjSession.logger().Infof("%v picked transport %v from %v", cjSession.IDString(), cjSession.Transport, d.TransportWeights)
+	}
+
+	cjSession.TcpDialer = d.TcpDialer
+	if d.PhantomV6Source != nil && d.TcpDialer != nil {
+		return nil, errors.New("PhantomV6Source can't bind a custom TcpDialer, set NetDialer instead")
+	}
+	cjSession.phantomV6Source = d.PhantomV6Source
+	cjSession.PhantomServerName = d.PhantomServerName
+	cjSession.netDialer = d.NetDialer
+	if cjSession.TcpDialer == nil {
+		cjSession.TcpDialer = d.defaultTcpDialer()
+	}
+	cjSession.DecoyDialer = d.DecoyDialer
+	cjSession.UseProxyHeader = d.UseProxyHeader
+	cjSession.Width = uint(d.Width)
+	cjSession.RotateDecoys = d.RotateDecoys
+	cjSession.LogSelection = d.LogSelection
+	cjSession.ExcludedPhantoms = d.ExcludedPhantoms
+	if d.PhantomDialAttempts > 1 {
+		cjSession.PhantomDialAttempts = uint(d.PhantomDialAttempts)
+	}
+	if d.RegPaddingAlign > 0 {
+		cjSession.RegPaddingAlign = uint(d.RegPaddingAlign)
+	}
+	if d.RegPaddingMin > 0 {
+		cjSession.RegPaddingMin = uint(d.RegPaddingMin)
+	}
+	if d.RegPaddingMax > 0 {
+		cjSession.RegPaddingMax = uint(d.RegPaddingMax)
+	}
+	cjSession.RegistrationTimeout = d.RegistrationTimeout
+	cjSession.CovertUDP = d.CovertUDP
+	cjSession.CovertConnectTimeout = d.CovertConnectTimeout
+	cjSession.DecoyMinTLSVersion = d.DecoyMinTLSVersion
+	cjSession.VerifyDecoyCertificates = d.VerifyDecoyCertificates
+	cjSession.DecoyRootCAs = d.DecoyRootCAs
+	cjSession.DecoyParrots = d.DecoyParrots
+	if d.DecoyTLSRetries > 0 {
+		cjSession.DecoyTLSRetries = uint(d.DecoyTLSRetries)
+	}
+	cjSession.DecoyALPN = d.DecoyALPN
+	cjSession.ConnectTagVersion = d.ConnectTagVersion
+	cjSession.ConnectTags = d.ConnectTags
+	if d.ConnectRetries > 0 {
+		cjSession.ConnectRetries = uint(d.ConnectRetries)
+	}
+	cjSession.ConnectRetryBackoff = d.ConnectRetryBackoff
+	cjSession.ReregisterOnRetry = d.ReregisterOnRetry
+	cjSession.Timings = d.Timings
+	cjSession.NoRegistrationSleep = d.NoRegistrationSleep
+	cjSession.DumpRegistrations = d.DumpRegistrations
+	cjSession.ReadDecoyResponse = d.ReadDecoyResponse
+	cjSession.PinClientConf = d.PinClientConf
+	cjSession.StopAfterFirstRegistration = d.StopAfterFirstRegistration
+	cjSession.HTTPRequestTemplate = d.HTTPRequestTemplate
+
+	if d.ForceV6 {
+		cjSession.V6Support = &V6{include: v6, support: true}
+	} else if d.V6Support {
+		cjSession.V6Support = &V6{include: both, support: true}
+	} else {
+		cjSession.V6Support = &V6{include: v4, support: false}
+	}
+	return cjSession, nil
+}
+
+// ProxyDialer adapts a golang.org/x/net/proxy Dialer (e.g. from proxy.SOCKS5 or
+// proxy.FromURL) for use as Dialer.TcpDialer or Dialer.DecoyDialer.
+// Dialers without DialContext are abandoned, and the connection closed, when ctx is done.
+func ProxyDialer(d proxy.Dialer) func(context.Context, string, string) (net.Conn, error) {
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		type dialResult struct {
+			conn net.Conn
+			err  error
+		}
+		results := make(chan dialResult, 1)
+		go func() {
+			conn, err := d.Dial(network, address)
+			results <- dialResult{conn, err}
+		}()
+
+		select {
+		case res := <-results:
+			return res.conn, res.err
+		case <-ctx.Done():
+			go func() {
+				if res := <-results; res.conn != nil {
+					res.conn.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+}
+
 // DialProxy establishes direct connection to TapDance station proxy.
 // Users are expected to send HTTP CONNECT request next.
 func (d *Dialer) DialProxy() (net.Conn, error) {
@@ -2,16 +2,26 @@ package tapdance
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	pb "github.com/dimuls/gotapdance/protobuf"
+	ps "github.com/dimuls/gotapdance/tapdance/phantoms"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestAssets() error {
@@ -134,3 +144,342 @@ func getResponseString(url url.URL, dial func(network, address string) (net.Conn
 	}
 	return string(responseBody), nil
 }
+
+func TestDialerLogger(t *testing.T) {
+	var b bytes.Buffer
+	testLogger := logrus.New()
+	testLogger.Out = &b
+	testLogger.Level = logrus.DebugLevel
+
+	failingDialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("test dialer always fails")
+	}
+
+	tdDialer := Dialer{
+		DarkDecoy:          true,
+		DarkDecoyRegistrar: DecoyRegistrar{},
+		TcpDialer:          failingDialer,
+		Width:              1,
+		Logger:             testLogger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := tdDialer.DialContext(ctx, "tcp", "1.2.3.4:443")
+	require.NotNil(t, err)
+	require.Contains(t, b.String(), "Registering V4 and V6 via DecoyRegistrar")
+
+	// session creation logs to the Dialer logger too, without key material
+	b.Reset()
+	cjSession, err := tdDialer.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	require.Contains(t, b.String(), "Using the station public key from the assets")
+	require.Contains(t, b.String(), cjSession.IDString()+" covert 1.2.3.4:443")
+	require.NotContains(t, b.String(), hex.EncodeToString(cjSession.Keys.SharedSecret))
+	require.NotContains(t, b.String(), hex.EncodeToString(cjSession.Keys.Representative))
+}
+
+// blockingProxy is a proxy.Dialer without DialContext that never connects
+type blockingProxy struct {
+	release chan struct{}
+}
+
+func (p blockingProxy) Dial(network, addr string) (net.Conn, error) {
+	<-p.release
+	return nil, errors.New("released")
+}
+
+func TestProxyDialerContext(t *testing.T) {
+	p := blockingProxy{release: make(chan struct{})}
+	defer close(p.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ProxyDialer(p)(ctx, "tcp", "1.2.3.4:443")
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCovertFilter(t *testing.T) {
+	var nilFilter *CovertFilter
+	require.Nil(t, nilFilter.Check("1.2.3.4:443"))
+
+	_, err := NewCovertFilter([]string{"10.0.0.0/33"}, nil)
+	require.NotNil(t, err)
+	_, err = NewCovertFilter(nil, []string{"[bad"})
+	require.NotNil(t, err)
+
+	f, err := NewCovertFilter(
+		[]string{"10.0.0.0/8", "2001:db8::/32", "*.example.com", "example.org"},
+		[]string{"10.1.2.3", "secret.example.com"})
+	require.Nil(t, err)
+
+	for _, addr := range []string{"10.9.8.7:443", "[2001:db8::1]:80", "www.EXAMPLE.com:443", "example.org.:22"} {
+		require.Nil(t, f.Check(addr), addr)
+	}
+	for _, addr := range []string{"10.1.2.3:443", "secret.example.com:443", "11.0.0.1:443", "example.com:443", "www.example.org:80", ""} {
+		require.NotNil(t, f.Check(addr), addr)
+	}
+
+	// deny-only filters allow the rest, including the station's default covert
+	f, err = NewCovertFilter(nil, []string{"192.168.0.0/16"})
+	require.Nil(t, err)
+	require.NotNil(t, f.Check("192.168.1.1:80"))
+	require.Nil(t, f.Check("8.8.8.8:53"))
+	require.Nil(t, f.Check(""))
+
+	// disallowed targets are rejected before registration
+	d := Dialer{DarkDecoy: true, DarkDecoyRegistrar: DecoyRegistrar{}, CovertFilter: f}
+	_, err = d.DialContext(context.Background(), "tcp", "192.168.1.1:80")
+	require.Contains(t, err.Error(), "denied")
+}
+
+func TestDialerNetDialer(t *testing.T) {
+	var controlled []string
+	d := Dialer{
+		DarkDecoy: true,
+		NetDialer: &net.Dialer{
+			Control: func(network, address string, c syscall.RawConn) error {
+				controlled = append(controlled, address)
+				return errors.New("blocked by control")
+			},
+		},
+	}
+
+	session, err := d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	_, err = session.TcpDialer(context.Background(), "tcp", "127.0.0.1:1")
+	require.Contains(t, err.Error(), "blocked by control")
+	require.Equal(t, []string{"127.0.0.1:1"}, controlled)
+
+	// an explicit TcpDialer takes precedence
+	d.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("custom TcpDialer")
+	}
+	session, err = d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	_, err = session.TcpDialer(context.Background(), "tcp", "127.0.0.1:1")
+	require.Contains(t, err.Error(), "custom TcpDialer")
+
+	// which a v6 source can't be bound to
+	d.PhantomV6Source = net.ParseIP("2001:db8::1")
+	_, err = d.makeConjureSession("1.2.3.4:443")
+	require.NotNil(t, err)
+	d.TcpDialer = nil
+	session, err = d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	require.Equal(t, d.NetDialer, session.netDialer)
+}
+
+func TestDialerFixedID(t *testing.T) {
+	d := Dialer{DarkDecoy: true}
+	session, err := d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	require.Regexp(t, `^\[[0-9]+-[0-9a-f]{6}\]$`, session.IDString())
+
+	d.FixedID = "repro-1"
+	for i := 0; i < 2; i++ {
+		session, err = d.makeConjureSession("1.2.3.4:443")
+		require.Nil(t, err)
+		require.Equal(t, "[repro-1]", session.IDString())
+	}
+}
+
+func TestDialerConcurrentSessionIDs(t *testing.T) {
+	ResetSessionIDs()
+	d := Dialer{DarkDecoy: true}
+	const sessions = 200
+	ids := make(chan uint64, sessions)
+	errs := make(chan error, sessions)
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session, err := d.makeConjureSession("1.2.3.4:443")
+			if err != nil {
+				errs <- err
+				return
+			}
+			ids <- session.SessionID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	// every session got its own ID, numbered from the reset
+	seen := make(map[uint64]bool)
+	for id := range ids {
+		require.False(t, seen[id], "duplicate session ID %v", id)
+		require.Less(t, id, uint64(sessions))
+		seen[id] = true
+	}
+	require.Len(t, seen, sessions)
+}
+
+func TestDialerClientAddress(t *testing.T) {
+	registrar := &failingRegistrar{}
+	d := Dialer{DarkDecoy: true, DarkDecoyRegistrar: registrar}
+	ctx := WithClientAddress(context.Background(), "192.0.2.7:51234")
+
+	// not sent unless enabled
+	_, err := d.DialContext(ctx, "tcp", "1.2.3.4:443")
+	require.NotNil(t, err)
+	require.Equal(t, "", registrar.session.ClientAddress)
+
+	d.SendClientAddress = true
+	_, err = d.DialContext(ctx, "tcp", "1.2.3.4:443")
+	require.NotNil(t, err)
+	require.Equal(t, "192.0.2.7:51234", registrar.session.ClientAddress)
+
+	session := makeTestSession(t, "1.2.3.4:443")
+	session.ClientAddress = registrar.session.ClientAddress
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, "192.0.2.7:51234", reg.generateClientToStation().GetClientAddress())
+	reg.clientAddress = ""
+	require.Nil(t, reg.generateClientToStation().ClientAddress)
+}
+
+// dialerRegisterOnly - d.RegisterOnly, with new sessions while their seed selects no
+// phantom, like makeTestSession
+func dialerRegisterOnly(d Dialer, ctx context.Context, address string) (*ConjureReg, error) {
+	for {
+		reg, err := d.RegisterOnly(ctx, address)
+		if !errors.Is(err, ps.ErrReservedAddress) && !errors.Is(err, ps.ErrNoSubnets) {
+			return reg, err
+		}
+	}
+}
+
+func TestDialerRegisterOnlyRegistrar(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	// the configured registrar is used, not a fresh decoy registration
+	registrar := &stubRegistrar{}
+	d := Dialer{DarkDecoy: true, DarkDecoyRegistrar: registrar}
+	reg, err := dialerRegisterOnly(d, context.Background(), "1.2.3.4:443")
+	require.Nil(t, err)
+	require.NotNil(t, reg)
+	require.NotZero(t, registrar.registrations)
+
+	// along with the TcpDialer of a configured DecoyRegistrar
+	d.DarkDecoyRegistrar = DecoyRegistrar{TcpDialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, fmt.Errorf("registrar dialer used for %s", address)
+	}}
+	d.Width = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	reg, err = dialerRegisterOnly(d, ctx, "1.2.3.4:443")
+	require.NotNil(t, err)
+	require.Equal(t, 1, len(reg.decoyResults))
+	require.Contains(t, reg.decoyResults[0].err.Error(), "registrar dialer used for")
+}
+
+func TestDialerTimings(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	timings := DefaultTimings()
+	timings.RegistrationSleep = Timing{Base: time.Minute}
+	d := Dialer{DarkDecoy: true, Timings: &timings, NoRegistrationSleep: true, Width: 1}
+
+	// a session whose seed selects phantoms, like makeTestSession
+	var session *ConjureSession
+	for session == nil {
+		s, err := d.makeConjureSession("1.2.3.4:443")
+		require.Nil(t, err)
+		if _, _, err := SelectPhantom(s.Keys.ConjureSeed, both); err == nil {
+			session = s
+		}
+	}
+	require.True(t, session.NoRegistrationSleep)
+
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, time.Minute, reg.getTimings().RegistrationSleep.Duration(100))
+
+	// the registration sleep of the timings is skipped
+	session.DecoyDialer = benchDecoy(t)
+	session.DecoySelector = func(*ConjureSession, []*pb.TLSDecoySpec) ([]*pb.TLSDecoySpec, error) {
+		return []*pb.TLSDecoySpec{pb.InitTLSDecoySpec("127.0.0.1", "example.com")}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	reg, err = DecoyRegistrar{}.Register(session, ctx)
+	require.Nil(t, err)
+	require.True(t, reg.anyDecoySucceeded())
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+}
+
+// pipeRegistrar registers without contacting decoys, to phantoms answering with pipes
+type pipeRegistrar struct {
+	registrations int
+	failDials     bool
+}
+
+func (r *pipeRegistrar) Register(cjSession *ConjureSession, ctx context.Context) (*ConjureReg, error) {
+	r.registrations++
+	return &ConjureReg{
+		sessionIDStr: cjSession.IDString(),
+		transport:    pb.TransportType_Null,
+		phantom4:     ipPtr("192.0.2.1"),
+		startTs:      time.Now(),
+		TcpDialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if r.failDials {
+				return nil, fmt.Errorf("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}, nil
+}
+
+func TestDialerRegistrationCache(t *testing.T) {
+	registrar := &pipeRegistrar{}
+	d := Dialer{DarkDecoy: true, DarkDecoyRegistrar: registrar, Transport: pb.TransportType_Null}
+
+	dial := func(d Dialer, address string) {
+		conn, err := d.Dial("tcp", address)
+		require.Nil(t, err)
+		conn.Close()
+	}
+
+	// without a cache, every dial registers
+	dial(d, "1.2.3.4:443")
+	dial(d, "1.2.3.4:443")
+	require.Equal(t, 2, registrar.registrations)
+
+	// copies of the dialer share the cache
+	registrar.registrations = 0
+	d.RegistrationCache = NewRegistrationCache(time.Minute, 0)
+	dial(d, "1.2.3.4:443")
+	dial(d, "1.2.3.4:443")
+	udp := d
+	udp.CovertUDP = true
+	dial(udp, "1.2.3.4:443")
+	dial(udp, "1.2.3.4:443")
+	require.Equal(t, 2, registrar.registrations)
+
+	// other covert addresses register
+	dial(d, "1.2.3.4:80")
+	require.Equal(t, 3, registrar.registrations)
+
+	// failed reconnects register again
+	registrar.failDials = true
+	_, err := d.Dial("tcp", "1.2.3.4:80")
+	require.NotNil(t, err)
+	require.Equal(t, 4, registrar.registrations)
+	registrar.failDials = false
+	dial(d, "1.2.3.4:80")
+	require.Equal(t, 5, registrar.registrations)
+
+	// registrations expire
+	d.RegistrationCache.MaxAge = 0
+	dial(d, "1.2.3.4:443")
+	require.Equal(t, 6, registrar.registrations)
+}
@@ -0,0 +1,296 @@
+package tapdance
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// HTTPHeader - A header of an HTTPRequestTemplate
+type HTTPHeader struct {
+	Name  string
+	Value string
+}
+
+// HTTPRequestTemplate - Layout of the HTTP request carrying a Conjure registration to
+// a decoy: the request line and headers, sent in order and with the given casing.
+// The request ends with the TagHeader header, whose value is random padding followed
+// by the encoded registration tag.
+//
+// When the decoy negotiates HTTP/2 with ALPN, the request is sent as HTTP/2 frames
+// instead, starting the connection like the browser of the parroted ClientHello:
+// Method and Path become the :method and :path pseudo-headers, the Host header
+// becomes :authority, header names are lowercased and connection-specific headers,
+// e.g. Connection, are left out.
+type HTTPRequestTemplate struct {
+	// Method and Path of the request line. Default to "GET" and "/" when empty.
+	Method string
+	Path   string
+
+	// Headers are sent in order before TagHeader. "{host}" in a value is replaced by
+	// the decoy hostname, or its IP address for decoys without SNI.
+	Headers []HTTPHeader
+
+	// TagHeader is the name of the last header, carrying the tag. "X-Ignore" if empty.
+	TagHeader string
+}
+
+// hostPlaceholder is replaced by the decoy hostname in header values
+const hostPlaceholder = "{host}"
+
+// DefaultHTTPRequestTemplate - Headers of a Chrome 62 page load, matching the
+// ClientHello parroted with decoys by default.
+var DefaultHTTPRequestTemplate = HTTPRequestTemplate{
+	Headers: []HTTPHeader{
+		{"Host", hostPlaceholder},
+		{"Connection", "keep-alive"},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/62.0.3202.94 Safari/537.36"},
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8"},
+		{"Accept-Encoding", "gzip, deflate, br"},
+		{"Accept-Language", "en-US,en;q=0.9"},
+	},
+}
+
+// TapDanceHTTPRequestTemplate - The original registration request, with a
+// User-Agent pointing decoy operators to information about the project.
+var TapDanceHTTPRequestTemplate = HTTPRequestTemplate{
+	Headers: []HTTPHeader{
+		{"Host", hostPlaceholder},
+		{"User-Agent", "TapDance/1.2 (+https://refraction.network/info)"},
+	},
+}
+
+// Validate - Check that the template renders to a well-formed request
+func (t *HTTPRequestTemplate) Validate() error {
+	if strings.ContainsAny(t.Method, " \r\n") {
+		return fmt.Errorf("invalid HTTP method %q", t.Method)
+	}
+	if strings.ContainsAny(t.Path, " \r\n") {
+		return fmt.Errorf("invalid HTTP path %q", t.Path)
+	}
+	for _, h := range append(t.Headers, HTTPHeader{Name: t.getTagHeader()}) {
+		if h.Name == "" || strings.ContainsAny(h.Name, ": \t\r\n") {
+			return fmt.Errorf("invalid HTTP header name %q", h.Name)
+		}
+		if strings.ContainsAny(h.Value, "\r\n") {
+			return fmt.Errorf("invalid value for HTTP header %v: %q", h.Name, h.Value)
+		}
+	}
+	return nil
+}
+
+func (t *HTTPRequestTemplate) getTagHeader() string {
+	if t.TagHeader == "" {
+		return "X-Ignore"
+	}
+	return t.TagHeader
+}
+
+// render - The beginning of the request for the decoy host, up to the padding in the
+// value of the tag header. The tag and the final "\r\n\r\n" are appended by the
+// caller. The padding size keeps the headers around 612 bytes, whichever the template.
+func (t *HTTPRequestTemplate) render(decoyHostname string) []byte {
+	method, path := t.Method, t.Path
+	if method == "" {
+		method = "GET"
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	request := method + " " + path + " HTTP/1.1\r\n"
+	for _, h := range t.renderHeaders(decoyHostname) {
+		request += h.Name + ": " + h.Value + "\r\n"
+	}
+	return []byte(request + t.getTagHeader() + ": " + t.padding(decoyHostname))
+}
+
+// renderHeaders - The headers of the template with the decoy host filled in
+func (t *HTTPRequestTemplate) renderHeaders(decoyHostname string) []HTTPHeader {
+	headers := make([]HTTPHeader, 0, len(t.Headers))
+	for _, h := range t.Headers {
+		headers = append(headers, HTTPHeader{h.Name, strings.Replace(h.Value, hostPlaceholder, decoyHostname, -1)})
+	}
+	return headers
+}
+
+// padding - Random padding preceding the tag in the value of the tag header
+func (t *HTTPRequestTemplate) padding(decoyHostname string) string {
+	headers := make([]string, 0, len(t.Headers))
+	for _, h := range t.renderHeaders(decoyHostname) {
+		headers = append(headers, h.Name+": "+h.Value)
+	}
+	// padding sized on the headers joined by "\n", as in the original request
+	return getRandPadding(7, maxInt(612-len(strings.Join(headers, "\n")), 7), 10)
+}
+
+// http2Fingerprint - How a browser starts its HTTP/2 connections: the frames it sends
+// after the client preface, and the stream, priority and pseudo-header order of its
+// first request
+type http2Fingerprint struct {
+	settings        []http2.Setting
+	windowIncrement uint32
+	// priorities are sent as PRIORITY frames before the request, e.g. the dependency
+	// tree Firefox builds from idle streams
+	priorities      []http2Priority
+	requestStream   uint32
+	requestPriority http2.PriorityParam
+	pseudoHeaders   []string
+}
+
+type http2Priority struct {
+	stream uint32
+	param  http2.PriorityParam
+}
+
+// http2ChromeFingerprint - Chrome 58 to 62, also used for parrots of other clients
+var http2ChromeFingerprint = http2Fingerprint{
+	settings: []http2.Setting{
+		{ID: http2.SettingHeaderTableSize, Val: 65536},
+		{ID: http2.SettingMaxConcurrentStreams, Val: 1000},
+		{ID: http2.SettingInitialWindowSize, Val: 6291456},
+	},
+	windowIncrement: 15663105,
+	requestStream:   1,
+	requestPriority: http2.PriorityParam{Exclusive: true, Weight: 255},
+	pseudoHeaders:   []string{":method", ":authority", ":scheme", ":path"},
+}
+
+// http2Fingerprints - Fingerprints by tls.ClientHelloID.Client of the parrots
+var http2Fingerprints = map[string]http2Fingerprint{
+	tls.HelloChrome_62.Client: http2ChromeFingerprint,
+	// Firefox 55 and 56
+	tls.HelloFirefox_56.Client: {
+		settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingInitialWindowSize, Val: 131072},
+			{ID: http2.SettingMaxFrameSize, Val: 16384},
+		},
+		windowIncrement: 12517377,
+		priorities: []http2Priority{
+			{3, http2.PriorityParam{Weight: 200}},
+			{5, http2.PriorityParam{Weight: 100}},
+			{7, http2.PriorityParam{Weight: 0}},
+			{9, http2.PriorityParam{StreamDep: 7, Weight: 0}},
+			{11, http2.PriorityParam{StreamDep: 3, Weight: 0}},
+		},
+		requestStream:   13,
+		requestPriority: http2.PriorityParam{StreamDep: 3, Weight: 41},
+		pseudoHeaders:   []string{":method", ":path", ":authority", ":scheme"},
+	},
+	// Safari of iOS 11
+	tls.HelloIOS_11_1.Client: {
+		settings: []http2.Setting{
+			{ID: http2.SettingInitialWindowSize, Val: 2097152},
+			{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+		},
+		windowIncrement: 10485760,
+		requestStream:   1,
+		requestPriority: http2.PriorityParam{Weight: 255},
+		pseudoHeaders:   []string{":method", ":scheme", ":path", ":authority"},
+	},
+}
+
+// http2FingerprintOf - The fingerprint of the browser parroted by the ClientHello,
+// Chrome for clients without their own, e.g. randomized ClientHellos
+func http2FingerprintOf(parrot tls.ClientHelloID) http2Fingerprint {
+	if fingerprint, ok := http2Fingerprints[parrot.Client]; ok {
+		return fingerprint
+	}
+	return http2ChromeFingerprint
+}
+
+// http2TagTrailerSize - Bytes of the tag header value after the tag, which ends the
+// request 4 bytes after the tag like the final "\r\n\r\n" of HTTP/1.1 requests
+const http2TagTrailerSize = 4
+
+// http2ConnectionHeaders - Connection-specific headers, which are not allowed in
+// HTTP/2 requests
+var http2ConnectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// renderHTTP2 - The request for the decoy host as the HTTP/2 frames the browser of
+// parrot starts its connections with: the client preface, SETTINGS, WINDOW_UPDATE,
+// any PRIORITY frames, and the HEADERS frame of the request. The value of the tag
+// header ends with tagLen zero bytes, to be replaced by the encoded tag, and
+// http2TagTrailerSize random characters. The offset of the tag in the request is
+// returned as well.
+//
+// Header values are Huffman-coded by the HPACK encoder like browsers do, except for
+// the value of the tag header: it is sent as a literal, so that the tag is in the
+// plaintext of the TLS record as encoded.
+func (t *HTTPRequestTemplate) renderHTTP2(decoyHostname string, tagLen int, parrot tls.ClientHelloID) ([]byte, int) {
+	method, path := t.Method, t.Path
+	if method == "" {
+		method = "GET"
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	fingerprint := http2FingerprintOf(parrot)
+	pseudoHeaders := map[string]string{":method": method, ":authority": decoyHostname, ":scheme": "https", ":path": path}
+
+	var block bytes.Buffer
+	encoder := hpack.NewEncoder(&block)
+	for _, name := range fingerprint.pseudoHeaders {
+		encoder.WriteField(hpack.HeaderField{Name: name, Value: pseudoHeaders[name]})
+	}
+	for _, h := range t.renderHeaders(decoyHostname) {
+		name := strings.ToLower(h.Name)
+		if name == "host" || http2ConnectionHeaders[name] {
+			continue
+		}
+		encoder.WriteField(hpack.HeaderField{Name: name, Value: h.Value})
+	}
+	// literal header field without indexing, with a literal name
+	value := append([]byte(t.padding(decoyHostname)), make([]byte, tagLen)...)
+	value = append(value, getRandString(http2TagTrailerSize)...)
+	block.WriteByte(0)
+	block.Write(appendHPACKString(nil, []byte(strings.ToLower(t.getTagHeader()))))
+	block.Write(appendHPACKString(nil, value))
+
+	var request bytes.Buffer
+	request.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&request, nil)
+	framer.WriteSettings(fingerprint.settings...)
+	framer.WriteWindowUpdate(0, fingerprint.windowIncrement)
+	for _, priority := range fingerprint.priorities {
+		framer.WritePriority(priority.stream, priority.param)
+	}
+	framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      fingerprint.requestStream,
+		BlockFragment: block.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+		Priority:      fingerprint.requestPriority,
+	})
+	return request.Bytes(), request.Len() - http2TagTrailerSize - tagLen
+}
+
+// appendHPACKString - Append s to dst as an HPACK string literal, without Huffman coding
+func appendHPACKString(dst []byte, s []byte) []byte {
+	// length as an integer with a 7 bits prefix, after the cleared Huffman bit
+	const prefixMax = 1<<7 - 1
+	n := len(s)
+	if n < prefixMax {
+		dst = append(dst, byte(n))
+	} else {
+		dst = append(dst, prefixMax)
+		for n -= prefixMax; n >= 0x80; n >>= 7 {
+			dst = append(dst, byte(n&0x7f)|0x80)
+		}
+		dst = append(dst, byte(n))
+	}
+	return append(dst, s...)
+}
@@ -0,0 +1,237 @@
+package tapdance
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	tls "github.com/refraction-networking/utls"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestHTTPRequestTemplateRender(t *testing.T) {
+	request := string(TapDanceHTTPRequestTemplate.render("example.com"))
+	require.True(t, strings.HasPrefix(request, "GET / HTTP/1.1\r\nHost: example.com\r\n"+
+		"User-Agent: TapDance/1.2 (+https://refraction.network/info)\r\nX-Ignore: #######"), request)
+	require.True(t, strings.HasSuffix(request, "#"))
+
+	request = string(DefaultHTTPRequestTemplate.render("example.com"))
+	require.True(t, strings.HasPrefix(request, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: keep-alive\r\n"), request)
+	require.Contains(t, request, "Chrome/62.")
+
+	template := HTTPRequestTemplate{
+		Method:    "POST",
+		Path:      "/upload",
+		Headers:   []HTTPHeader{{"host", "{host}:443"}, {"x-lower", "v"}},
+		TagHeader: "Cookie",
+	}
+	require.Nil(t, template.Validate())
+	request = string(template.render("example.com"))
+	require.True(t, strings.HasPrefix(request, "POST /upload HTTP/1.1\r\nhost: example.com:443\r\nx-lower: v\r\nCookie: #"), request)
+
+	for _, invalid := range []HTTPRequestTemplate{
+		{Method: "GET /"},
+		{Path: "/\r\nX: y"},
+		{Headers: []HTTPHeader{{"", "v"}}},
+		{Headers: []HTTPHeader{{"Bad Name", "v"}}},
+		{Headers: []HTTPHeader{{"X", "v\r\nY: z"}}},
+		{TagHeader: "X-Tag:"},
+	} {
+		require.NotNil(t, invalid.Validate(), "%+v", invalid)
+	}
+}
+
+func TestCreateRequestTemplate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	decoy := pb.InitTLSDecoySpec("127.0.0.1", "template.test")
+	long := HTTPRequestTemplate{Headers: append(DefaultHTTPRequestTemplate.Headers, HTTPHeader{"X-Long", strings.Repeat("a", 1000)})}
+
+	for _, template := range []*HTTPRequestTemplate{nil, &TapDanceHTTPRequestTemplate, &long} {
+		session.HTTPRequestTemplate = template
+		reg, err := session.newConjureReg()
+		require.Nil(t, err)
+
+		dialConn, err := net.Dial("tcp", server.Listener.Addr().String())
+		require.Nil(t, err)
+		tlsConn := tls.UClient(dialConn, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}, tls.HelloGolang)
+		require.Nil(t, tlsConn.Handshake())
+
+		request, err := reg.createRequest(tlsConn, decoy)
+		require.Nil(t, err)
+		keystream, err := tlsConn.GetOutKeystream(len(request))
+		require.Nil(t, err)
+		tlsConn.Close()
+
+		// the encoded tag follows the padding, whatever the length of the headers
+		require.True(t, bytes.HasSuffix(request, []byte("\r\n\r\n")))
+		tagStart := bytes.LastIndexByte(request, '#') + 1
+		tag, err := ReverseDecrypt(request[tagStart:len(request)-4], keystream[tagStart:])
+		require.Nil(t, err)
+		encryptedFspSize := fspSize + 16 // fixed size payload and GCM tag
+		representative := tag[len(tag)-encryptedFspSize-len(reg.keys.Representative) : len(tag)-encryptedFspSize]
+		require.Equal(t, reg.keys.Representative, representative)
+
+		// the decrypted fixed size payload carries the payload version
+		fsp, err := aesGcmDecrypt(tag[len(tag)-encryptedFspSize:], reg.keys.FspKey, reg.keys.FspIv)
+		require.Nil(t, err)
+		require.Equal(t, byte(currentPayloadVersion), fsp[fspVersionOffset])
+	}
+}
+
+func TestCreateRequestHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	decoy := pb.InitTLSDecoySpec("127.0.0.1", "template.test")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	dialConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.Nil(t, err)
+	config := &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+	tlsConn := tls.UClient(dialConn, config, tls.HelloGolang)
+	require.Nil(t, tlsConn.Handshake())
+	defer tlsConn.Close()
+	require.True(t, decoyNegotiatedHTTP2(tlsConn))
+
+	request, err := reg.createRequest(tlsConn, decoy)
+	require.Nil(t, err)
+	keystream, err := tlsConn.GetOutKeystream(len(request))
+	require.Nil(t, err)
+
+	// the preface and frames of a Chrome connection, ending with the request HEADERS
+	require.True(t, bytes.HasPrefix(request, []byte(http2.ClientPreface)))
+	framer := http2.NewFramer(nil, bytes.NewReader(request[len(http2.ClientPreface):]))
+	frame, err := framer.ReadFrame()
+	require.Nil(t, err)
+	require.IsType(t, &http2.SettingsFrame{}, frame)
+	frame, err = framer.ReadFrame()
+	require.Nil(t, err)
+	require.IsType(t, &http2.WindowUpdateFrame{}, frame)
+	frame, err = framer.ReadFrame()
+	require.Nil(t, err)
+	headers, ok := frame.(*http2.HeadersFrame)
+	require.True(t, ok)
+	require.True(t, headers.StreamEnded())
+	require.True(t, headers.HeadersEnded())
+	require.False(t, headers.Flags.Has(http2.FlagHeadersPadded))
+
+	fields, err := hpack.NewDecoder(4096, nil).DecodeFull(headers.HeaderBlockFragment())
+	require.Nil(t, err)
+	values := make(map[string]string)
+	var names []string
+	for _, field := range fields {
+		values[field.Name] = field.Value
+		names = append(names, field.Name)
+	}
+	require.Equal(t, []string{":method", ":authority", ":scheme", ":path"}, names[:4])
+	require.Equal(t, "template.test", values[":authority"])
+	require.NotContains(t, values, "host")
+	require.NotContains(t, values, "connection")
+	require.Contains(t, values["user-agent"], "Chrome/62.")
+	require.Equal(t, "x-ignore", names[len(names)-1])
+
+	// the encoded tag ends 4 bytes before the end of the value of the tag header, which
+	// ends the request
+	tagStart := bytes.LastIndexByte(request, '#') + 1
+	tagEnd := len(request) - http2TagTrailerSize
+	require.True(t, strings.HasSuffix(values["x-ignore"], string(request[tagStart:])))
+	tag, err := ReverseDecrypt(request[tagStart:tagEnd], keystream[tagStart:])
+	require.Nil(t, err)
+	encryptedFspSize := fspSize + 16 // fixed size payload and GCM tag
+	representative := tag[len(tag)-encryptedFspSize-len(reg.keys.Representative) : len(tag)-encryptedFspSize]
+	require.Equal(t, reg.keys.Representative, representative)
+
+	// the decoy response is read until the request stream ends
+	_, err = tlsConn.Write(request)
+	require.Nil(t, err)
+	start := time.Now()
+	reg.readHTTP2ResponseAndClose(tlsConn, 10*time.Second)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRenderHTTP2Parrots(t *testing.T) {
+	for _, test := range []struct {
+		parrot        tls.ClientHelloID
+		initialWindow uint32
+		priorities    int
+		stream        uint32
+		pseudoHeaders []string
+	}{
+		{tls.HelloChrome_62, 6291456, 0, 1, []string{":method", ":authority", ":scheme", ":path"}},
+		{tls.HelloFirefox_56, 131072, 5, 13, []string{":method", ":path", ":authority", ":scheme"}},
+		{tls.HelloIOS_11_1, 2097152, 0, 1, []string{":method", ":scheme", ":path", ":authority"}},
+		{tls.HelloRandomized, 6291456, 0, 1, []string{":method", ":authority", ":scheme", ":path"}},
+	} {
+		request, tagOffset := DefaultHTTPRequestTemplate.renderHTTP2("example.com", 10, test.parrot)
+		require.Equal(t, len(request)-http2TagTrailerSize-10, tagOffset, test.parrot.Str())
+
+		// the frames of the parroted browser, ending with the request HEADERS
+		framer := http2.NewFramer(nil, bytes.NewReader(request[len(http2.ClientPreface):]))
+		frame, err := framer.ReadFrame()
+		require.Nil(t, err)
+		settings := frame.(*http2.SettingsFrame)
+		initialWindow, ok := settings.Value(http2.SettingInitialWindowSize)
+		require.True(t, ok)
+		require.Equal(t, test.initialWindow, initialWindow, test.parrot.Str())
+		frame, err = framer.ReadFrame()
+		require.Nil(t, err)
+		require.IsType(t, &http2.WindowUpdateFrame{}, frame)
+		for i := 0; i < test.priorities; i++ {
+			frame, err = framer.ReadFrame()
+			require.Nil(t, err)
+			require.IsType(t, &http2.PriorityFrame{}, frame)
+		}
+		frame, err = framer.ReadFrame()
+		require.Nil(t, err)
+		headers := frame.(*http2.HeadersFrame)
+		require.Equal(t, test.stream, headers.StreamID, test.parrot.Str())
+		require.False(t, headers.Flags.Has(http2.FlagHeadersPadded))
+
+		fields, err := hpack.NewDecoder(4096, nil).DecodeFull(headers.HeaderBlockFragment())
+		require.Nil(t, err)
+		var names []string
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+		require.Equal(t, test.pseudoHeaders, names[:4], test.parrot.Str())
+		_, err = framer.ReadFrame()
+		require.Equal(t, io.EOF, err)
+	}
+}
+
+func TestCreateRequestTooLarge(t *testing.T) {
+	decoy := pb.InitTLSDecoySpec("127.0.0.1", "large.test")
+	// the size is checked before the TLS connection is used
+	tlsConn := tls.UClient(nil, &tls.Config{}, tls.HelloGolang)
+
+	session := makeTestSession(t, strings.Repeat("a", 20000)+":443")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	_, err = reg.createRequest(tlsConn, decoy)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "exceeds the 16384 bytes of a TLS record")
+	require.Contains(t, err.Error(), "covert address of 20004 bytes is too large")
+
+	session = makeTestSession(t, strings.Repeat("a", 70000)+":443")
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	_, err = reg.createRequest(tlsConn, decoy)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "Variable-Size Payload")
+	require.Contains(t, err.Error(), "covert address of 70004 bytes is too large")
+}
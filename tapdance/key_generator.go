@@ -0,0 +1,21 @@
+package tapdance
+
+// KeyGenerator - Generates the client key of a Conjure session for the station public
+// key: the secret shared with the station, from which all the session keys are
+// derived, and the representative of the client public key sent to the station in
+// the registration tag. Alternative implementations allow deterministic keys in
+// tests, or other curves and encodings as the protocol evolves, provided the station
+// supports them.
+type KeyGenerator interface {
+	GenerateKey(stationPubkey [32]byte) (sharedSecret, representative []byte, err error)
+}
+
+// ElligatorKeyGenerator - The default KeyGenerator: an X25519 key exchange with the
+// client public key encoded with Elligator, so that its representative is
+// indistinguishable from random bytes.
+type ElligatorKeyGenerator struct{}
+
+// GenerateKey - Generate a random client key for stationPubkey
+func (ElligatorKeyGenerator) GenerateKey(stationPubkey [32]byte) ([]byte, []byte, error) {
+	return generateEligatorTransformedKey(stationPubkey[:])
+}
@@ -0,0 +1,62 @@
+package tapdance
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fixedKeyGenerator generates the same client key for every session
+type fixedKeyGenerator struct {
+	calls int
+}
+
+func (g *fixedKeyGenerator) GenerateKey(stationPubkey [32]byte) ([]byte, []byte, error) {
+	g.calls++
+	return bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32), nil
+}
+
+func TestKeyGenerator(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	// the default generator gives random keys
+	first, err := generateSharedKeys([32]byte{0}, nil, DefaultKeyLengths)
+	require.Nil(t, err)
+	second, err := generateSharedKeys([32]byte{0}, ElligatorKeyGenerator{}, DefaultKeyLengths)
+	require.Nil(t, err)
+	require.Len(t, second.SharedSecret, 32)
+	require.NotEqual(t, first.Representative, second.Representative)
+
+	// a deterministic generator gives the same session keys every time
+	keyGen := &fixedKeyGenerator{}
+	d := Dialer{DarkDecoy: true, KeyGenerator: keyGen}
+	first = nil
+	for i := 0; i < 2; i++ {
+		session, err := d.makeConjureSession("1.2.3.4:443")
+		require.Nil(t, err)
+		require.Equal(t, bytes.Repeat([]byte{2}, 32), session.Keys.Representative)
+		if first != nil {
+			require.Equal(t, first, session.Keys)
+		}
+		first = session.Keys
+	}
+	require.Equal(t, 2, keyGen.calls)
+
+	session, err := NewConjureSession("1.2.3.4:443", WithKeyGenerator(keyGen))
+	require.Nil(t, err)
+	require.Equal(t, first.ConjureSeed, session.Keys.ConjureSeed)
+	require.Equal(t, 3, keyGen.calls)
+
+	_, err = NewConjureSession("1.2.3.4:443", WithKeyGenerator(nil))
+	require.NotNil(t, err)
+	_, err = generateSharedKeys([32]byte{0}, failingKeyGenerator{}, DefaultKeyLengths)
+	require.EqualError(t, err, "no key")
+}
+
+type failingKeyGenerator struct{}
+
+func (failingKeyGenerator) GenerateKey(stationPubkey [32]byte) ([]byte, []byte, error) {
+	return nil, nil, errors.New("no key")
+}
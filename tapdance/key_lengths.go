@@ -0,0 +1,83 @@
+package tapdance
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"gitlab.com/yawning/obfs4.git/common/ntor"
+)
+
+// KeyLengths - Lengths in bytes of the AES-GCM keys and IVs of the registration
+// payloads, read in this order from the HKDF of the shared secret, before the other
+// session keys. The station reads the same lengths, so moving to AES-256 keys takes
+// updating its configuration and the key lengths of the ClientConf together.
+type KeyLengths struct {
+	FspKey, FspIv, VspKey, VspIv int
+}
+
+// DefaultKeyLengths - AES-128 keys and 96-bit IVs, used unless the ClientConf sets
+// other lengths
+var DefaultKeyLengths = KeyLengths{FspKey: 16, FspIv: 12, VspKey: 16, VspIv: 12}
+
+const (
+	// newMasterSecretLen and conjureSeedLen - Lengths of the session keys following
+	// the payload keys in the HKDF output, fixed by TLS and phantom selection
+	newMasterSecretLen = 48
+	conjureSeedLen     = 16
+
+	// obfs4KeysLen - HKDF output read last by generateObfs4Keys
+	obfs4KeysLen = ntor.PrivateKeyLength + ntor.NodeIDLength
+
+	// hkdfMaxOutput - Most bytes an HKDF with SHA-256 can output
+	hkdfMaxOutput = 255 * sha256.Size
+)
+
+// keyLengthsFromConf - The key lengths set by conf, DefaultKeyLengths for those unset
+func keyLengthsFromConf(conf *pb.KeyLengths) KeyLengths {
+	lengths := DefaultKeyLengths
+	for _, field := range []struct {
+		length *int
+		conf   uint32
+	}{
+		{&lengths.FspKey, conf.GetFspKey()},
+		{&lengths.FspIv, conf.GetFspIv()},
+		{&lengths.VspKey, conf.GetVspKey()},
+		{&lengths.VspIv, conf.GetVspIv()},
+	} {
+		if field.conf != 0 {
+			*field.length = int(field.conf)
+		}
+	}
+	return lengths
+}
+
+// hkdfOutputLen - Number of bytes read from the HKDF to derive the session keys
+func (l KeyLengths) hkdfOutputLen() int {
+	return l.FspKey + l.FspIv + l.VspKey + l.VspIv + newMasterSecretLen + conjureSeedLen + obfs4KeysLen
+}
+
+// validate - Check the keys are AES keys, the IVs are not empty, and the HKDF can
+// output all the session keys
+func (l KeyLengths) validate() error {
+	for _, key := range []struct {
+		name   string
+		length int
+	}{{"FspKey", l.FspKey}, {"VspKey", l.VspKey}} {
+		if key.length != 16 && key.length != 24 && key.length != 32 {
+			return fmt.Errorf("%v length %v is not that of an AES key: 16, 24 or 32", key.name, key.length)
+		}
+	}
+	for _, iv := range []struct {
+		name   string
+		length int
+	}{{"FspIv", l.FspIv}, {"VspIv", l.VspIv}} {
+		if iv.length <= 0 {
+			return fmt.Errorf("%v length %v must be positive", iv.name, iv.length)
+		}
+	}
+	if total := l.hkdfOutputLen(); total > hkdfMaxOutput {
+		return fmt.Errorf("session keys of %v bytes exceed the %v bytes HKDF can output", total, hkdfMaxOutput)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package tapdance
+
+import (
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyLengths(t *testing.T) {
+	AssetsSetDir("./assets")
+
+	require.Equal(t, DefaultKeyLengths, keyLengthsFromConf(nil))
+	aes256 := keyLengthsFromConf(&pb.KeyLengths{FspKey: proto.Uint32(32), VspKey: proto.Uint32(32)})
+	require.Equal(t, KeyLengths{FspKey: 32, FspIv: 12, VspKey: 32, VspIv: 12}, aes256)
+	require.Nil(t, aes256.validate())
+
+	for _, invalid := range []KeyLengths{
+		{FspKey: 20, FspIv: 12, VspKey: 16, VspIv: 12},
+		{FspKey: 16, FspIv: 12, VspKey: 64, VspIv: 12},
+		{FspKey: 16, FspIv: 0, VspKey: 16, VspIv: 12},
+		{FspKey: 16, FspIv: 12, VspKey: 16, VspIv: hkdfMaxOutput},
+	} {
+		_, err := generateSharedKeys([32]byte{0}, nil, invalid)
+		require.NotNil(t, err, "%+v", invalid)
+	}
+
+	// the keys are read from the same HKDF output, only longer
+	keyGen := &fixedKeyGenerator{}
+	keys, err := generateSharedKeys([32]byte{0}, keyGen, DefaultKeyLengths)
+	require.Nil(t, err)
+	longKeys, err := generateSharedKeys([32]byte{0}, keyGen, aes256)
+	require.Nil(t, err)
+	require.Len(t, longKeys.FspKey, 32)
+	require.Len(t, longKeys.VspKey, 32)
+	require.Equal(t, keys.FspKey, longKeys.FspKey[:16])
+	require.Len(t, longKeys.ConjureSeed, conjureSeedLen)
+	require.Nil(t, longKeys.validate(pb.TransportType_Min, aes256))
+	require.NotNil(t, longKeys.validate(pb.TransportType_Min, DefaultKeyLengths))
+
+	// the payloads are encrypted with AES-256 keys
+	ciphertext, err := aesGcmEncrypt([]byte("payload"), longKeys.VspKey, longKeys.VspIv)
+	require.Nil(t, err)
+	plaintext, err := aesGcmDecrypt(ciphertext, longKeys.VspKey, longKeys.VspIv)
+	require.Nil(t, err)
+	require.Equal(t, []byte("payload"), plaintext)
+
+	// sessions use the lengths of the ClientConf
+	Assets().config.KeyLengths = &pb.KeyLengths{FspKey: proto.Uint32(32), VspKey: proto.Uint32(32)}
+	defer func() { Assets().config.KeyLengths = nil }()
+	require.Equal(t, aes256, Assets().GetKeyLengths())
+	session := makeTestSession(t, "1.2.3.4:1234")
+	require.Equal(t, aes256, session.keyLengths)
+	require.Len(t, session.Keys.FspKey, 32)
+	require.Len(t, session.Keys.VspKey, 32)
+}
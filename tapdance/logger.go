@@ -35,3 +35,14 @@ func Logger() *logrus.Logger {
 	})
 	return logrusLogger
 }
+
+// LeveledLogger is the subset of logrus-compatible methods used by Conjure
+// sessions. Both *logrus.Logger and *logrus.Entry satisfy it, so callers can
+// route a Dialer's logs into their own logging system.
+type LeveledLogger interface {
+	Tracef(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
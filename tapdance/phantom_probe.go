@@ -0,0 +1,150 @@
+package tapdance
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// phantomProbeWorkers - phantoms dialed in parallel by ProbePhantoms
+const phantomProbeWorkers = 20
+
+// phantomProbeTimeout - time allowed to each phantom for the TCP handshake
+const phantomProbeTimeout = 5 * time.Second
+
+// PhantomProbeResult - Outcome of the ProbePhantoms connections to the phantoms of one
+// subnet of the ClientConf.
+type PhantomProbeResult struct {
+	// Subnet is the phantom subnet, "unknown" for phantoms outside the ClientConf
+	// subnets
+	Subnet string
+
+	// Attempts is the number of phantoms of the subnet dialed
+	Attempts int
+
+	// Connected phantoms accepted the TCP connection, in RTT on average
+	Connected int
+	RTT       time.Duration
+
+	// TimedOut phantoms did not answer within the timeout
+	TimedOut int
+
+	// Failed phantoms returned an immediate error, the last one being LastErr
+	Failed  int
+	LastErr error
+}
+
+// ProbePhantoms - Select the v4 and v6 phantoms of each seed from the assets, like
+// sessions do, TCP-connect to them on port 443 and report the outcomes by subnet, in
+// the order of the ClientConf subnets, leaving out subnets no phantom was selected in.
+// Unlike TestDecoys, this validates the phantom dialing path.
+//
+// Phantoms only answer once registered: without a registration, a phantom normally
+// times out. An immediate failure (network unreachable, connection refused or reset)
+// shows that the path to the subnet is blocked on this network, and a connection
+// shows that something answers for unused addresses of the subnet.
+func ProbePhantoms(ctx context.Context, seeds [][]byte) []PhantomProbeResult {
+	var d net.Dialer
+	return probePhantoms(ctx, seeds, AssetsPhantomSelector{}, Assets().GetPhantomSubnets(), d.DialContext, phantomProbeTimeout)
+}
+
+func probePhantoms(ctx context.Context, seeds [][]byte, selector PhantomSelector, subnetsList *pb.PhantomSubnetsList,
+	dialer dialFunc, timeout time.Duration) []PhantomProbeResult {
+
+	var phantoms []net.IP
+	for _, seed := range seeds {
+		for _, v6 := range []bool{false, true} {
+			phantom, err := selector.Select(seed, v6)
+			if err == nil && phantom != nil {
+				phantoms = append(phantoms, *phantom)
+			}
+		}
+	}
+
+	// results by subnet, in the order of the ClientConf
+	var results []PhantomProbeResult
+	var subnets []*net.IPNet
+	for _, weighted := range subnetsList.GetWeightedSubnets() {
+		for _, subnet := range weighted.GetSubnets() {
+			_, ipNet, err := net.ParseCIDR(subnet)
+			if err != nil {
+				continue
+			}
+			subnets = append(subnets, ipNet)
+			results = append(results, PhantomProbeResult{Subnet: subnet})
+		}
+	}
+	results = append(results, PhantomProbeResult{Subnet: "unknown"})
+	subnetIndex := func(phantom net.IP) int {
+		for i, subnet := range subnets {
+			if subnet.Contains(phantom) {
+				return i
+			}
+		}
+		return len(subnets)
+	}
+
+	var m sync.Mutex
+	totalRTT := make([]time.Duration, len(results))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < phantomProbeWorkers && w < len(phantoms); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				rtt, err := probePhantom(ctx, phantoms[i], dialer, timeout)
+
+				m.Lock()
+				result := &results[subnetIndex(phantoms[i])]
+				result.Attempts++
+				var netErr net.Error
+				switch {
+				case err == nil:
+					result.Connected++
+					totalRTT[subnetIndex(phantoms[i])] += rtt
+				case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+					result.TimedOut++
+				default:
+					result.Failed++
+					result.LastErr = err
+				}
+				m.Unlock()
+			}
+		}()
+	}
+	for i := range phantoms {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	probed := results[:0]
+	for i, result := range results {
+		if result.Attempts == 0 {
+			continue
+		}
+		if result.Connected > 0 {
+			result.RTT = totalRTT[i] / time.Duration(result.Connected)
+		}
+		probed = append(probed, result)
+	}
+	return probed
+}
+
+func probePhantom(ctx context.Context, phantom net.IP, dialer dialFunc, timeout time.Duration) (time.Duration, error) {
+	childCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialer(childCtx, "tcp", net.JoinHostPort(phantom.String(), "443"))
+	if err != nil {
+		return 0, err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}
@@ -0,0 +1,56 @@
+package tapdance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbePhantoms(t *testing.T) {
+	subnets := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{
+			{Weight: proto.Uint32(1), Subnets: []string{"192.0.2.0/24", "2001:db8::/64"}},
+		},
+	}
+	seeds := make([][]byte, 5)
+	for i := range seeds {
+		seeds[i] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+
+	// v4 phantoms answer, the v6 network is unreachable
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "[") {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ENETUNREACH}
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	results := probePhantoms(context.Background(), seeds, PhantomSelectorV1{Subnets: subnets}, subnets, dialer, time.Second)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, "192.0.2.0/24", results[0].Subnet)
+	require.Equal(t, 5, results[0].Attempts)
+	require.Equal(t, 5, results[0].Connected)
+	require.Equal(t, "2001:db8::/64", results[1].Subnet)
+	require.Equal(t, 5, results[1].Failed)
+	require.ErrorIs(t, results[1].LastErr, syscall.ENETUNREACH)
+
+	// phantoms that never answer time out
+	dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, fmt.Errorf("dial %v: %w", address, ctx.Err())
+	}
+	results = probePhantoms(context.Background(), seeds[:1], PhantomSelectorV1{Subnets: subnets}, subnets, dialer, 10*time.Millisecond)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, 1, results[0].TimedOut)
+	require.Equal(t, 1, results[1].TimedOut)
+}
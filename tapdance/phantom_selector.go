@@ -0,0 +1,130 @@
+package tapdance
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	ps "github.com/dimuls/gotapdance/tapdance/phantoms"
+	"golang.org/x/crypto/hkdf"
+)
+
+// PhantomSelector - Maps a seed to a phantom address of the requested address family.
+// Implementations must select the same phantom as the station does for the seed.
+type PhantomSelector interface {
+	Select(seed []byte, v6 bool) (*net.IP, error)
+}
+
+// phantomSelectorGenerations - First ClientConf generation using each phantom selector
+// version, indexed by version. Stations upgrade the selection algorithm together with
+// the ClientConf they distribute, so the generation tells which version they expect.
+// Stations select with version 1 for every generation they have distributed so far;
+// version 2 gets the first generation stations distribute with it once they do.
+var phantomSelectorGenerations = []uint32{
+	1: 0,
+}
+
+// PhantomSelectorVersion - Get the phantom selector version used with the given
+// ClientConf generation. The maximum generation marks decoys overridden locally (see
+// OverrideDecoys) rather than a ClientConf distributed by stations, and gets version 1.
+func PhantomSelectorVersion(generation uint32) uint {
+	version := uint(1)
+	if generation == ^uint32(0) {
+		return version
+	}
+	for v := 1; v < len(phantomSelectorGenerations); v++ {
+		if generation >= phantomSelectorGenerations[v] {
+			version = uint(v)
+		}
+	}
+	return version
+}
+
+// NewPhantomSelector - Get the selector implementing the given version of phantom
+// selection over subnets.
+func NewPhantomSelector(version uint, subnets *pb.PhantomSubnetsList) (PhantomSelector, error) {
+	return newPhantomSelector(version, subnets, nil)
+}
+
+func newPhantomSelector(version uint, subnets *pb.PhantomSubnetsList, tracer ps.Tracer) (PhantomSelector, error) {
+	switch version {
+	case 1:
+		return PhantomSelectorV1{Subnets: subnets, Tracer: tracer}, nil
+	case 2:
+		return PhantomSelectorV2{Subnets: subnets, Tracer: tracer}, nil
+	default:
+		return nil, fmt.Errorf("%w %v", ErrUnsupportedSelectorVersion, version)
+	}
+}
+
+// ErrUnsupportedSelectorVersion - The phantom selector version, e.g. of the ClientConf
+// generation, is not implemented by this client: update it to select the phantoms the
+// station expects.
+var ErrUnsupportedSelectorVersion = errors.New("unsupported phantom selector version")
+
+// AssetsPhantomSelector - Default PhantomSelector: selects from the phantom subnets of
+// the current ClientConf, with the selector version matching its generation.
+type AssetsPhantomSelector struct {
+	Tracer ps.Tracer // receives trace logs of the selection, nil to disable them
+}
+
+// Select - select one phantom IP address based on shared secret
+func (s AssetsPhantomSelector) Select(seed []byte, v6 bool) (*net.IP, error) {
+	selector, err := newPhantomSelector(Assets().GetPhantomSelectorVersion(), Assets().GetPhantomSubnets(), s.Tracer)
+	if err != nil {
+		return nil, err
+	}
+	return selector.Select(seed, v6)
+}
+
+// PhantomSelectorV1 - Weighted selection from the subnets, keyed directly by the seed.
+type PhantomSelectorV1 struct {
+	Subnets *pb.PhantomSubnetsList
+	Tracer  ps.Tracer // receives trace logs of the selection, nil to disable them
+}
+
+// Select - select one phantom IP address based on shared secret
+func (s PhantomSelectorV1) Select(seed []byte, v6 bool) (*net.IP, error) {
+	return ps.SelectPhantomTraced(seed, s.Subnets, familyFilter(v6), true, s.Tracer)
+}
+
+// PhantomSelectorV2 - Weighted selection from the subnets, keyed by an HKDF expansion
+// of the seed rather than the seed itself.
+type PhantomSelectorV2 struct {
+	Subnets *pb.PhantomSubnetsList
+	Tracer  ps.Tracer // receives trace logs of the selection, nil to disable them
+}
+
+// Select - select one phantom IP address based on shared secret
+func (s PhantomSelectorV2) Select(seed []byte, v6 bool) (*net.IP, error) {
+	selectionSeed := make([]byte, len(seed))
+	_, err := io.ReadFull(hkdf.New(sha256.New, seed, nil, []byte("phantom-selection-v2")), selectionSeed)
+	if err != nil {
+		return nil, err
+	}
+	return ps.SelectPhantomTraced(selectionSeed, s.Subnets, familyFilter(v6), true, s.Tracer)
+}
+
+// excludingSelector - Re-rolls the phantoms of selector in the excluded subnets
+type excludingSelector struct {
+	selector PhantomSelector
+	excluded []*net.IPNet
+	tracer   ps.Tracer
+}
+
+// Select - select one phantom IP address based on shared secret
+func (s excludingSelector) Select(seed []byte, v6 bool) (*net.IP, error) {
+	return ps.SelectExcluding(seed, s.excluded, s.tracer, func(seed []byte) (*net.IP, error) {
+		return s.selector.Select(seed, v6)
+	})
+}
+
+func familyFilter(v6 bool) ps.SubnetFilter {
+	if v6 {
+		return ps.V6Only
+	}
+	return ps.V4Only
+}
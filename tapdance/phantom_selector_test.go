@@ -0,0 +1,112 @@
+package tapdance
+
+import (
+	"io/ioutil"
+	"net"
+	"path"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/dimuls/gotapdance/protobuf"
+	ps "github.com/dimuls/gotapdance/tapdance/phantoms"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSelectorGenerations - Assign the first generation of phantom selector version 2
+// for the duration of the test
+func stubSelectorGenerations(t *testing.T, v2 uint32) {
+	original := phantomSelectorGenerations
+	phantomSelectorGenerations = []uint32{1: 0, 2: v2}
+	t.Cleanup(func() { phantomSelectorGenerations = original })
+}
+
+func TestPhantomSelectorVersion(t *testing.T) {
+	// generations distributed by stations
+	require.Equal(t, uint(1), PhantomSelectorVersion(0))
+	require.Equal(t, uint(1), PhantomSelectorVersion(1153))
+	require.Equal(t, uint(1), PhantomSelectorVersion(^uint32(0)))
+
+	stubSelectorGenerations(t, 2000)
+	require.Equal(t, uint(1), PhantomSelectorVersion(1999))
+	require.Equal(t, uint(2), PhantomSelectorVersion(2000))
+	// local decoy overrides are not a generation of version 2
+	require.Equal(t, uint(1), PhantomSelectorVersion(^uint32(0)))
+
+	_, err := NewPhantomSelector(3, ps.GetDefaultPhantomSubnets())
+	require.ErrorIs(t, err, ErrUnsupportedSelectorVersion)
+}
+
+func TestSelectSessionPhantomsExcluded(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.PhantomSelector = PhantomSelectorV1{Subnets: &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Weight: proto.Uint32(1), Subnets: []string{"10.0.0.0/8", "2001:db8::/32"}}},
+	}}
+	phantom4, _, err := session.SelectSessionPhantoms()
+	require.Nil(t, err)
+
+	session.ExcludedPhantoms = []*net.IPNet{{IP: *phantom4, Mask: net.CIDRMask(32, 32)}}
+	rerolled, _, err := session.SelectSessionPhantoms()
+	require.Nil(t, err)
+	require.False(t, rerolled.Equal(*phantom4))
+	require.True(t, rerolled.To4() != nil)
+}
+
+func TestSelectPhantomsErrors(t *testing.T) {
+	selector := PhantomSelectorV1{Subnets: &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Weight: proto.Uint32(1), Subnets: []string{"10.0.0.0/8"}}},
+	}}
+	seed := make([]byte, 16)
+	seed[0] = 1
+
+	_, _, err := selectPhantoms(selector, seed, v4)
+	require.Nil(t, err)
+	_, _, err = selectPhantoms(selector, seed, both)
+	require.ErrorIs(t, err, ps.ErrNoSubnets)
+	require.Contains(t, err.Error(), "failed to select v6 phantom")
+}
+
+func TestPhantomSelectorForGeneration(t *testing.T) {
+	oldpath := Assets().path
+	defer AssetsSetDir(oldpath)
+	stubSelectorGenerations(t, 2000)
+
+	seed := []byte{
+		0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7,
+		0x8, 0x9, 0xA, 0xB, 0xC, 0xD, 0xE, 0xF,
+	}
+	subnets := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{
+			{Weight: proto.Uint32(1), Subnets: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		},
+	}
+	v1, err := PhantomSelectorV1{Subnets: subnets}.Select(seed, false)
+	require.Nil(t, err)
+	v2, err := PhantomSelectorV2{Subnets: subnets}.Select(seed, false)
+	require.Nil(t, err)
+	require.NotEqual(t, v1.String(), v2.String())
+
+	for _, tc := range []struct {
+		generation uint32
+		expected   string
+	}{
+		{1153, v1.String()},
+		{2000, v2.String()},
+	} {
+		dir := t.TempDir()
+		conf := &pb.ClientConf{Generation: proto.Uint32(tc.generation), PhantomSubnetsList: subnets}
+		buf, err := proto.Marshal(conf)
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(path.Join(dir, "ClientConf"), buf, 0644))
+		AssetsSetDir(dir)
+
+		phantom, err := AssetsPhantomSelector{}.Select(seed, false)
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, phantom.String(), "generation %v", tc.generation)
+
+		// decoy overrides bump the generation but keep the selector version
+		Assets().OverrideDecoys(nil, ^uint32(0))
+		phantom, err = AssetsPhantomSelector{}.Select(seed, false)
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, phantom.String(), "generation %v overridden", tc.generation)
+	}
+}
@@ -2,6 +2,8 @@ package phantoms
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -13,6 +15,36 @@ import (
 	pb "github.com/dimuls/gotapdance/protobuf"
 )
 
+// ErrNoSubnets - Returned by SelectPhantom when the subnets list has no subnet left
+//		after the SubnetFilter, e.g. no v6 subnet in the selected group for V6Only.
+var ErrNoSubnets = errors.New("no phantom subnet of the address family")
+
+// ErrReservedAddress - Returned by SelectPhantom when the seed maps to the reserved
+//		address index that no subnet covers. Another seed selects a valid phantom.
+var ErrReservedAddress = errors.New("seed selected the reserved phantom address index")
+
+// ErrAllExcluded - Returned when the phantom selected by the seed and those of the
+//		MaxExclusionRerolls re-rolled seeds are all excluded.
+var ErrAllExcluded = errors.New("every phantom selected is excluded")
+
+// MaxExclusionRerolls - seeds derived from the original seed tried in turn when the
+//		phantom selected is excluded
+const MaxExclusionRerolls = 16
+
+// Tracer - receives trace logs of a phantom selection (the chosen subnet group, the
+//		seed-derived index, the subnet it falls in and the resulting address), for
+//		debugging selection mismatches with the station, e.g. the logger of the session
+//		selecting. Nil disables tracing.
+type Tracer interface {
+	Tracef(format string, args ...interface{})
+}
+
+func tracef(tracer Tracer, format string, args ...interface{}) {
+	if tracer != nil {
+		tracer.Tracef(format, args...)
+	}
+}
+
 // getSubnets - return EITHER all subnet strings as one composite array if we are
 //		selecting unweighted, or return the array associated with the (seed) selected
 //		array of subnet strings based on the associated weights
@@ -167,7 +199,7 @@ func SelectAddrFromSubnet(seed []byte, net1 *net.IPNet) (net.IP, error) {
 	return net.IP(ipBigInt.Bytes()), nil
 }
 
-func selectIPAddr(seed []byte, subnets []*net.IPNet) (*net.IP, error) {
+func selectIPAddr(seed []byte, subnets []*net.IPNet, tracer Tracer) (*net.IP, error) {
 
 	addresses_total := big.NewInt(0)
 
@@ -201,7 +233,7 @@ func selectIPAddr(seed []byte, subnets []*net.IPNet) (*net.IP, error) {
 	}
 
 	if addresses_total.Cmp(big.NewInt(0)) <= 0 {
-		return nil, fmt.Errorf("No valid addresses specified")
+		return nil, ErrNoSubnets
 	}
 
 	id := &big.Int{}
@@ -218,18 +250,33 @@ func selectIPAddr(seed []byte, subnets []*net.IPNet) (*net.IP, error) {
 			if err != nil {
 				return nil, fmt.Errorf("Failed to chose IP address: %v", err)
 			}
+			tracef(tracer, "phantom selection: index %v of %v addresses in %d subnets -> subnet %v -> %v",
+				id, addresses_total, len(subnets), _idNet.net, result)
 		}
 	}
 	if result == nil {
-		return nil, errors.New("let's rewrite the phantom address selector")
+		tracef(tracer, "phantom selection: index %v of %v addresses in %d subnets matched no subnet", id, addresses_total, len(subnets))
+		return nil, ErrReservedAddress
 	}
 	return &result, nil
 }
 
 // SelectPhantom - select one phantom IP address based on shared secret
 func SelectPhantom(seed []byte, subnetsList *pb.PhantomSubnetsList, transform SubnetFilter, weighted bool) (*net.IP, error) {
+	return SelectPhantomTraced(seed, subnetsList, transform, weighted, nil)
+}
 
-	s, err := parseSubnets(getSubnets(subnetsList, seed, weighted))
+// SelectPhantomTraced - SelectPhantom, logging how the phantom is selected to tracer
+func SelectPhantomTraced(seed []byte, subnetsList *pb.PhantomSubnetsList, transform SubnetFilter, weighted bool, tracer Tracer) (*net.IP, error) {
+
+	subnets := getSubnets(subnetsList, seed, weighted)
+	if weighted {
+		tracef(tracer, "phantom selection: weighted choice of %d groups picked %v", len(subnetsList.GetWeightedSubnets()), subnets)
+	}
+	if len(subnets) == 0 {
+		return nil, ErrNoSubnets
+	}
+	s, err := parseSubnets(subnets)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse subnets: %v", err)
 	}
@@ -240,8 +287,74 @@ func SelectPhantom(seed []byte, subnetsList *pb.PhantomSubnetsList, transform Su
 			return nil, err
 		}
 	}
+	if len(s) == 0 {
+		return nil, ErrNoSubnets
+	}
+
+	return selectIPAddr(seed, s, tracer)
+}
+
+// ParseExclusions - parse excluded phantoms, given as addresses or CIDR subnets
+func ParseExclusions(exclusions []string) ([]*net.IPNet, error) {
+	var excluded []*net.IPNet
+	for _, exclusion := range exclusions {
+		if ip := net.ParseIP(exclusion); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			excluded = append(excluded, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(exclusion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded phantom %q: not an address or CIDR subnet", exclusion)
+		}
+		excluded = append(excluded, subnet)
+	}
+	return excluded, nil
+}
+
+// SelectExcluding - select a phantom with selectOne, and while it is in an excluded
+//		subnet, select again with seeds derived from the original seed and the re-roll
+//		index, up to MaxExclusionRerolls times. The station selects the same phantom
+//		only if it excludes the same subnets. Re-rolls are traced to tracer, if not nil.
+func SelectExcluding(seed []byte, excluded []*net.IPNet, tracer Tracer, selectOne func(seed []byte) (*net.IP, error)) (*net.IP, error) {
+	rerollSeed := seed
+	for i := 0; i <= MaxExclusionRerolls; i++ {
+		if i > 0 {
+			rerollSeed = exclusionSeed(seed, i)
+		}
+		addr, err := selectOne(rerollSeed)
+		if err != nil {
+			return nil, err
+		}
+		if !isExcluded(*addr, excluded) {
+			return addr, nil
+		}
+		tracef(tracer, "phantom selection: %v is excluded, re-rolling", addr)
+	}
+	return nil, ErrAllExcluded
+}
 
-	return selectIPAddr(seed, s)
+func isExcluded(addr net.IP, excluded []*net.IPNet) bool {
+	for _, subnet := range excluded {
+		if subnet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// exclusionSeed - derive the seed of the i-th re-roll past excluded phantoms
+func exclusionSeed(seed []byte, i int) []byte {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(fmt.Sprintf("phantomexclusion%d", i)))
+	sum := mac.Sum(nil)
+	if len(seed) < len(sum) {
+		return sum[:len(seed)]
+	}
+	return sum
 }
 
 // SelectPhantomUnweighted - select one phantom IP address based on shared secret
@@ -2,8 +2,10 @@ package phantoms
 
 import (
 	"encoding/hex"
+	"fmt"
 	"math/rand"
 	"net"
+	"strings"
 	"testing"
 
 	pb "github.com/dimuls/gotapdance/protobuf"
@@ -155,3 +157,85 @@ func TestPhantomsV6OnlyFilter(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, 2, len(testNetsParsed))
 }
+
+type recordingTracer struct {
+	lines []string
+}
+
+func (r *recordingTracer) Tracef(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSelectPhantomTrace(t *testing.T) {
+	tracer := &recordingTracer{}
+
+	seed, err := hex.DecodeString("5a87133b68da3468988a21659a12ed2ece07345c8c1a5b08459ffdea4218d12f")
+	require.Nil(t, err)
+	subnets := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Subnets: []string{"192.122.190.0/24", "10.0.0.0/16"}}},
+	}
+
+	addr, err := SelectPhantomTraced(seed, subnets, V4Only, false, tracer)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(tracer.lines))
+	require.True(t, strings.HasSuffix(tracer.lines[0], "-> "+addr.String()), tracer.lines[0])
+	require.Contains(t, tracer.lines[0], "in 2 subnets")
+}
+
+func TestSelectPhantomErrors(t *testing.T) {
+	seed, err := hex.DecodeString("5a87133b68da3468988a21659a12ed2ece07345c8c1a5b08459ffdea4218d12f")
+	require.Nil(t, err)
+	v4Subnets := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Subnets: []string{"192.122.190.0/24"}}},
+	}
+
+	_, err = SelectPhantom(seed, v4Subnets, V6Only, false)
+	require.ErrorIs(t, err, ErrNoSubnets)
+	_, err = SelectPhantom(seed, &pb.PhantomSubnetsList{}, V4Only, true)
+	require.ErrorIs(t, err, ErrNoSubnets)
+
+	// the all zero seed maps to index 0, covered by no subnet
+	_, err = SelectPhantom(make([]byte, 32), v4Subnets, V4Only, false)
+	require.ErrorIs(t, err, ErrReservedAddress)
+}
+
+func TestSelectPhantomExclusions(t *testing.T) {
+	seed, err := hex.DecodeString("5a87133b68da3468988a21659a12ed2ece07345c8c1a5b08459ffdea4218d12f")
+	require.Nil(t, err)
+	subnets := &pb.PhantomSubnetsList{
+		WeightedSubnets: []*pb.PhantomSubnets{{Subnets: []string{"192.122.190.0/24", "10.0.0.0/16"}}},
+	}
+	selectOne := func(seed []byte) (*net.IP, error) {
+		return SelectPhantom(seed, subnets, V4Only, false)
+	}
+	selected, err := selectOne(seed)
+	require.Nil(t, err)
+
+	// nothing excluded: the phantom is the one selected
+	same, err := SelectExcluding(seed, nil, nil, selectOne)
+	require.Nil(t, err)
+	require.Equal(t, selected.String(), same.String())
+
+	// an excluded phantom is re-rolled, the same way every time
+	exclusions, err := ParseExclusions([]string{selected.String()})
+	require.Nil(t, err)
+	rerolled, err := SelectExcluding(seed, exclusions, nil, selectOne)
+	require.Nil(t, err)
+	require.False(t, rerolled.Equal(*selected))
+	again, err := SelectExcluding(seed, exclusions, nil, selectOne)
+	require.Nil(t, err)
+	require.Equal(t, rerolled.String(), again.String())
+
+	exclusions, err = ParseExclusions([]string{"192.122.190.0/24", "10.0.0.0/16"})
+	require.Nil(t, err)
+	_, err = SelectExcluding(seed, exclusions, nil, selectOne)
+	require.ErrorIs(t, err, ErrAllExcluded)
+
+	_, err = ParseExclusions([]string{"10.0.0.0/33"})
+	require.NotNil(t, err)
+
+	excluded, err := ParseExclusions([]string{"10.1.2.3", "2001:db8::1", "10.0.0.0/8"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"10.1.2.3/32", "2001:db8::1/128", "10.0.0.0/8"},
+		[]string{excluded[0].String(), excluded[1].String(), excluded[2].String()})
+}
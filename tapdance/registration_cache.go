@@ -0,0 +1,147 @@
+package tapdance
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// RegistrationCache - Registrations kept by a Dialer to reconnect to their phantoms
+// (see ConjureReg.Reconnect) on later dials to the same covert address, saving a decoy
+// registration per connection when many short connections go to the same target.
+// A nil *RegistrationCache keeps nothing, which is the Dialer default.
+//
+// Reusing a registration is subject to the protocol constraints:
+//   - The station only accepts phantom connections for a registration during a
+//     validity window after receiving it, set in the station configuration and not
+//     known to the client. MaxAge should stay below it: a registration that expired
+//     earlier costs a failed reconnect before registering again.
+//   - The station matches phantom connections to registrations by client address, so
+//     reconnecting fails once the client address changes, e.g. on a network switch.
+//   - All the connections of a registration go to the same phantom with the same
+//     keys, making them linkable to each other by an observer. Each one is proxied
+//     separately to the covert address.
+type RegistrationCache struct {
+	// MaxAge is how long after being registered a registration is reused
+	MaxAge time.Duration
+
+	// MaxEntries bounds the number of covert addresses registrations are kept for.
+	// Once reached, the least recently used one is dropped. Zero means no bound.
+	MaxEntries int
+
+	m       sync.Mutex
+	entries map[string]*list.Element // of *registrationCacheEntry
+	lru     list.List                // most recently used first
+}
+
+type registrationCacheEntry struct {
+	key string
+	reg *ConjureReg
+}
+
+// NewRegistrationCache - Create a cache reusing registrations for maxAge, for at most
+// maxEntries covert addresses (0 for no bound)
+func NewRegistrationCache(maxAge time.Duration, maxEntries int) *RegistrationCache {
+	return &RegistrationCache{MaxAge: maxAge, MaxEntries: maxEntries}
+}
+
+// registrationCacheKey - Registrations are only reused for the same covert address,
+// transport and protocol
+func registrationCacheKey(transport fmt.Stringer, covertUDP bool, address string) string {
+	return fmt.Sprintf("%v/%v/%v", transport, covertUDP, address)
+}
+
+// store - Keep the registration of a successful connection for key, dropping expired
+// registrations and the least recently used ones over MaxEntries
+func (c *RegistrationCache) store(key string, reg *ConjureReg) {
+	if c == nil || reg == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*registrationCacheEntry).reg = reg
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[key] = c.lru.PushFront(&registrationCacheEntry{key: key, reg: reg})
+	}
+
+	for e := c.lru.Back(); e != nil; {
+		prev := e.Prev()
+		entry := e.Value.(*registrationCacheEntry)
+		if c.expired(entry.reg) || (c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries) {
+			c.remove(e)
+		}
+		e = prev
+	}
+}
+
+// reconnect - Connect with the registration kept for key, if it is still within
+// MaxAge. Returns nil when there is none or reconnecting fails, dropping it.
+func (c *RegistrationCache) reconnect(ctx context.Context, key string) net.Conn {
+	if c == nil {
+		return nil
+	}
+	c.m.Lock()
+	var reg *ConjureReg
+	if e, ok := c.entries[key]; ok {
+		reg = e.Value.(*registrationCacheEntry).reg
+		if c.expired(reg) {
+			c.remove(e)
+			reg = nil
+		} else {
+			c.lru.MoveToFront(e)
+		}
+	}
+	c.m.Unlock()
+	if reg == nil {
+		return nil
+	}
+
+	conn, err := reg.Reconnect(ctx)
+	if err != nil {
+		reg.logger().Infof("%v Failed to reuse registration: %v", reg.sessionIDStr, err)
+		c.drop(key, reg)
+		return nil
+	}
+	return conn
+}
+
+// Len - Get the number of covert addresses registrations are kept for, including
+// expired ones not dropped yet
+func (c *RegistrationCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.lru.Len()
+}
+
+// drop - Forget the registration of key, unless it was already replaced
+func (c *RegistrationCache) drop(key string, reg *ConjureReg) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if e, ok := c.entries[key]; ok && e.Value.(*registrationCacheEntry).reg == reg {
+		c.remove(e)
+	}
+}
+
+// expired - Whether reg is past MaxAge, to be called with c.m held
+func (c *RegistrationCache) expired(reg *ConjureReg) bool {
+	return time.Since(reg.startTs) >= c.MaxAge
+}
+
+// remove - Remove the entry of e, to be called with c.m held
+func (c *RegistrationCache) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*registrationCacheEntry).key)
+	c.lru.Remove(e)
+}
@@ -0,0 +1,52 @@
+package tapdance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationCacheLRU(t *testing.T) {
+	c := NewRegistrationCache(time.Minute, 2)
+	reg := func(age time.Duration) *ConjureReg {
+		return &ConjureReg{sessionIDStr: "[test]", startTs: time.Now().Add(-age)}
+	}
+	has := func(key string) bool {
+		c.m.Lock()
+		defer c.m.Unlock()
+		_, ok := c.entries[key]
+		return ok
+	}
+
+	c.store("a", reg(0))
+	c.store("b", reg(0))
+	// storing a again makes b the least recently used
+	c.store("a", reg(0))
+
+	c.store("c", reg(0))
+	require.Equal(t, 2, c.Len())
+	require.True(t, has("a"))
+	require.False(t, has("b"))
+	require.True(t, has("c"))
+
+	// expired registrations are dropped, without reconnecting
+	c.store("old", reg(2*time.Minute))
+	require.False(t, has("old"))
+	c.store("d", reg(30*time.Second))
+	c.MaxAge = 10 * time.Second
+	require.Nil(t, c.reconnect(context.Background(), "d"))
+	require.False(t, has("d"))
+
+	// without bound, every covert address is kept
+	c = NewRegistrationCache(time.Minute, 0)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.store(key, reg(0))
+	}
+	require.Equal(t, 4, c.Len())
+
+	var nilCache *RegistrationCache
+	nilCache.store("a", reg(0))
+	require.Equal(t, 0, nilCache.Len())
+}
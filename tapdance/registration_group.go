@@ -0,0 +1,99 @@
+package tapdance
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// RegistrationGroup - De-duplicates the registrations of concurrent dials to the same
+// covert address, transport and protocol: while one dial registers, the others to the
+// same target wait for it, then connect to the phantom of its registration (see
+// ConjureReg.Reconnect) instead of each sending registrations to the decoys. This
+// flattens the spikes of decoy load when many connections start at once. A nil
+// *RegistrationGroup shares nothing, which is the Dialer default. The zero value is
+// ready to use.
+//
+// Sharing a registration is subject to the protocol constraints of reusing one with
+// RegistrationCache: the dials sharing it must have the same client address, and
+// their connections all go to the same phantom with the same keys, making them
+// linkable to each other by an observer. As waiting dials connect right after the
+// registration, they are within its validity window at the station.
+//
+// When the registering dial fails, the dials waiting for it fail with its error
+// rather than registering in turn, so a failing target does not multiply decoy
+// registrations either. Only when it fails because its own context ended or its
+// session was closed does a waiting dial register instead.
+type RegistrationGroup struct {
+	m       sync.Mutex
+	flights map[string]*registrationFlight
+}
+
+// registrationFlight - A registering dial others wait for, done when it returned
+type registrationFlight struct {
+	done chan struct{}
+	reg  *ConjureReg
+	err  error
+}
+
+// dial - Dial with register, unless a dial for key is already registering: then wait
+// for it and connect with its registration
+func (g *RegistrationGroup) dial(ctx context.Context, key string, register func() (net.Conn, *ConjureReg, error)) (net.Conn, *ConjureReg, error) {
+	if g == nil {
+		return register()
+	}
+
+	for {
+		g.m.Lock()
+		if g.flights == nil {
+			g.flights = make(map[string]*registrationFlight)
+		}
+		flight, ok := g.flights[key]
+		if !ok {
+			flight = &registrationFlight{done: make(chan struct{})}
+			g.flights[key] = flight
+			g.m.Unlock()
+			return g.lead(key, flight, register)
+		}
+		g.m.Unlock()
+
+		select {
+		case <-flight.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if flight.err == nil && flight.reg != nil {
+			flight.reg.logger().Infof("%v Sharing registration with a concurrent dial", flight.reg.sessionIDStr)
+			conn, err := flight.reg.Reconnect(ctx)
+			if err != nil {
+				return nil, nil, &PhantomUnreachableError{Attempts: 1, Err: err}
+			}
+			return conn, flight.reg, nil
+		}
+		if flight.err != nil && !registrationAbandoned(flight.err) {
+			return nil, nil, flight.err
+		}
+	}
+}
+
+// lead - Dial with register for the dials waiting on flight, releasing them when done
+func (g *RegistrationGroup) lead(key string, flight *registrationFlight, register func() (net.Conn, *ConjureReg, error)) (net.Conn, *ConjureReg, error) {
+	defer func() {
+		g.m.Lock()
+		delete(g.flights, key)
+		g.m.Unlock()
+		close(flight.done)
+	}()
+
+	conn, reg, err := register()
+	flight.reg, flight.err = reg, err
+	return conn, reg, err
+}
+
+// registrationAbandoned - Whether a dial failed with err because of its own context
+// or session rather than because of the target
+func registrationAbandoned(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, errSessionClosed)
+}
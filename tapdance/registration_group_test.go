@@ -0,0 +1,143 @@
+package tapdance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationGroup(t *testing.T) {
+	AssetsSetDir("./assets")
+	phantom, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer phantom.Close()
+	go func() {
+		for {
+			conn, err := phantom.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	session := makeTestSession(t, "1.2.3.4:1234")
+	session.V6Support = &V6{include: v4, fixed: true}
+	session.TcpDialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, phantom.Addr().String())
+	}
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+
+	// concurrent dials wait for the registering one and share its registration
+	var g RegistrationGroup
+	var registrations int32
+	release := make(chan struct{})
+	register := func() (net.Conn, *ConjureReg, error) {
+		atomic.AddInt32(&registrations, 1)
+		<-release
+		conn, err := reg.Connect(context.Background())
+		return conn, reg, err
+	}
+	var wg sync.WaitGroup
+	regs := make(chan *ConjureReg, 10)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, shared, err := g.dial(context.Background(), "key", register)
+			if err == nil {
+				conn.Close()
+			}
+			regs <- shared
+			errs <- err
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(regs)
+	close(errs)
+	require.Equal(t, int32(1), atomic.LoadInt32(&registrations))
+	for shared := range regs {
+		require.Equal(t, reg, shared)
+	}
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	// failures are shared as well, unless the registering dial gave up
+	failures := []error{errors.New("decoys unreachable"), context.Canceled}
+	for _, failure := range failures {
+		atomic.StoreInt32(&registrations, 0)
+		release = make(chan struct{})
+		leaderDone := make(chan struct{})
+		go func() {
+			g.dial(context.Background(), "key", func() (net.Conn, *ConjureReg, error) {
+				atomic.AddInt32(&registrations, 1)
+				<-release
+				return nil, nil, failure
+			})
+			close(leaderDone)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		followerDone := make(chan error)
+		go func() {
+			_, _, err := g.dial(context.Background(), "key", func() (net.Conn, *ConjureReg, error) {
+				atomic.AddInt32(&registrations, 1)
+				return nil, nil, errors.New("registered again")
+			})
+			followerDone <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		<-leaderDone
+		err := <-followerDone
+		if failure == context.Canceled {
+			require.EqualError(t, err, "registered again")
+			require.Equal(t, int32(2), atomic.LoadInt32(&registrations))
+		} else {
+			require.Equal(t, failure, err)
+			require.Equal(t, int32(1), atomic.LoadInt32(&registrations))
+		}
+	}
+
+	// waiting dials stop with their own context
+	release = make(chan struct{})
+	defer close(release)
+	go g.dial(context.Background(), "key", func() (net.Conn, *ConjureReg, error) {
+		<-release
+		return nil, nil, errors.New("too late")
+	})
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = g.dial(ctx, "key", register)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// other keys and a nil group do not wait
+	conn, _, err := g.dial(context.Background(), "other key", func() (net.Conn, *ConjureReg, error) {
+		conn, err := reg.Connect(context.Background())
+		return conn, reg, err
+	})
+	require.Nil(t, err)
+	conn.Close()
+	var nilGroup *RegistrationGroup
+	_, _, err = nilGroup.dial(context.Background(), "key", func() (net.Conn, *ConjureReg, error) {
+		return nil, nil, errors.New("not shared")
+	})
+	require.EqualError(t, err, "not shared")
+}
@@ -0,0 +1,118 @@
+package tapdance
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// SessionOption - Option of NewConjureSession, setting a parameter of the session
+type SessionOption func(*ConjureSession) error
+
+// NewConjureSession - Create a Conjure session to the covert address, with keys for
+// the station public key from the environment or assets (see getStationKey). Without
+// options, the session registers with defaultRegWidth decoys for both IP versions and
+// uses the min transport. The session is then used with Register, or with
+// DialConjure and a Registrar.
+func NewConjureSession(covert string, opts ...SessionOption) (*ConjureSession, error) {
+	if err := validateCovertAddress(covert); err != nil {
+		return nil, err
+	}
+	cjSession := newConjureSessionDefaults(covert, pb.TransportType_Min)
+	for _, opt := range opts {
+		if err := opt(cjSession); err != nil {
+			return nil, err
+		}
+	}
+	if err := cjSession.generateKeys(nil, cjSession.keyGen); err != nil {
+		return nil, fmt.Errorf("failed to create Conjure session: %v", err)
+	}
+	return cjSession, nil
+}
+
+// WithWidth - Register through width decoys
+func WithWidth(width uint) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		if width == 0 {
+			return errors.New("registration width must be at least 1")
+		}
+		cjSession.Width = width
+		return nil
+	}
+}
+
+// WithTransport - Connect to the phantom with the transport
+func WithTransport(transport pb.TransportType) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		if _, ok := pb.TransportType_name[int32(transport)]; !ok {
+			return fmt.Errorf("unknown transport %v", transport)
+		}
+		cjSession.Transport = transport
+		return nil
+	}
+}
+
+// WithIPVersions - Register phantoms and decoys of the enabled IP versions only,
+// instead of both with v6 support probed by the registrations (default).
+func WithIPVersions(v4Enabled, v6Enabled bool) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		switch {
+		case v4Enabled && v6Enabled:
+			cjSession.V6Support = &V6{support: true, include: both, fixed: true}
+		case v4Enabled:
+			cjSession.V6Support = &V6{support: false, include: v4, fixed: true}
+		case v6Enabled:
+			cjSession.V6Support = &V6{support: true, include: v6, fixed: true}
+		default:
+			return errors.New("at least one IP version must be enabled")
+		}
+		return nil
+	}
+}
+
+// WithProxyHeader - Ask the station to send a PROXY protocol header to the covert
+func WithProxyHeader(useProxyHeader bool) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		cjSession.UseProxyHeader = useProxyHeader
+		return nil
+	}
+}
+
+// WithPhantomPort - Connect to phantoms on port, see ConjureSession.PhantomPort
+func WithPhantomPort(port uint16) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		if port == 0 {
+			return errors.New("phantom port must not be 0")
+		}
+		cjSession.PhantomPort = port
+		return nil
+	}
+}
+
+// WithPhantomServerName - Present name as SNI in the TLS transport handshakes with
+// the phantom, see ConjureSession.PhantomServerName
+func WithPhantomServerName(name string) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		if name == "" {
+			return errors.New("phantom server name must not be empty")
+		}
+		if err := validatePhantomServerName(name); err != nil {
+			return err
+		}
+		cjSession.PhantomServerName = name
+		return nil
+	}
+}
+
+// WithKeyGenerator - Generate the client key of the session with keyGen instead of
+// ElligatorKeyGenerator, see KeyGenerator
+func WithKeyGenerator(keyGen KeyGenerator) SessionOption {
+	return func(cjSession *ConjureSession) error {
+		if keyGen == nil {
+			return errors.New("key generator must not be nil")
+		}
+		cjSession.keyGen = keyGen
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package tapdance
+
+import (
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConjureSession(t *testing.T) {
+	session, err := NewConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	require.Equal(t, uint(defaultRegWidth), session.Width)
+	require.Equal(t, pb.TransportType_Min, session.Transport)
+	require.Equal(t, both, session.V6Support.include)
+	require.NotNil(t, session.Keys)
+
+	session, err = NewConjureSession("1.2.3.4:443",
+		WithWidth(2),
+		WithTransport(pb.TransportType_Obfs4),
+		WithIPVersions(true, false),
+		WithProxyHeader(true),
+		WithPhantomPort(8443),
+		WithPhantomServerName("cdn.example.com"))
+	require.Nil(t, err)
+	require.Equal(t, uint(2), session.Width)
+	require.Equal(t, pb.TransportType_Obfs4, session.Transport)
+	require.Equal(t, v4, session.V6Support.include)
+	require.True(t, session.UseProxyHeader)
+	require.Equal(t, "cdn.example.com", session.PhantomServerName)
+
+	// the phantom port is signalled to the station when not 443
+	reg := &ConjureReg{phantomPort: session.PhantomPort}
+	require.Equal(t, uint32(8443), reg.generateClientToStation().GetPhantomPort())
+	reg = &ConjureReg{}
+	require.Nil(t, reg.generateClientToStation().PhantomPort)
+
+	for _, opt := range []SessionOption{
+		WithWidth(0),
+		WithTransport(pb.TransportType(42)),
+		WithIPVersions(false, false),
+		WithPhantomPort(0),
+		WithPhantomServerName(""),
+		WithPhantomServerName("192.0.2.1"),
+		WithPhantomServerName("bad name"),
+	} {
+		_, err = NewConjureSession("1.2.3.4:443", opt)
+		require.NotNil(t, err)
+	}
+
+	_, err = NewConjureSession("1.2.3.4")
+	require.NotNil(t, err)
+}
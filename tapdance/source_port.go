@@ -0,0 +1,186 @@
+package tapdance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// SourcePortStrategy - How SourcePortDialer picks the local port of each connection
+type SourcePortStrategy int
+
+const (
+	// SourcePortOS leaves the port to the ephemeral port allocation of the OS
+	SourcePortOS SourcePortStrategy = iota
+
+	// SourcePortRandom picks each port uniformly at random within the range
+	SourcePortRandom
+
+	// SourcePortSequential walks the range in order, from a random first port, so
+	// that a small range is used evenly
+	SourcePortSequential
+)
+
+// sourcePortAttempts - ports tried per connection before giving up, when the ports
+// picked are in use
+const sourcePortAttempts = 8
+
+// SourcePorts - Strategy and range of the local ports of the connections made by
+// SourcePortDialer. Min and Max bound the range, inclusive, and default to 1024 and
+// 65535 when 0, so SourcePorts{Strategy: SourcePortRandom} randomizes the port over
+// all non-privileged ports instead of following the OS allocation pattern.
+//
+// Ports are bound before connecting, like with net.Dialer.LocalAddr. A port still
+// used by an earlier connection, e.g. in TIME_WAIT after closing, is in use and
+// fails the bind, and another port is tried, up to sourcePortAttempts. With high
+// connection churn over a small range, set SO_REUSEADDR in the Control function of
+// the net.Dialer given to SourcePortDialer so that ports in TIME_WAIT can be bound
+// again: the OS then still refuses to connect from a port whose earlier connection
+// to the same decoy address is in TIME_WAIT, and another port is tried as well.
+type SourcePorts struct {
+	Strategy SourcePortStrategy
+	Min      uint16
+	Max      uint16
+}
+
+// ParseSourcePorts - Parse "os", "random", "sequential", or either of the latter
+// with a range, e.g. "random:40000-41000"
+func ParseSourcePorts(s string) (SourcePorts, error) {
+	var ports SourcePorts
+	parts := strings.SplitN(s, ":", 2)
+	strategy, hasRange := parts[0], len(parts) == 2
+	switch strategy {
+	case "os":
+		if hasRange {
+			return ports, fmt.Errorf("source port strategy %q takes no range", strategy)
+		}
+		return ports, nil
+	case "random":
+		ports.Strategy = SourcePortRandom
+	case "sequential":
+		ports.Strategy = SourcePortSequential
+	default:
+		return ports, fmt.Errorf("unknown source port strategy %q", strategy)
+	}
+	if !hasRange {
+		return ports, nil
+	}
+
+	bounds := strings.SplitN(parts[1], "-", 2)
+	min, err := strconv.ParseUint(bounds[0], 10, 16)
+	if err != nil {
+		return ports, fmt.Errorf("invalid source port range %q: %v", parts[1], err)
+	}
+	max := min
+	if len(bounds) == 2 {
+		max, err = strconv.ParseUint(bounds[1], 10, 16)
+		if err != nil {
+			return ports, fmt.Errorf("invalid source port range %q: %v", parts[1], err)
+		}
+	}
+	ports.Min, ports.Max = uint16(min), uint16(max)
+	return ports, ports.validate()
+}
+
+func (ports SourcePorts) validate() error {
+	switch ports.Strategy {
+	case SourcePortOS, SourcePortRandom, SourcePortSequential:
+	default:
+		return fmt.Errorf("unknown source port strategy %v", ports.Strategy)
+	}
+	if min, max := ports.bounds(); min > max {
+		return fmt.Errorf("source port range %v-%v is empty", min, max)
+	}
+	return nil
+}
+
+// bounds - Get the range of ports, with defaults applied
+func (ports SourcePorts) bounds() (int, int) {
+	min, max := int(ports.Min), int(ports.Max)
+	if min == 0 {
+		min = 1024
+	}
+	if max == 0 {
+		max = 65535
+	}
+	return min, max
+}
+
+// sourcePortPicker - Picks the ports of SourcePorts, safe for concurrent dials
+type sourcePortPicker struct {
+	strategy SourcePortStrategy
+	min, max int
+
+	m    sync.Mutex
+	next int
+}
+
+func newSourcePortPicker(ports SourcePorts) *sourcePortPicker {
+	min, max := ports.bounds()
+	return &sourcePortPicker{strategy: ports.Strategy, min: min, max: max, next: getRandInt(min, max)}
+}
+
+// pick - Get the next port, 0 for one chosen by the OS
+func (p *sourcePortPicker) pick() int {
+	switch p.strategy {
+	case SourcePortRandom:
+		return getRandInt(p.min, p.max)
+	case SourcePortSequential:
+		p.m.Lock()
+		defer p.m.Unlock()
+		port := p.next
+		p.next++
+		if p.next > p.max {
+			p.next = p.min
+		}
+		return port
+	default:
+		return 0
+	}
+}
+
+// SourcePortDialer - Get a dialer of TCP connections from local ports picked by the
+// ports strategy, for use as Dialer.DecoyDialer, or Dialer.TcpDialer. Connections are
+// made with copies of d, or of a zero net.Dialer if nil, keeping the IP of
+// d.LocalAddr if set. Ports found in use are skipped, see SourcePorts.
+func SourcePortDialer(d *net.Dialer, ports SourcePorts) (func(context.Context, string, string) (net.Conn, error), error) {
+	if err := ports.validate(); err != nil {
+		return nil, err
+	}
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	if ports.Strategy == SourcePortOS {
+		return d.DialContext, nil
+	}
+
+	var localIP net.IP
+	if local, ok := d.LocalAddr.(*net.TCPAddr); ok {
+		localIP = local.IP
+	}
+	picker := newSourcePortPicker(ports)
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var err error
+		for i := 0; i < sourcePortAttempts; i++ {
+			bound := *d
+			bound.LocalAddr = &net.TCPAddr{IP: localIP, Port: picker.pick()}
+			var conn net.Conn
+			conn, err = bound.DialContext(ctx, network, address)
+			if err == nil || !sourcePortInUse(err) {
+				return conn, err
+			}
+		}
+		return nil, err
+	}, nil
+}
+
+// sourcePortInUse - Whether the dial failed because the source port is in use,
+// either bound (EADDRINUSE) or already connected to the address (EADDRNOTAVAIL)
+func sourcePortInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)
+}
@@ -0,0 +1,87 @@
+package tapdance
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourcePorts(t *testing.T) {
+	for s, expected := range map[string]SourcePorts{
+		"os":                     {},
+		"random":                 {Strategy: SourcePortRandom},
+		"random:40000-41000":     {Strategy: SourcePortRandom, Min: 40000, Max: 41000},
+		"sequential:40000-40009": {Strategy: SourcePortSequential, Min: 40000, Max: 40009},
+		"sequential:40000":       {Strategy: SourcePortSequential, Min: 40000, Max: 40000},
+	} {
+		ports, err := ParseSourcePorts(s)
+		require.Nil(t, err, s)
+		require.Equal(t, expected, ports, s)
+	}
+
+	for _, s := range []string{"", "fixed", "os:1-2", "random:2-1", "random:1-70000", "random:a-b"} {
+		_, err := ParseSourcePorts(s)
+		require.NotNil(t, err, s)
+	}
+}
+
+func TestSourcePortPicker(t *testing.T) {
+	picker := newSourcePortPicker(SourcePorts{Strategy: SourcePortRandom, Min: 40000, Max: 40003})
+	for i := 0; i < 100; i++ {
+		port := picker.pick()
+		require.GreaterOrEqual(t, port, 40000)
+		require.LessOrEqual(t, port, 40003)
+	}
+
+	// sequential ports walk the range, wrapping around
+	picker = newSourcePortPicker(SourcePorts{Strategy: SourcePortSequential, Min: 40000, Max: 40003})
+	first := picker.pick()
+	for i := 1; i < 8; i++ {
+		require.Equal(t, 40000+(first-40000+i)%4, picker.pick())
+	}
+
+	require.Equal(t, 0, newSourcePortPicker(SourcePorts{}).pick())
+}
+
+func TestSourcePortDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a port held by a listener is in use, and skipped
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer held.Close()
+	heldPort := held.Addr().(*net.TCPAddr).Port
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	freePort := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	dialer, err := SourcePortDialer(nil, SourcePorts{Strategy: SourcePortSequential, Min: uint16(heldPort), Max: uint16(heldPort)})
+	require.Nil(t, err)
+	_, err = dialer(context.Background(), "tcp", ln.Addr().String())
+	require.True(t, sourcePortInUse(err), "%v", err)
+
+	dialer, err = SourcePortDialer(&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}},
+		SourcePorts{Strategy: SourcePortRandom, Min: uint16(freePort), Max: uint16(freePort)})
+	require.Nil(t, err)
+	conn, err := dialer(context.Background(), "tcp", ln.Addr().String())
+	require.Nil(t, err)
+	require.Equal(t, freePort, conn.LocalAddr().(*net.TCPAddr).Port)
+	conn.Close()
+
+	_, err = SourcePortDialer(nil, SourcePorts{Strategy: SourcePortRandom, Min: 2, Max: 1})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,70 @@
+package tapdance
+
+import (
+	"time"
+)
+
+// Timing - A randomized, RTT-scaled delay. Conjure uses these to avoid
+// fixed timing signals between registration and connection steps.
+//
+// A delay is Base + RTT * (r / RTTScale) milliseconds, where RTT is the measured TCP
+// round trip to the decoy in milliseconds (300 if not measured yet), r is drawn
+// uniformly from [Min, Max], and the division is an integer division.
+type Timing struct {
+	// Base is added to every delay
+	Base time.Duration
+
+	// Min and Max bound the random RTT multiplier, in units of 1/RTTScale
+	Min int
+	Max int
+
+	// RTTScale divides the random multiplier, truncating it to a whole number.
+	// Values below 1 are treated as 1.
+	RTTScale int
+}
+
+// Duration - Get a random delay for the given RTT in milliseconds
+func (t Timing) Duration(rttMillis uint32) time.Duration {
+	scale := t.RTTScale
+	if scale < 1 {
+		scale = 1
+	}
+	multiplier := getRandInt(t.Min, t.Max) / scale
+	return t.Base + time.Millisecond*time.Duration(rttInt(rttMillis)*multiplier)
+}
+
+// Timings - The randomized delays used by a Conjure session
+type Timings struct {
+	// RegistrationSleep is slept after the registrations are sent, before
+	// connecting to the phantom, to break the intraflow signal. A zero Timing
+	// disables it; see ConjureSession.NoRegistrationSleep for the trade-off.
+	RegistrationSleep Timing
+
+	// PhantomDialTimeout bounds the phantom dial when the context has no deadline.
+	PhantomDialTimeout Timing
+
+	// DecoyDialTimeout bounds the TCP connection to a decoy, separately from the
+	// TLS handshake, so that a black-holed decoy fails fast instead of using the
+	// whole registration deadline. The RTT is not measured yet and counts as 300ms.
+	// A zero Timing only bounds the dial by the registration deadline.
+	DecoyDialTimeout Timing
+
+	// DecoyTLSTimeout bounds the TLS handshake with a decoy, based on the RTT of
+	// the TCP connection to that decoy.
+	DecoyTLSTimeout Timing
+}
+
+// DefaultTimings - The delays used when a session does not override them.
+// In milliseconds, for an RTT of 100ms:
+//   - RegistrationSleep:  3000 + 100 * {0..3}
+//   - PhantomDialTimeout: 100 * {2..5}
+//   - DecoyDialTimeout:   300 * {10..20}, the RTT not being measured yet
+//   - DecoyTLSTimeout:    100 * [2122, 5859]
+func DefaultTimings() Timings {
+	return Timings{
+		RegistrationSleep:  Timing{Base: 3000 * time.Millisecond, Min: 212, Max: 3449, RTTScale: 1000},
+		PhantomDialTimeout: Timing{Min: 1061 * 2, Max: 1953 * 3, RTTScale: 1000},
+		DecoyDialTimeout:   Timing{Min: 10, Max: 20, RTTScale: 1},
+		DecoyTLSTimeout:    Timing{Min: 1061 * 2, Max: 1953 * 3, RTTScale: 1},
+	}
+}
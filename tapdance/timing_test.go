@@ -0,0 +1,52 @@
+package tapdance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimingDefaults(t *testing.T) {
+	timings := DefaultTimings()
+	for i := 0; i < 100; i++ {
+		// registration sleep: 3000 + rtt * {0..3}
+		d := timings.RegistrationSleep.Duration(100)
+		require.GreaterOrEqual(t, int64(d), int64(3000*time.Millisecond))
+		require.LessOrEqual(t, int64(d), int64(3300*time.Millisecond))
+		require.Equal(t, time.Duration(0), d%(100*time.Millisecond))
+
+		// phantom dial: rtt * {2..5}
+		d = timings.PhantomDialTimeout.Duration(100)
+		require.GreaterOrEqual(t, int64(d), int64(200*time.Millisecond))
+		require.LessOrEqual(t, int64(d), int64(500*time.Millisecond))
+
+		// decoy dial: 300 * {10..20}, whatever the RTT
+		d = timings.DecoyDialTimeout.Duration(0)
+		require.GreaterOrEqual(t, int64(d), int64(3000*time.Millisecond))
+		require.LessOrEqual(t, int64(d), int64(6000*time.Millisecond))
+
+		// decoy TLS: rtt * [2122, 5859]
+		d = timings.DecoyTLSTimeout.Duration(10)
+		require.GreaterOrEqual(t, int64(d), int64(21220*time.Millisecond))
+		require.LessOrEqual(t, int64(d), int64(58590*time.Millisecond))
+	}
+
+	// unmeasured RTT defaults to 300ms
+	d := Timing{Min: 1, Max: 1}.Duration(0)
+	require.Equal(t, 300*time.Millisecond, d)
+}
+
+func TestTimingSessionOverride(t *testing.T) {
+	session := makeTestSession(t, "1.2.3.4:1234")
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, DefaultTimings(), *reg.getTimings())
+
+	timings := DefaultTimings()
+	timings.RegistrationSleep = Timing{Base: 10 * time.Millisecond}
+	session.Timings = &timings
+	reg, err = session.newConjureReg()
+	require.Nil(t, err)
+	require.Equal(t, 10*time.Millisecond, reg.getTimings().RegistrationSleep.Duration(100))
+}
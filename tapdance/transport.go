@@ -0,0 +1,96 @@
+package tapdance
+
+import (
+	"context"
+	"net"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"gitlab.com/yawning/obfs4.git/transports/obfs4"
+)
+
+// Transport - Sets up the connection to the station of a registered session, over
+// its phantoms or another path the station is reachable on. Each TransportType the
+// client supports has one.
+type Transport interface {
+	// Connect - Connect to the station for reg, ready for the covert stream
+	Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error)
+}
+
+// transports - Transport of each supported TransportType
+var transports = map[pb.TransportType]Transport{
+	pb.TransportType_Min:     minTransport{},
+	pb.TransportType_Obfs4:   obfs4Transport{},
+	pb.TransportType_TLS:     tlsTransport{},
+	pb.TransportType_Fronted: frontedTransport{},
+	pb.TransportType_Null:    nullTransport{},
+}
+
+// minTransport - Sends the connect tag on the first reachable phantom
+type minTransport struct{}
+
+func (minTransport) Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error) {
+	conn, err := reg.getFirstConnection(ctx, reg.TcpDialer, reg.phantomIPs())
+	if err != nil {
+		reg.logger().Infof("%v failed to form phantom connection: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+
+	// Send hmac(seed, str) bytes to indicate to station (min transport)
+	reg.writeConnectTag(conn)
+	return conn, nil
+}
+
+// obfs4Transport - Performs an obfs4 handshake with the first reachable phantom
+type obfs4Transport struct{}
+
+func (obfs4Transport) Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error) {
+	args, err := obfs4Args(reg.obfs4Params, reg.keys.Obfs4Keys)
+	if err != nil {
+		reg.logger().Infof("%v invalid obfs4 parameters: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+
+	nodeID, _ := args.Get("node-id")
+	publicKey, _ := args.Get("public-key")
+	iatMode, _ := args.Get("iat-mode")
+	reg.logger().Infof("%v node_id = %s; public key = %s; iat-mode = %s", reg.sessionIDStr, nodeID, publicKey, iatMode)
+
+	t := obfs4.Transport{}
+	c, err := t.ClientFactory("")
+	if err != nil {
+		reg.logger().Infof("%v failed to create client factory: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+
+	parsedArgs, err := c.ParseArgs(args)
+	if err != nil {
+		reg.logger().Infof("%v failed to parse obfs4 args: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+
+	dialer := func(dialContext context.Context, network string, address string) (net.Conn, error) {
+		d := func(network, address string) (net.Conn, error) { return reg.TcpDialer(dialContext, network, address) }
+		return c.Dial("tcp", address, d, parsedArgs)
+	}
+
+	conn, err := reg.getFirstConnection(ctx, dialer, reg.phantomIPs())
+	if err != nil {
+		reg.logger().Infof("%v failed to form obfs4 connection: %v", reg.sessionIDStr, err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// nullTransport - Dials the first reachable phantom and does nothing to the connection
+// before returning it to the user, unless a connect tag was defined for the null
+// transport.
+type nullTransport struct{}
+
+func (nullTransport) Connect(ctx context.Context, reg *ConjureReg) (net.Conn, error) {
+	conn, err := reg.getFirstConnection(ctx, reg.TcpDialer, reg.phantomIPs())
+	if err != nil {
+		return nil, err
+	}
+	reg.writeConnectTag(conn)
+	return conn, nil
+}
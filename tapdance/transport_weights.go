@@ -0,0 +1,95 @@
+package tapdance
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+)
+
+// WeightedTransport - A transport and its relative weight in TransportWeights
+type WeightedTransport struct {
+	Transport pb.TransportType
+	Weight    uint
+}
+
+// TransportWeights - Transports picked at random by weight, one per session, e.g. to
+// compare the stealth and performance of transports in experiments. The pick is
+// derived from the ConjureSeed of the session, so a session with the same keys always
+// picks the same transport.
+type TransportWeights []WeightedTransport
+
+// ParseTransportWeights - Parse comma-separated transports with their weights, e.g.
+// "min:3,obfs4:1" to pick min 3 times out of 4. A transport without a weight has
+// weight 1.
+func ParseTransportWeights(s string) (TransportWeights, error) {
+	var weights TransportWeights
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, ":", 2)
+		transport, err := parseTransportName(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		weight := uint64(1)
+		if len(parts) == 2 {
+			weight, err = strconv.ParseUint(parts[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight of transport %v: %v", parts[0], err)
+			}
+		}
+		weights = append(weights, WeightedTransport{Transport: transport, Weight: uint(weight)})
+	}
+	return weights, weights.validate()
+}
+
+// parseTransportName - Get the transport named, e.g. "min" or "obfs4", in any case
+func parseTransportName(name string) (pb.TransportType, error) {
+	for value, transportName := range pb.TransportType_name {
+		if strings.EqualFold(name, transportName) {
+			return pb.TransportType(value), nil
+		}
+	}
+	return pb.TransportType_Null, fmt.Errorf("unknown transport %q", name)
+}
+
+func (weights TransportWeights) validate() error {
+	if weights.total() == 0 {
+		return fmt.Errorf("transport weights %v sum to 0", weights)
+	}
+	return nil
+}
+
+func (weights TransportWeights) total() uint64 {
+	var total uint64
+	for _, w := range weights {
+		total += uint64(w.Weight)
+	}
+	return total
+}
+
+// pick - Get the transport seed maps to, with a probability proportional to its weight
+func (weights TransportWeights) pick(seed []byte) pb.TransportType {
+	total := weights.total()
+	if total == 0 {
+		return pb.TransportType_Min
+	}
+	roll := binary.BigEndian.Uint64(conjureHMAC(seed, "TransportSelectionHMACString")) % total
+	for _, w := range weights {
+		if roll < uint64(w.Weight) {
+			return w.Transport
+		}
+		roll -= uint64(w.Weight)
+	}
+	return weights[len(weights)-1].Transport
+}
+
+// String - Format the weights like ParseTransportWeights parses them
+func (weights TransportWeights) String() string {
+	entries := make([]string, len(weights))
+	for i, w := range weights {
+		entries[i] = fmt.Sprintf("%v:%v", strings.ToLower(w.Transport.String()), w.Weight)
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,67 @@
+package tapdance
+
+import (
+	"crypto/rand"
+	"testing"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransportWeights(t *testing.T) {
+	weights, err := ParseTransportWeights("min:3,Obfs4:1,tls")
+	require.Nil(t, err)
+	require.Equal(t, TransportWeights{
+		{Transport: pb.TransportType_Min, Weight: 3},
+		{Transport: pb.TransportType_Obfs4, Weight: 1},
+		{Transport: pb.TransportType_TLS, Weight: 1},
+	}, weights)
+	require.Equal(t, "min:3,obfs4:1,tls:1", weights.String())
+
+	for _, s := range []string{"", "quic:1", "min:-1", "min:a", "min:0,tls:0"} {
+		_, err := ParseTransportWeights(s)
+		require.NotNil(t, err, s)
+	}
+}
+
+func TestTransportWeightsPick(t *testing.T) {
+	weights := TransportWeights{
+		{Transport: pb.TransportType_Min, Weight: 3},
+		{Transport: pb.TransportType_Obfs4, Weight: 1},
+		{Transport: pb.TransportType_TLS, Weight: 0},
+	}
+
+	picked := make(map[pb.TransportType]int)
+	seed := make([]byte, 16)
+	for i := 0; i < 4000; i++ {
+		_, err := rand.Read(seed)
+		require.Nil(t, err)
+		transport := weights.pick(seed)
+		require.Equal(t, transport, weights.pick(seed))
+		picked[transport]++
+	}
+	require.Zero(t, picked[pb.TransportType_TLS])
+	require.InDelta(t, 3000, picked[pb.TransportType_Min], 200)
+	require.InDelta(t, 1000, picked[pb.TransportType_Obfs4], 200)
+}
+
+func TestDialerTransportWeights(t *testing.T) {
+	AssetsSetDir("./assets")
+	d := Dialer{
+		DarkDecoy:        true,
+		Transport:        pb.TransportType_TLS,
+		TransportWeights: TransportWeights{{Transport: pb.TransportType_Obfs4, Weight: 1}},
+	}
+	session, err := d.makeConjureSession("1.2.3.4:443")
+	require.Nil(t, err)
+	require.Equal(t, pb.TransportType_Obfs4, session.Transport)
+
+	// the picked transport is recorded in the registration stats
+	reg, err := session.newConjureReg()
+	require.Nil(t, err)
+	require.Contains(t, reg.digestStats(), "transport:Obfs4")
+
+	d.TransportWeights = TransportWeights{{Transport: pb.TransportType_Obfs4, Weight: 0}}
+	_, err = d.makeConjureSession("1.2.3.4:443")
+	require.NotNil(t, err)
+}
@@ -27,7 +27,8 @@ func aesGcmEncrypt(plaintext []byte, key []byte, iv []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	aesGcmCipher, err := cipher.NewGCM(block)
+	// IVs of other sizes than the standard 12 bytes may be set, see KeyLengths
+	aesGcmCipher, err := cipher.NewGCMWithNonceSize(block, len(iv))
 	if err != nil {
 		return nil, err
 	}
@@ -131,16 +132,9 @@ func uint16toInt16(i uint16) int16 {
 	return pos + neg
 }
 
-// generates HTTP request, that is ready to have tag prepended to it
-func generateHTTPRequestBeginning(decoyHostname string) []byte {
-	sharedHeaders := `Host: ` + decoyHostname +
-		"\nUser-Agent: TapDance/1.2 (+https://refraction.network/info)"
-	httpTag := fmt.Sprintf(`GET / HTTP/1.1
-%s
-X-Ignore: %s`, sharedHeaders, getRandPadding(7, maxInt(612-len(sharedHeaders), 7), 10))
-	return []byte(strings.Replace(httpTag, "\n", "\r\n", -1))
-}
-
+// reverseEncrypt - Encode a tag, 3 bytes at a time, as the plaintext whose encryption
+// with keyStream has the tag bits in the low 6 bits of each byte. The plaintext bytes
+// are in 0x40-0x7f, so the plaintext can end the registration HTTP request.
 func reverseEncrypt(ciphertext []byte, keyStream []byte) []byte {
 	var plaintext string
 	// our plaintext can be antyhing where x & 0xc0 == 0x40
@@ -189,6 +183,48 @@ func reverseEncrypt(ciphertext []byte, keyStream []byte) []byte {
 	return []byte(plaintext)
 }
 
+// reverseEncryptedSize - Size of the plaintext reverseEncrypt encodes a tag of tagLen
+// bytes in: 4 bytes per 3 bytes of tag.
+func reverseEncryptedSize(tagLen int) int {
+	return (tagLen + 2) / 3 * 4
+}
+
+// reverseEncryptKeystreamSize - Number of keystream bytes reverseEncrypt may use to
+// encode a tag of tagLen bytes: 4 per 3 bytes of tag, plus a spare group.
+func reverseEncryptKeystreamSize(tagLen int) int {
+	return (tagLen/3 + 1) * 4 // we can't use first 2 bits of every byte
+}
+
+// ReverseDecrypt - Recover the tag encoded by reverseEncrypt from the plaintext it
+// produced and the same keystream, as the station does.
+func ReverseDecrypt(plaintext []byte, keyStream []byte) ([]byte, error) {
+	if len(plaintext)%4 != 0 {
+		return nil, fmt.Errorf("plaintext length %v is not a multiple of 4", len(plaintext))
+	}
+	if len(keyStream) < len(plaintext) {
+		return nil, fmt.Errorf("keystream too short: %v bytes for %v bytes of plaintext",
+			len(keyStream), len(plaintext))
+	}
+
+	ciphertext := make([]byte, 0, len(plaintext)/4*3)
+	var c [4]byte
+	for i := 0; i < len(plaintext); i += 4 {
+		for j := range c {
+			p := plaintext[i+j]
+			if p&0xc0 != 0x40 {
+				return nil, fmt.Errorf("plaintext byte %v out of range: %#x", i+j, p)
+			}
+			// undo the 0x40 offset and the xor; only the lower 6 bits carry the tag
+			c[j] = ((p - 0x40) ^ keyStream[i+j]) & 0x3f
+		}
+		ciphertext = append(ciphertext,
+			c[0]<<2|c[1]>>4, // 6 bits sa, 2 bits sa
+			c[1]<<4|c[2]>>2, // 4 bits sb, 4 bits sb
+			c[2]<<6|c[3])    // 2 bits sc, 6 bits sc
+	}
+	return ciphertext, nil
+}
+
 func minInt(a, b int) int {
 	if a > b {
 		return b
@@ -298,3 +334,26 @@ func obfuscateTagAndProtobuf(stegoPayload []byte, protobuf []byte, stationPubkey
 	encryptedProtobuf, err := aesGcmEncrypt(protobuf, aesKey, aesIvProtobuf)
 	return tag, append(aesIvProtobuf, encryptedProtobuf...), err
 }
+
+// SetKeepAlive - Configure TCP keepalive on conn like net.Dialer.KeepAlive does on
+// dialed connections: probes every period if positive, the Go default if zero, and
+// disabled if negative. Connections that are not TCP are left unchanged.
+func SetKeepAlive(conn net.Conn, period time.Duration) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if period < 0 {
+		return tcpConn.SetKeepAlive(false)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if period == 0 {
+		period = defaultKeepAlive
+	}
+	return tcpConn.SetKeepAlivePeriod(period)
+}
+
+// defaultKeepAlive - keepalive period of net.Dialer and net.Listener by default
+const defaultKeepAlive = 15 * time.Second
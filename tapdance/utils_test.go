@@ -1,13 +1,16 @@
 package tapdance
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -31,7 +34,7 @@ func aesGcmDecrypt(ciphertext []byte, key []byte, iv []byte) (plaintext []byte,
 		return
 	}
 
-	aesGcmCipher, err := cipher.NewGCM(block)
+	aesGcmCipher, err := cipher.NewGCMWithNonceSize(block, len(iv))
 	if err != nil {
 		return
 	}
@@ -82,6 +85,43 @@ func TestReverseEncrypt(t *testing.T) {
 	}
 }
 
+func TestReverseEncryptRoundtrip(t *testing.T) {
+	// registration tags are padded to a multiple of 3 bytes
+	for tagLen := 3; tagLen <= 600; tagLen += 3 {
+		tag := make([]byte, tagLen)
+		keystream := make([]byte, reverseEncryptKeystreamSize(tagLen))
+		if _, err := rand.Read(tag); err != nil {
+			t.Fatalf("Error: %v\n", err)
+		}
+		if _, err := rand.Read(keystream); err != nil {
+			t.Fatalf("Error: %v\n", err)
+		}
+
+		plaintext := reverseEncrypt(tag, keystream)
+		if len(plaintext) != tagLen/3*4 {
+			t.Fatalf("Tag of %v bytes encoded to %v bytes, expected %v", tagLen, len(plaintext), tagLen/3*4)
+		}
+		decrypted, err := ReverseDecrypt(plaintext, keystream)
+		if err != nil {
+			t.Fatalf("Error decrypting tag of %v bytes: %v", tagLen, err)
+		}
+		if !bytes.Equal(tag, decrypted) {
+			t.Fatalf("Decrypted tag of %v bytes differs from original!\nDecrypt(Encrypt(tag)): %s\ntag: %s\n",
+				tagLen, hex.Dump(decrypted), hex.Dump(tag))
+		}
+	}
+
+	if _, err := ReverseDecrypt([]byte("ABC"), make([]byte, 4)); err == nil {
+		t.Fatal("Expected error decrypting a truncated plaintext")
+	}
+	if _, err := ReverseDecrypt([]byte("ABCD"), make([]byte, 3)); err == nil {
+		t.Fatal("Expected error decrypting with a short keystream")
+	}
+	if _, err := ReverseDecrypt([]byte("AB\x00D"), make([]byte, 4)); err == nil {
+		t.Fatal("Expected error decrypting a plaintext byte out of range")
+	}
+}
+
 func TestObfuscationRandomness(t *testing.T) {
 	testKey, _ := hex.DecodeString("b47066bc390d2605cc13581c496ea995cb8cfadf00a649052509ef4ac8a51a07")
 
@@ -177,3 +217,30 @@ func (rt *randomnessChecker) testInRange(min, max int) error {
 	}
 	return nil
 }
+
+func TestSetKeepAlive(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	for _, period := range []time.Duration{-1, 0, 30 * time.Second} {
+		if err := SetKeepAlive(conn, period); err != nil {
+			t.Fatalf("Failed to set keepalive %v: %v", period, err)
+		}
+	}
+
+	// connections that are not TCP are left unchanged
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err := SetKeepAlive(client, time.Second); err != nil {
+		t.Fatalf("SetKeepAlive on a pipe returned %v", err)
+	}
+}
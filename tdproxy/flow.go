@@ -3,7 +3,6 @@ package tdproxy
 import (
 	"errors"
 	"github.com/dimuls/gotapdance/tapdance"
-	"io"
 	"net"
 	"strconv"
 	"strings"
@@ -40,6 +39,9 @@ func makeTapDanceFlow(proxy *TapDanceProxy, id uint64, splitFlows bool) *tapDanc
 
 func (TDstate *tapDanceFlow) redirect() error {
 	dialer := tapdance.Dialer{SplitFlows: TDstate.splitFlows, DarkDecoy: true}
+	if TDstate.proxy.keepAlive != 0 {
+		dialer.NetDialer = &net.Dialer{KeepAlive: TDstate.proxy.keepAlive}
+	}
 	var err error
 	TDstate.servConn, err = dialer.DialProxy()
 	if err != nil {
@@ -54,8 +56,7 @@ func (TDstate *tapDanceFlow) redirect() error {
 	}()
 
 	forwardFromServerToClient := func() {
-		buf := make([]byte, 65536)
-		n, _err := io.CopyBuffer(TDstate.userConn, TDstate.servConn, buf)
+		n, _err := TDstate.proxy.copyBuffers.Copy(TDstate.userConn, TDstate.servConn)
 		Logger.Debugf("{tapDanceFlow} forwardFromServerToClient returns, bytes sent: " +
 			strconv.FormatUint(uint64(n), 10))
 		if _err == nil {
@@ -66,8 +67,7 @@ func (TDstate *tapDanceFlow) redirect() error {
 	}
 
 	forwardFromClientToServer := func() {
-		buf := make([]byte, 65536)
-		n, _err := io.CopyBuffer(TDstate.servConn, TDstate.userConn, buf)
+		n, _err := TDstate.proxy.copyBuffers.Copy(TDstate.servConn, TDstate.userConn)
 		Logger.Debugf("{tapDanceFlow} forwardFromClientToServer returns, bytes sent: " +
 			strconv.FormatUint(uint64(n), 10))
 		if _err == nil {
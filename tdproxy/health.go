@@ -0,0 +1,188 @@
+package tdproxy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/dimuls/gotapdance/tapdance"
+)
+
+const (
+	// defaultHealthProbeInterval - How often HealthServer probes decoys by default
+	defaultHealthProbeInterval = time.Minute
+	// healthProbeDecoys - Number of random decoys tested by each HealthServer probe
+	healthProbeDecoys = 5
+	// healthProbeTimeout - How long a HealthServer probe may take
+	healthProbeTimeout = 15 * time.Second
+)
+
+// HealthServer - HTTP server of the liveness and readiness of the client, for
+// orchestrators and load balancers to probe. /healthz answers 200 as long as the
+// process serves it. /readyz answers 200 when the assets have decoys and one of the
+// decoys was reachable in a recent probe, and 503 with the reason otherwise. Decoys
+// are probed in the background every ProbeInterval, by testing a few of them at
+// random over TCP and TLS like tapdance.TestDecoys, through DecoyDialer.
+type HealthServer struct {
+	// ProbeInterval - How often to probe decoys. A probe older than twice the interval
+	// does not count towards readiness. Default(0): one minute.
+	ProbeInterval time.Duration
+
+	// DecoyDialer - Dialer of the probed decoys, which should be the DecoyDialer of the
+	// registrations so that readiness reflects the path they take, e.g. a decoy proxy.
+	// Default(nil): direct TCP connections.
+	DecoyDialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// testDecoys - Test the given decoys, tapdance.TestDecoyList if nil
+	testDecoys func(context.Context, []*pb.TLSDecoySpec) []tapdance.DecoyResult
+
+	m         sync.Mutex
+	lastProbe time.Time
+	reachable int
+	probed    int
+
+	server *http.Server
+	cancel context.CancelFunc
+}
+
+// ListenAndServe - Serve the health endpoints at address, e.g. "127.0.0.1:8080", and
+// probe decoys until Close. Probing from outside of a container takes an address of
+// an interface reachable from there, e.g. ":8080" for all interfaces.
+func (h *HealthServer) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return h.Serve(listener)
+}
+
+// Serve - Like ListenAndServe, with the given listener
+func (h *HealthServer) Serve(listener net.Listener) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.m.Lock()
+	h.server = &http.Server{Handler: h.handler()}
+	h.cancel = cancel
+	server := h.server
+	h.m.Unlock()
+
+	go h.probeLoop(ctx)
+	Logger.Infof("Serving health endpoints at %v", listener.Addr())
+	err := server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close - Stop serving the health endpoints and probing decoys
+func (h *HealthServer) Close() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.server == nil {
+		return nil
+	}
+	h.cancel()
+	return h.server.Close()
+}
+
+func (h *HealthServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// ready - Get why the client is not ready, or nil if it is
+func (h *HealthServer) ready() error {
+	if len(tapdance.Assets().GetAllDecoys()) == 0 {
+		return fmt.Errorf("no decoys in the assets")
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.lastProbe.IsZero() {
+		return fmt.Errorf("decoys not probed yet")
+	}
+	if age := time.Since(h.lastProbe); age > 2*h.probeInterval() {
+		return fmt.Errorf("last decoy probe is %v old", age.Round(time.Second))
+	}
+	if h.reachable == 0 {
+		return fmt.Errorf("none of %d decoys probed %v ago was reachable", h.probed,
+			time.Since(h.lastProbe).Round(time.Second))
+	}
+	return nil
+}
+
+func (h *HealthServer) probeInterval() time.Duration {
+	if h.ProbeInterval <= 0 {
+		return defaultHealthProbeInterval
+	}
+	return h.ProbeInterval
+}
+
+func (h *HealthServer) probeLoop(ctx context.Context) {
+	ticker := time.NewTicker(h.probeInterval())
+	defer ticker.Stop()
+	for {
+		h.probe(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// probe - Test a few random decoys of the assets and record how many were reachable
+func (h *HealthServer) probe(ctx context.Context) {
+	decoys := append([]*pb.TLSDecoySpec(nil), tapdance.Assets().GetAllDecoys()...)
+	rand.Shuffle(len(decoys), func(i, j int) { decoys[i], decoys[j] = decoys[j], decoys[i] })
+	if len(decoys) > healthProbeDecoys {
+		decoys = decoys[:healthProbeDecoys]
+	}
+
+	testDecoys := h.testDecoys
+	if testDecoys == nil {
+		testDecoys = tapdance.TestDecoyList
+		if h.DecoyDialer != nil {
+			testDecoys = func(ctx context.Context, decoys []*pb.TLSDecoySpec) []tapdance.DecoyResult {
+				return tapdance.TestDecoyListDialer(ctx, decoys, h.DecoyDialer)
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	results := testDecoys(ctx, decoys)
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	reachable := 0
+	for _, result := range results {
+		if result.Err == nil {
+			reachable++
+		}
+	}
+	if reachable == 0 && len(results) > 0 {
+		Logger.Warnf("Health probe: none of %d decoys was reachable", len(results))
+	}
+
+	h.m.Lock()
+	h.lastProbe = time.Now()
+	h.reachable = reachable
+	h.probed = len(results)
+	h.m.Unlock()
+}
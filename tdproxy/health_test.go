@@ -0,0 +1,93 @@
+package tdproxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/dimuls/gotapdance/protobuf"
+	"github.com/dimuls/gotapdance/tapdance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthServer(t *testing.T) {
+	var reachable atomic.Value
+	reachable.Store(false)
+	h := &HealthServer{
+		ProbeInterval: 50 * time.Millisecond,
+		testDecoys: func(ctx context.Context, decoys []*pb.TLSDecoySpec) []tapdance.DecoyResult {
+			var err error
+			if !reachable.Load().(bool) {
+				err = errors.New("unreachable")
+			}
+			results := make([]tapdance.DecoyResult, len(decoys))
+			for i, decoy := range decoys {
+				results[i] = tapdance.DecoyResult{Decoy: decoy, Err: err}
+			}
+			return results
+		},
+	}
+
+	// readiness waits for a probe with a reachable decoy
+	require.EqualError(t, h.ready(), "decoys not probed yet")
+	h.probe(context.Background())
+	require.Contains(t, h.ready().Error(), "none of 1 decoys")
+	reachable.Store(true)
+	h.probe(context.Background())
+	require.Nil(t, h.ready())
+
+	// and expires when probes stop
+	h.m.Lock()
+	h.lastProbe = time.Now().Add(-time.Second)
+	h.m.Unlock()
+	require.Contains(t, h.ready().Error(), "last decoy probe is")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	served := make(chan error, 1)
+	go func() { served <- h.Serve(listener) }()
+	defer h.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + listener.Addr().String() + path)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, _ := get("/healthz")
+	require.Equal(t, http.StatusOK, code)
+	reachable.Store(false)
+	h.probe(context.Background())
+	code, body := get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code, body)
+	reachable.Store(true)
+	require.Eventually(t, func() bool {
+		code, _ := get("/readyz")
+		return code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	require.Nil(t, h.Close())
+	require.Nil(t, <-served)
+}
+
+func TestHealthServerDecoyDialer(t *testing.T) {
+	// decoys are probed through the DecoyDialer, e.g. a decoy proxy
+	var dials int32
+	h := &HealthServer{
+		DecoyDialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("proxy refused")
+		},
+	}
+	h.probe(context.Background())
+	require.NotZero(t, atomic.LoadInt32(&dials))
+	require.Contains(t, h.ready().Error(), "none of")
+}
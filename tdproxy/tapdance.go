@@ -42,6 +42,12 @@ type TapDanceProxy struct {
 
 	statsTicker *time.Ticker
 
+	// buffers of the copies between users and TapDance connections
+	copyBuffers *tapdance.BufferPool
+
+	// TCP keepalive period of user and TapDance connections, see SetKeepAlive
+	keepAlive time.Duration
+
 	stop bool
 }
 
@@ -51,6 +57,7 @@ func NewTapDanceProxy(listenPort int) *TapDanceProxy {
 	proxy.listenPort = listenPort
 
 	proxy.connections.m = make(map[uint64]*tapDanceFlow)
+	proxy.copyBuffers = tapdance.NewBufferPool(defaultCopyBufferSize)
 	proxy.State = ProxyStateInitialized
 
 	Logger.Infof("Successfully initialized new Tapdance Proxy")
@@ -59,6 +66,24 @@ func NewTapDanceProxy(listenPort int) *TapDanceProxy {
 	return proxy
 }
 
+// defaultCopyBufferSize - size of the buffers tunnels copy with by default
+const defaultCopyBufferSize = 65536
+
+// SetCopyBufferSize - Set the size of the buffers tunnels copy with, from a pool
+// shared by all tunnels. Larger buffers help high throughput tunnels. Must be called
+// before ListenAndServe.
+func (proxy *TapDanceProxy) SetCopyBufferSize(size int) {
+	proxy.copyBuffers = tapdance.NewBufferPool(size)
+}
+
+// SetKeepAlive - Set the TCP keepalive period of the user connections and of the
+// connections to decoys, so that idle tunnels are not dropped by NATs and firewalls.
+// Zero keeps the Go default of 15s, and a negative period disables keepalives. Must
+// be called before ListenAndServe.
+func (proxy *TapDanceProxy) SetKeepAlive(period time.Duration) {
+	proxy.keepAlive = period
+}
+
 func (proxy *TapDanceProxy) statsHelper() error {
 	proxy.statsTicker = time.NewTicker(time.Second * time.Duration(60))
 	for range proxy.statsTicker.C {
@@ -82,6 +107,7 @@ func (proxy *TapDanceProxy) ListenAndServe() error {
 
 	for !proxy.stop {
 		if conn, err := proxy.listener.Accept(); err == nil {
+			tapdance.SetKeepAlive(conn, proxy.keepAlive)
 			go proxy.handleUserConn(conn)
 		} else {
 			if proxy.stop {